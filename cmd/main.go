@@ -1,13 +1,28 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"time"
 
 	easymirrorbackend "github.com/easymirror/easymirror-backend/internal/api"
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload"
 	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+	"github.com/easymirror/easymirror-backend/internal/hosts/limit"
+	"github.com/easymirror/easymirror-backend/internal/jobs"
+	"github.com/easymirror/easymirror-backend/internal/linkhealth"
+	"github.com/easymirror/easymirror-backend/internal/mailer"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/easymirror/easymirror-backend/internal/vault"
 	"github.com/joho/godotenv"
 )
 
+const (
+	defaultAnonRetention = 30 * (24 * time.Hour) // How long unused anonymous users are kept by default
+)
+
 func main() {
 	// Load the env file
 	if err := godotenv.Load(".env"); err != nil {
@@ -29,7 +44,96 @@ func main() {
 		}
 	}()
 
+	// Start background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	retention := durationFromEnv("ANON_USER_RETENTION", defaultAnonRetention)
+	jobs.Start(ctx, jobs.Job{
+		Name:     "purge-anonymous-users",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			n, err := user.PurgeAnonymous(ctx, database, retention)
+			if n > 0 {
+				log.Printf("Purged %v anonymous users", n)
+			}
+			return err
+		},
+	})
+
+	// One keyring for the vault, shared by the rewrap job and the upload handler
+	keyring, err := vault.FromEnv()
+	if err != nil {
+		panic(err)
+	}
+	if keyring != nil {
+		jobs.Start(ctx, jobs.Job{
+			Name:     "vault-rewrap",
+			Interval: 24 * time.Hour,
+			Run: func(ctx context.Context) error {
+				n, err := vault.RewrapAll(ctx, database, keyring)
+				if n > 0 {
+					log.Printf("Rewrapped %v vault keys with master key %v", n, keyring.ActiveKey())
+				}
+				return err
+			},
+		})
+	}
+
+	// Emails are sent over SMTP, or only logged with MAILER_LOG=true
+	m, err := mailer.FromEnv()
+	if err != nil {
+		panic(err)
+	}
+
+	// Load the limits and proxies of the hosts before anything gets uploaded
+	if err = limit.FromEnv(); err != nil {
+		panic(err)
+	}
+	clients, err := httpx.FromEnv()
+	if err != nil {
+		panic(err)
+	}
+	httpx.SetDefault(clients)
+	jobs.Start(ctx, jobs.Job{
+		Name:     "proxy-health",
+		Interval: time.Minute,
+		Run:      clients.CheckProxies,
+	})
+
+	// Probe the links of mirrors and upload the ones that died again
+	uploads, err := upload.NewHandler(database, keyring)
+	if err != nil {
+		panic(err)
+	}
+	monitor := &linkhealth.Monitor{
+		Database: database,
+		Remirror: uploads.RemirrorHost,
+		Recheck:  durationFromEnv("LINK_HEALTH_RECHECK", linkhealth.DefaultRecheck),
+		History:  durationFromEnv("LINK_HEALTH_HISTORY", linkhealth.DefaultHistory),
+	}
+	jobs.Start(ctx, jobs.Job{
+		Name:     "link-health",
+		Interval: durationFromEnv("LINK_HEALTH_INTERVAL", time.Hour),
+		Run:      monitor.Run,
+	})
+
+	// Delete the staged files of mirrors once their retention expired
+	jobs.Start(ctx, jobs.Job{
+		Name:     "staging-sweeper",
+		Interval: time.Hour,
+		Run:      uploads.SweepStaging,
+	})
+
 	// initialize API server
 	log.Println("Starting api...")
-	easymirrorbackend.InitServer(database)
+	easymirrorbackend.InitServer(database, uploads, m)
+}
+
+// durationFromEnv parses a duration (ie: `720h`) from an env variable, falling back to a default
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
 }
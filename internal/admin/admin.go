@@ -0,0 +1,217 @@
+// Package admin implements the moderation and operations features behind the `/api/admin` routes.
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/common"
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/easymirror/easymirror-backend/internal/session"
+	"github.com/google/uuid"
+)
+
+const (
+	queryLimit = 50
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrForbidden = errors.New("not allowed to manage this user")
+)
+
+// User is what operators see about a user
+type User struct {
+	ID            uuid.UUID  `json:"id"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	Username      string     `json:"username"`
+	Role          auth.Role  `json:"role"`
+	MemberSince   time.Time  `json:"member_since"`
+	SuspendedAt   *time.Time `json:"suspended_at"`
+	MirrorCount   int        `json:"mirror_count"`
+}
+
+// Mirror is a mirror link with everything operators need to inspect it
+type Mirror struct {
+	*mirrorlink.ShareLink
+	OwnerID     uuid.UUID         `json:"owner_id"`
+	WorkspaceID *uuid.UUID        `json:"workspace_id,omitempty"`
+	Files       []mirrorlink.File `json:"files"`
+}
+
+const selectUser = `
+	SELECT u.id, u.email, u.verified_at IS NOT NULL, u.username, u.role, u.member_since, u.suspended_at,
+	(SELECT COUNT(*) FROM mirroring_links m WHERE m.created_by_id=u.id)
+	FROM users u
+`
+
+// SearchUsers returns users whose ID, email or username contains the query, newest first.
+// An empty query lists all users.
+func SearchUsers(ctx context.Context, db *db.Database, query string, pageNum int) ([]User, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	pattern := "%" + escapeLike(strings.ToLower(strings.TrimSpace(query))) + "%"
+	rows, err := db.PostgresConn.QueryContext(ctx, selectUser+`
+		WHERE u.id::text LIKE ($1) OR LOWER(u.email) LIKE ($1) OR LOWER(u.username) LIKE ($1)
+		ORDER BY u.member_since DESC
+		LIMIT ($2)
+		OFFSET ($3);
+	`, pattern, queryLimit, common.GetPageOffset(queryLimit, pageNum))
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// GetUser returns a single user
+func GetUser(ctx context.Context, db *db.Database, userID string) (*User, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	u, err := scanUser(db.PostgresConn.QueryRowContext(ctx, selectUser+`WHERE u.id=($1);`, id))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+	return u, nil
+}
+
+// SetSuspended suspends or unsuspends a user. Suspending a user logs out all their sessions.
+// Operators can only manage users with a lower role than their own.
+func SetSuspended(ctx context.Context, db *db.Database, actor auth.Role, userID string, suspended bool) error {
+	target, err := GetUser(ctx, db, userID)
+	if err != nil {
+		return err
+	}
+	if !canManage(actor, target.Role) {
+		return ErrForbidden
+	}
+
+	var suspendedAt sql.NullTime
+	if suspended {
+		suspendedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	if _, err = db.PostgresConn.ExecContext(ctx, `UPDATE users SET suspended_at=($1) WHERE id=($2);`, suspendedAt, target.ID); err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if suspended {
+		if _, err = session.RevokeAll(ctx, db, target.ID, ""); err != nil {
+			return fmt.Errorf("RevokeAll error: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetRole changes the role of a user. The new role applies on the user's next token refresh.
+func SetRole(ctx context.Context, db *db.Database, actor auth.Role, userID string, role auth.Role) error {
+	target, err := GetUser(ctx, db, userID)
+	if err != nil {
+		return err
+	}
+	if !canManage(actor, target.Role) || !canManage(actor, role) {
+		return ErrForbidden
+	}
+	if _, err = db.PostgresConn.ExecContext(ctx, `UPDATE users SET role=($1) WHERE id=($2);`, string(role), target.ID); err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetMirror returns any mirror link with its host links and files
+func GetMirror(ctx context.Context, db *db.Database, mirrorID string) (*Mirror, error) {
+	owner, err := mirrorlink.GetOwnership(ctx, db, mirrorID)
+	if errors.Is(err, mirrorlink.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	m := &Mirror{OwnerID: owner.CreatedByID, WorkspaceID: owner.WorkspaceID, ShareLink: &mirrorlink.ShareLink{}}
+	if sl, err := mirrorlink.GetMirror(ctx, db, mirrorID); err == nil {
+		m.ShareLink = sl
+	} else {
+		// Mirrors that were never uploaded to a host have no host links yet
+		id, _ := uuid.Parse(mirrorID)
+		m.ShareLink.ID = id
+	}
+	if m.Files, err = mirrorlink.GetFilesFromMirror(ctx, db, mirrorID); err != nil {
+		return nil, fmt.Errorf("GetFilesFromMirror error: %w", err)
+	}
+	return m, nil
+}
+
+// DeleteMirror deletes any mirror link
+func DeleteMirror(ctx context.Context, db *db.Database, mirrorID string) error {
+	if _, err := uuid.Parse(mirrorID); err != nil {
+		return ErrNotFound
+	}
+	err := mirrorlink.Delete(ctx, db, mirrorID)
+	if errors.Is(err, mirrorlink.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// canManage returns true if an operator with the actor role may manage users with the target role.
+// Admins can manage everyone, everyone else only roles below their own.
+func canManage(actor, target auth.Role) bool {
+	if actor == auth.RoleAdmin {
+		return true
+	}
+	return actor.AtLeast(auth.RoleModerator) && !target.AtLeast(actor)
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans a row selected with `selectUser`
+func scanUser(row scanner) (*User, error) {
+	var (
+		u         User
+		email     sql.NullString
+		username  sql.NullString
+		role      sql.NullString
+		since     sql.NullTime
+		suspended sql.NullTime
+	)
+	if err := row.Scan(&u.ID, &email, &u.EmailVerified, &username, &role, &since, &suspended, &u.MirrorCount); err != nil {
+		return nil, err
+	}
+	u.Email, u.Username, u.MemberSince = email.String, username.String, since.Time
+	u.Role, _ = auth.ParseRole(role.String)
+	if suspended.Valid {
+		u.SuspendedAt = &suspended.Time
+	}
+	return &u, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
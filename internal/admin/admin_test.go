@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestCanManage$ github.com/easymirror/easymirror-backend/internal/admin
+func TestCanManage(t *testing.T) {
+	tests := []struct {
+		Actor, Target auth.Role
+		Expected      bool
+	}{
+		{Actor: auth.RoleAdmin, Target: auth.RoleAdmin, Expected: true},
+		{Actor: auth.RoleAdmin, Target: auth.RoleUser, Expected: true},
+		{Actor: auth.RoleModerator, Target: auth.RoleUser, Expected: true},
+		{Actor: auth.RoleModerator, Target: auth.RoleModerator, Expected: false},
+		{Actor: auth.RoleModerator, Target: auth.RoleAdmin, Expected: false},
+		{Actor: auth.RoleUser, Target: auth.RoleUser, Expected: false},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := canManage(test.Actor, test.Target)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestEscapeLike$ github.com/easymirror/easymirror-backend/internal/admin
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `50\%\_off\\`, escapeLike(`50%_off\`))
+}
@@ -4,15 +4,18 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload"
 	"github.com/easymirror/easymirror-backend/internal/api/v1/router"
 	"github.com/easymirror/easymirror-backend/internal/db"
 	"github.com/easymirror/easymirror-backend/internal/log"
+	"github.com/easymirror/easymirror-backend/internal/mailer"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-// InitServer starts and initializes the API and its routes
-func InitServer(db *db.Database) {
+// InitServer starts and initializes the API and its routes.
+// The upload handler is shared with the background jobs that upload mirrors again.
+func InitServer(db *db.Database, uploads *upload.Handler, m mailer.Mailer) {
 
 	e := echo.New()
 	e.Use(log.NewMiddlewareLogger())
@@ -24,7 +27,7 @@ func InitServer(db *db.Database) {
 	}))
 
 	// Register routes for the server
-	router.Register(e, db)
+	router.Register(e, db, uploads, m)
 
 	// Get the port/address to start the server
 	port := os.Getenv("PORT")
@@ -0,0 +1,57 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/session"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// UpdatePassword is a handler that sets or changes the user's password.
+// Every other session of the user is logged out.
+func (h *Handler) UpdatePassword(c echo.Context) error {
+	// Get the user-id from the JWT token
+	u, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	body := &struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}{}
+	if err = (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = u.SetPassword(ctx, h.Database, body.CurrentPassword, body.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, user.ErrWeakPassword):
+			return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+		case errors.Is(err, user.ErrInvalidCredentials):
+			return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "invalid_credentials"})
+		}
+		log.Println("Failed to update password:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	var current string
+	if token, ok := c.Get("jwt-token").(*jwt.Token); ok {
+		current = auth.SessionID(token)
+	}
+	if _, err = session.RevokeAll(ctx, h.Database, u.ID(), current); err != nil {
+		log.Println("Failed to revoke sessions:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
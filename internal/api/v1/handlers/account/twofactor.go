@@ -0,0 +1,94 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+// EnrollTOTP is a handler that starts TOTP enrollment.
+// It returns the secret and an `otpauth://` URI for authenticator apps.
+func (h *Handler) EnrollTOTP(c echo.Context) error {
+	// Get the user-id from the JWT token
+	u, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	enrollment, err := u.EnrollTOTP(ctx, h.Database)
+	if err != nil {
+		if errors.Is(err, user.ErrTOTPEnabled) {
+			return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "already_enabled"})
+		}
+		log.Println("Failed to enroll TOTP:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "totp": enrollment})
+}
+
+// ConfirmTOTP is a handler that activates TOTP with a code from the authenticator app.
+// It returns the recovery codes, which are only shown once.
+func (h *Handler) ConfirmTOTP(c echo.Context) error {
+	return h.withCode(c, func(ctx context.Context, u user.User, code string) (map[string]any, error) {
+		codes, err := u.ConfirmTOTP(ctx, h.Database, code)
+		return map[string]any{"recovery_codes": codes}, err
+	})
+}
+
+// DisableTOTP is a handler that turns off TOTP after verifying a code
+func (h *Handler) DisableTOTP(c echo.Context) error {
+	return h.withCode(c, func(ctx context.Context, u user.User, code string) (map[string]any, error) {
+		return map[string]any{}, u.DisableTOTP(ctx, h.Database, code)
+	})
+}
+
+// RegenerateRecoveryCodes is a handler that replaces all recovery codes after verifying a code
+func (h *Handler) RegenerateRecoveryCodes(c echo.Context) error {
+	return h.withCode(c, func(ctx context.Context, u user.User, code string) (map[string]any, error) {
+		codes, err := u.RegenerateRecoveryCodes(ctx, h.Database, code)
+		return map[string]any{"recovery_codes": codes}, err
+	})
+}
+
+// withCode reads a `code` from the body, runs fn and maps two-factor errors to responses
+func (h *Handler) withCode(c echo.Context, fn func(ctx context.Context, u user.User, code string) (map[string]any, error)) error {
+	// Get the user-id from the JWT token
+	u, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	body := &struct {
+		Code string `json:"code"`
+	}{}
+	if err = (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	resp, err := fn(ctx, u, body.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidCode):
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_code"})
+		case errors.Is(err, user.ErrTOTPNotEnabled):
+			return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "not_enabled"})
+		case errors.Is(err, user.ErrTOTPEnabled):
+			return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "already_enabled"})
+		}
+		log.Println("Failed two-factor action:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	resp["success"] = true
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/pool"
+	"github.com/labstack/echo/v4"
+)
+
+// ListHostAccounts is a handler that returns the usage and state of the operator's host accounts
+func (h *Handler) ListHostAccounts(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "accounts": h.Uploads.Pools.Status()})
+}
+
+// EnableHostAccount is a handler that puts a disabled host account back into rotation
+func (h *Handler) EnableHostAccount(c echo.Context) error {
+	p := h.Uploads.Pools.Get(c.Param("host"))
+	if p == nil {
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+	}
+	if err := p.Enable(c.Param("id")); err != nil {
+		if errors.Is(err, pool.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+		}
+		log.Println("Error enabling host account:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
@@ -0,0 +1,11 @@
+package admin
+
+import (
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload"
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+type Handler struct {
+	*db.Database
+	Uploads *upload.Handler // Used to re-mirror files
+}
@@ -0,0 +1,24 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+	"github.com/easymirror/easymirror-backend/internal/hosts/limit"
+	"github.com/labstack/echo/v4"
+)
+
+// ListHostLimits is a handler that returns the load and circuit state of every host
+func (h *Handler) ListHostLimits(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "hosts": limit.Status()})
+}
+
+// ListHostProxies is a handler that returns the health of the outbound proxies of every host
+func (h *Handler) ListHostProxies(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "proxies": httpx.Default().ProxyStatus()})
+}
+
+// ListHostMetrics is a handler that returns counters of the requests sent to every host
+func (h *Handler) ListHostMetrics(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "hosts": httpx.Default().Metrics()})
+}
@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/admin"
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/labstack/echo/v4"
+)
+
+// GetMirror is a handler that returns any mirror with its owner, host links and files
+func (h *Handler) GetMirror(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	m, err := admin.GetMirror(ctx, h.Database, c.Param("id"))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, m)
+}
+
+// GetLinkHealth is a handler that returns every probe of the links of a mirror on the hosts, newest first
+func (h *Handler) GetLinkHealth(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := admin.GetMirror(ctx, h.Database, c.Param("id")); err != nil {
+		return adminError(c, err)
+	}
+	checks, err := mirrorlink.GetLinkHistory(ctx, h.Database, c.Param("id"))
+	if err != nil {
+		log.Println("Error getting link history:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "checks": checks})
+}
+
+// Remirror is a handler that forces the files of a mirror to be uploaded to the given sites again.
+// It only works while the staged files of the mirror are still retained, see mirrorlink.Retention, and answers source_files_gone otherwise.
+func (h *Handler) Remirror(c echo.Context) error {
+	body := &struct {
+		Sites       []string `json:"sites"`
+		Split       string   `json:"split"`
+		Archive     string   `json:"archive"`
+		ArchiveOnly bool     `json:"archive_only"`
+		Verify      string   `json:"verify"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+	sites, err := upload.ParseHosts(body.Sites)
+	if err != nil || len(sites) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_sites"})
+	}
+	split, err := hosts.ParseSplitMode(body.Split)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_split"})
+	}
+	archive, err := hosts.ParseArchiveFormat(body.Archive)
+	if err != nil || (body.ArchiveOnly && archive == hosts.ArchiveNone) {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_archive"})
+	}
+	verify, err := hosts.ParseVerifyMode(body.Verify)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_verify"})
+	}
+
+	// Make sure the mirror exists
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	m, err := admin.GetMirror(ctx, h.Database, c.Param("id"))
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	// The key of an encrypted mirror was never stored, so its files can't be encrypted for other sites again
+	encrypted, err := mirrorlink.IsEncrypted(ctx, h.Database, c.Param("id"))
+	if err != nil {
+		log.Println("Error checking encryption:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	} else if encrypted {
+		return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "encrypted_mirror"})
+	}
+
+	// The staged files are needed to upload them again
+	r, err := mirrorlink.GetRetention(ctx, h.Database, c.Param("id"))
+	if err != nil && !errors.Is(err, mirrorlink.ErrNoRetention) {
+		log.Println("Error getting retention:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	} else if err == nil && r.Status == mirrorlink.StagingDeleted {
+		return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "source_files_gone"})
+	}
+
+	// Remirror into the accounts of the user that created the mirror
+	skipped, err := h.Uploads.StartMirror(m.OwnerID, c.Param("id"), sites, upload.Options{Split: split, Archive: archive, ArchiveOnly: body.ArchiveOnly, Verify: verify})
+	if err != nil {
+		if errors.Is(err, upload.ErrNoSourceFiles) {
+			return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "source_files_gone"})
+		} else if errors.Is(err, upload.ErrNothingAccepted) {
+			return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "no_files_accepted", "skipped": skipped})
+		} else if errors.Is(err, upload.ErrNoCapacity) {
+			return c.JSON(http.StatusServiceUnavailable, map[string]any{"success": false, "error": "no_capacity"})
+		}
+		log.Println("Error starting mirror:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusAccepted, map[string]any{"success": true, "skipped": skipped})
+}
+
+// DeleteMirror is a handler that deletes any mirror
+func (h *Handler) DeleteMirror(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := admin.DeleteMirror(ctx, h.Database, c.Param("id")); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
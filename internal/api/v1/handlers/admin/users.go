@@ -0,0 +1,101 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/admin"
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// SearchUsers is a handler that lists users matching the `q` query parameter
+func (h *Handler) SearchUsers(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	users, err := admin.SearchUsers(ctx, h.Database, c.QueryParam("q"), page)
+	if err != nil {
+		log.Println("Error searching users:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, users)
+}
+
+// GetUser is a handler that returns a single user
+func (h *Handler) GetUser(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	u, err := admin.GetUser(ctx, h.Database, c.Param("id"))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, u)
+}
+
+// SuspendUser is a handler that suspends a user and logs them out everywhere
+func (h *Handler) SuspendUser(c echo.Context) error {
+	return h.setSuspended(c, true)
+}
+
+// UnsuspendUser is a handler that lifts a suspension
+func (h *Handler) UnsuspendUser(c echo.Context) error {
+	return h.setSuspended(c, false)
+}
+
+func (h *Handler) setSuspended(c echo.Context, suspended bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := admin.SetSuspended(ctx, h.Database, actorRole(c), c.Param("id"), suspended); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// SetRole is a handler that changes the role of a user
+func (h *Handler) SetRole(c echo.Context) error {
+	body := &struct {
+		Role string `json:"role"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+	role, err := auth.ParseRole(body.Role)
+	if err != nil || body.Role == "" {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_role"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = admin.SetRole(ctx, h.Database, actorRole(c), c.Param("id"), role); err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// actorRole returns the role of the operator making the request
+func actorRole(c echo.Context) auth.Role {
+	token, ok := c.Get("jwt-token").(*jwt.Token)
+	if !ok {
+		return auth.RoleUser
+	}
+	return auth.RoleFromToken(token)
+}
+
+// adminError converts errors from the admin package into responses
+func adminError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, admin.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+	case errors.Is(err, admin.ErrForbidden):
+		return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "forbidden"})
+	}
+	log.Println("Admin error:", err)
+	return c.String(http.StatusInternalServerError, "Internal server error")
+}
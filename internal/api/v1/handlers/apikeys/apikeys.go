@@ -0,0 +1,119 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/apikey"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxNameLength = 60
+	maxExpiryDays = 365
+)
+
+// CreateKey is a handler that creates a new API key for the user.
+// The plain-text key is only returned in this response.
+func (h *Handler) CreateKey(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	// Parse the body
+	body := &struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}{}
+	if err = (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		log.Println("Error binding body:", err)
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+
+	// Validate
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len(body.Name) > maxNameLength {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid name"})
+	}
+	scopes, err := apikey.ParseScopes(body.Scopes)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+	}
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > maxExpiryDays {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid expiry"})
+	}
+	var expiresAt *time.Time
+	if body.ExpiresInDays > 0 {
+		t := time.Now().UTC().Add(time.Duration(body.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &t
+	}
+
+	// Create the key
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	key, secret, err := apikey.Create(ctx, h.Database, user.ID(), body.Name, scopes, expiresAt)
+	if err != nil {
+		if errors.Is(err, apikey.ErrLimitHit) {
+			return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "too_many_keys"})
+		}
+		log.Println("Error creating api key:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	// Return response
+	response := map[string]any{
+		"success": true,
+		"key":     key,
+		"secret":  secret,
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+// ListKeys is a handler that returns all active API keys of the user
+func (h *Handler) ListKeys(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	keys, err := apikey.List(ctx, h.Database, user.ID())
+	if err != nil {
+		log.Println("Error listing api keys:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeKey is a handler that revokes a given API key
+func (h *Handler) RevokeKey(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = apikey.Revoke(ctx, h.Database, user.ID(), c.Param("id")); err != nil {
+		if errors.Is(err, apikey.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+		}
+		log.Println("Error revoking api key:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
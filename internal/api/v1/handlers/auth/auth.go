@@ -1,29 +1,84 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/challenge"
+	"github.com/easymirror/easymirror-backend/internal/session"
 	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-// NewJWT is a handler to issue new JWT Tokens
+// NewChallenge is a handler that returns a challenge that must be solved before calling `NewJWT`
+func (h *Handler) NewChallenge(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ch, err := h.Challenge.Issue(ctx)
+	if err != nil {
+		log.Println("Error issuing challenge:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	return c.JSON(http.StatusOK, ch)
+}
+
+// NewJWT is a handler to issue new JWT Tokens.
+// Every call creates an anonymous user, so a solved challenge from `NewChallenge` is required.
 func (h *Handler) NewJWT(c echo.Context) error {
+	// Verify the challenge
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	response := challenge.Response{
+		Challenge: c.QueryParam("challenge"),
+		Solution:  c.QueryParam("solution"),
+		RemoteIP:  c.RealIP(),
+	}
+	if err := h.Challenge.Verify(ctx, response); err != nil {
+		if errors.Is(err, challenge.ErrMissing) || errors.Is(err, challenge.ErrInvalid) || errors.Is(err, challenge.ErrReplayed) {
+			return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "challenge_failed"})
+		}
+		log.Println("Error verifying challenge:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+
 	// Create new user
 	u, err := user.Create(h.Database)
 	if err != nil {
 		log.Println("Error creating user:", err)
 		return c.String(http.StatusInternalServerError, "Internal Server Error")
 	}
-	jwt, err := auth.GenerateJWT(u.ID().String())
+	return h.issueTokens(ctx, c, u)
+}
+
+// issueTokens starts a new session and generates an access and refresh token for it.
+// The refresh token is set as a cookie and the access token is returned in the body.
+// Suspended users don't get any tokens.
+func (h *Handler) issueTokens(ctx context.Context, c echo.Context, u user.User) error {
+	role, err := u.Role(ctx, h.Database)
+	if err != nil {
+		if errors.Is(err, user.ErrSuspended) {
+			return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "account_suspended"})
+		}
+		log.Println("Error getting role:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	s, err := session.Create(ctx, h.Database, u.ID(), session.MetaFromEcho(c))
+	if err != nil {
+		log.Println("Error creating session:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	jwt, err := auth.GenerateSessionJWT(u.ID().String(), s.ID.String(), role)
 	if err != nil {
 		log.Println("Error generating JWT:", err)
 		return c.String(http.StatusInternalServerError, "Internal Server Error")
 	}
 
-	c.SetCookie(&http.Cookie{Name: auth.RefreshCookieName, Value: jwt.RefreshToken, HttpOnly: true, Path: "/"})
+	setRefreshCookie(c, jwt.RefreshToken)
 	response := map[string]any{
 		"success":      true,
 		"access_token": jwt.AccessToken,
@@ -31,18 +86,61 @@ func (h *Handler) NewJWT(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-// RefreshJWT is a handler to refresh expired access tokens
+// RefreshJWT is a handler to refresh expired access tokens.
+// The session of the refresh token must still be active. Refresh tokens issued before
+// sessions existed never expire and can't be revoked, so they are turned down and the user has to log in again.
 func (h *Handler) RefreshJWT(c echo.Context) error {
 	// Get refresh token from cookie
 	cookie, err := c.Cookie(auth.RefreshCookieName)
 	if err != nil {
 		return c.String(http.StatusBadRequest, "Bad Request")
 	}
+	claims, err := auth.ParseRefreshToken(cookie.Value)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_refresh_token"})
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_refresh_token"})
+	}
+
+	if claims.ID == "" {
+		c.SetCookie(&http.Cookie{Name: auth.RefreshCookieName, HttpOnly: true, Path: "/", MaxAge: -1})
+		return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_refresh_token"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Make sure the session is still active
+	if err = session.Touch(ctx, h.Database, userID, claims.ID, session.MetaFromEcho(c)); err != nil {
+		if errors.Is(err, session.ErrRevoked) || errors.Is(err, session.ErrNotFound) {
+			c.SetCookie(&http.Cookie{Name: auth.RefreshCookieName, HttpOnly: true, Path: "/", MaxAge: -1})
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "session_revoked"})
+		}
+		log.Println("Error refreshing session:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// Look up the role again, so role changes and suspensions apply
+	role, err := user.FromID(userID).Role(ctx, h.Database)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrSuspended):
+			c.SetCookie(&http.Cookie{Name: auth.RefreshCookieName, HttpOnly: true, Path: "/", MaxAge: -1})
+			return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "account_suspended"})
+		case errors.Is(err, user.ErrNotFound):
+			c.SetCookie(&http.Cookie{Name: auth.RefreshCookieName, HttpOnly: true, Path: "/", MaxAge: -1})
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_refresh_token"})
+		}
+		log.Println("Error getting role:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
 
 	// Refresh token
-	newAccess, err := auth.RefreshAccessToken(cookie.Value)
+	newAccess, err := auth.NewAccessToken(claims.Subject, claims.ID, role)
 	if err != nil {
-		// TODO: Handle this error better
+		log.Println("Error generating access token:", err)
 		return c.String(http.StatusInternalServerError, "Internal Server Error")
 	}
 
@@ -53,3 +151,8 @@ func (h *Handler) RefreshJWT(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, response)
 }
+
+// setRefreshCookie stores a refresh token in a cookie
+func setRefreshCookie(c echo.Context, token string) {
+	c.SetCookie(&http.Cookie{Name: auth.RefreshCookieName, Value: token, HttpOnly: true, Path: "/"})
+}
@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/mailer"
+	"github.com/easymirror/easymirror-backend/internal/session"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	verifyEmailMaxAge   = 48 * time.Hour // How long an email verification link is valid for
+	passwordResetMaxAge = time.Hour      // How long a password reset link is valid for
+)
+
+// UpdateEmail is a handler that changes the user's email address and sends a verification link to it
+func (h *Handler) UpdateEmail(c echo.Context) error {
+	// Get the user-id from the JWT token
+	u, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	body := &struct {
+		Email string `json:"email"`
+	}{}
+	if err = (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+	email, err := user.NormalizeEmail(body.Email)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_email"})
+	}
+
+	// Every change sends an email, so it is rate limited like a resend
+	if !h.verificationLimiter.Allow(u.ID().String()) {
+		return c.JSON(http.StatusTooManyRequests, map[string]any{"success": false, "error": "too_many_requests"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = u.SetEmail(ctx, h.Database, email); err != nil {
+		if errors.Is(err, user.ErrEmailTaken) {
+			return c.JSON(http.StatusConflict, map[string]any{"success": false, "error": "email_taken"})
+		}
+		log.Println("Failed to update email:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	// Send the verification link, unless the address was already verified
+	if verified, err := u.EmailVerified(ctx, h.Database); err != nil {
+		log.Println("Error checking email verification:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	} else if verified {
+		return c.JSON(http.StatusOK, map[string]any{"success": true, "email_verified": true})
+	}
+	if err = h.sendVerification(ctx, u, email); err != nil {
+		log.Println("Error sending verification email:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "email_verified": false})
+}
+
+// ResendVerification is a handler that sends a new verification link to the user's email address
+func (h *Handler) ResendVerification(c echo.Context) error {
+	// Get the user-id from the JWT token
+	u, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	email, verified, err := user.Email(ctx, h.Database, u)
+	switch {
+	case errors.Is(err, user.ErrNoEmail):
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "no_email"})
+	case err != nil:
+		log.Println("Error getting email:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	case verified:
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "already_verified"})
+	}
+
+	if !h.verificationLimiter.Allow(u.ID().String()) {
+		return c.JSON(http.StatusTooManyRequests, map[string]any{"success": false, "error": "too_many_requests"})
+	}
+	if err = h.sendVerification(ctx, u, email); err != nil {
+		log.Println("Error sending verification email:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// VerifyEmail is a handler that consumes an email verification link.
+// Like magic links, it is a POST endpoint so email scanners cannot burn the token.
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	body := &struct {
+		Token string `json:"token"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := user.VerifyEmail(ctx, h.Database, body.Token); err != nil {
+		if errors.Is(err, user.ErrInvalidToken) {
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_token"})
+		}
+		log.Println("Error verifying email:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// RequestPasswordReset is a handler that emails a password reset link to a user.
+// It responds the same way whether or not an account with the email exists.
+func (h *Handler) RequestPasswordReset(c echo.Context) error {
+	body := &struct {
+		Email string `json:"email"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+	email, err := user.NormalizeEmail(body.Email)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_email"})
+	}
+
+	// Rate limit per email
+	if !h.passwordResetLimiter.Allow(email) {
+		return c.JSON(http.StatusTooManyRequests, map[string]any{"success": false, "error": "too_many_requests"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	u, err := user.FromEmail(ctx, h.Database, email)
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		return c.JSON(http.StatusOK, map[string]any{"success": true})
+	case err != nil:
+		log.Println("Error looking up user by email:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// Create and send the link
+	token, err := user.IssueToken(ctx, h.Database, u.ID(), auth.PurposePasswordReset, passwordResetMaxAge)
+	if err != nil {
+		log.Println("Error issuing password reset token:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Reset your EasyMirror password",
+		Body: fmt.Sprintf(
+			"Click the link below to choose a new password for your EasyMirror account.\n\n%v\n\nThis link expires in %v minutes and can only be used once. If you did not request it, you can ignore this email.\n",
+			appLink("/auth/reset-password", token),
+			int(passwordResetMaxAge.Minutes()),
+		),
+	}
+	if err = h.Mailer.Send(ctx, msg); err != nil {
+		log.Println("Error sending password reset:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// ConfirmPasswordReset is a handler that sets a new password using a password reset link.
+// All sessions of the user are logged out. The user has to log in again afterwards,
+// so a second factor is not bypassed.
+func (h *Handler) ConfirmPasswordReset(c echo.Context) error {
+	body := &struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	u, err := user.ResetPassword(ctx, h.Database, body.Token, body.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrWeakPassword):
+			return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+		case errors.Is(err, user.ErrInvalidToken):
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_token"})
+		}
+		log.Println("Error resetting password:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	if _, err = session.RevokeAll(ctx, h.Database, u.ID(), ""); err != nil {
+		log.Println("Error revoking sessions:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// sendVerification emails a verification link to a user
+func (h *Handler) sendVerification(ctx context.Context, u user.User, email string) error {
+	token, err := user.IssueToken(ctx, h.Database, u.ID(), auth.PurposeVerifyEmail, verifyEmailMaxAge)
+	if err != nil {
+		return fmt.Errorf("IssueToken error: %w", err)
+	}
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your EasyMirror email address",
+		Body: fmt.Sprintf(
+			"Click the link below to verify your email address.\n\n%v\n\nThis link expires in %v hours. If you did not add this address to an EasyMirror account, you can ignore this email.\n",
+			appLink("/auth/verify-email", token),
+			int(verifyEmailMaxAge.Hours()),
+		),
+	})
+}
@@ -1,7 +1,51 @@
 package auth
 
-import "github.com/easymirror/easymirror-backend/internal/db"
+import (
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth/oidc"
+	"github.com/easymirror/easymirror-backend/internal/challenge"
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/mailer"
+	"github.com/easymirror/easymirror-backend/internal/ratelimit"
+)
+
+const (
+	magicLinkLimit  = 3                // Max number of magic links that can be requested per email...
+	magicLinkWindow = 15 * time.Minute // ...within this window
+	emailLimit      = 3                // Max number of verification or password reset emails per address...
+	emailWindow     = time.Hour        // ...within this window
+	loginLimit      = 10               // Max number of password logins per login and per IP...
+	loginWindow     = 15 * time.Minute // ...within this window
+	mfaLimit        = 5                // Max number of second factor attempts per user and per IP...
+	mfaWindow       = 15 * time.Minute // ...within this window
+)
 
 type Handler struct {
 	*db.Database
+	Mailer    mailer.Mailer
+	Providers *oidc.Registry
+	Challenge challenge.Verifier
+
+	magicLinkLimiter     *ratelimit.Limiter
+	verificationLimiter  *ratelimit.Limiter
+	passwordResetLimiter *ratelimit.Limiter
+	loginLimiter         *ratelimit.Limiter
+	mfaLimiter           *ratelimit.Limiter
+}
+
+// NewHandler returns a new auth handler that sends emails with the given mailer,
+// logs users in with the given identity providers and gates anonymous sign ups with the given verifier
+func NewHandler(db *db.Database, m mailer.Mailer, providers *oidc.Registry, verifier challenge.Verifier) *Handler {
+	return &Handler{
+		Database:             db,
+		Mailer:               m,
+		Providers:            providers,
+		Challenge:            verifier,
+		magicLinkLimiter:     ratelimit.New(magicLinkLimit, magicLinkWindow),
+		verificationLimiter:  ratelimit.New(emailLimit, emailWindow),
+		passwordResetLimiter: ratelimit.New(emailLimit, emailWindow),
+		loginLimiter:         ratelimit.New(loginLimit, loginWindow),
+		mfaLimiter:           ratelimit.New(mfaLimit, mfaWindow),
+	}
 }
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	mfaTokenMaxAge = 5 * time.Minute // How long a user has to enter their second factor
+)
+
+// Login is a handler that logs in a user with a username or email and password.
+//
+// If the user has two-factor authentication enabled, no tokens are issued.
+// Instead, a short-lived `mfa_token` is returned that must be exchanged at `LoginSecondFactor`.
+// Attempts are rate limited per login and per IP.
+func (h *Handler) Login(c echo.Context) error {
+	body := &struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+	if !h.loginLimiter.Allow("ip:"+c.RealIP()) || !h.loginLimiter.Allow("login:"+strings.ToLower(strings.TrimSpace(body.Login))) {
+		return c.JSON(http.StatusTooManyRequests, map[string]any{"success": false, "error": "too_many_requests"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	u, err := user.Login(ctx, h.Database, body.Login, body.Password)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_credentials"})
+		}
+		log.Println("Error logging in:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return h.completeLogin(ctx, c, u)
+}
+
+// completeLogin issues tokens for a user that proved their first factor.
+// If the user has two-factor authentication enabled, an `mfa_token` is returned instead.
+func (h *Handler) completeLogin(ctx context.Context, c echo.Context, u user.User) error {
+	enabled, err := u.TOTPEnabled(ctx, h.Database)
+	if err != nil {
+		log.Println("Error checking 2FA:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	if !enabled {
+		return h.issueTokens(ctx, c, u)
+	}
+
+	token, err := user.IssueToken(ctx, h.Database, u.ID(), auth.PurposeMFA, mfaTokenMaxAge)
+	if err != nil {
+		log.Println("Error generating mfa token:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	response := map[string]any{
+		"success":      true,
+		"mfa_required": true,
+		"mfa_token":    token,
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// LoginSecondFactor is a handler that completes a login by exchanging an `mfa_token`
+// and a valid TOTP or recovery code for tokens.
+//
+// An `mfa_token` can only be used once. A wrong code returns a new one to try again with,
+// as long as the attempts per user and per IP are within their rate limit.
+func (h *Handler) LoginSecondFactor(c echo.Context) error {
+	body := &struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+
+	// Rate limit before the token is used up, so a limited attempt can be tried again later
+	claims, err := auth.ParsePurposeToken(auth.PurposeMFA, body.MFAToken)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_mfa_token"})
+	}
+	if !h.mfaLimiter.Allow("ip:"+c.RealIP()) || !h.mfaLimiter.Allow("user:"+claims.Subject) {
+		return c.JSON(http.StatusTooManyRequests, map[string]any{"success": false, "error": "too_many_requests"})
+	}
+
+	// Use up the MFA token
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	u, err := user.ConsumeToken(ctx, h.Database, auth.PurposeMFA, body.MFAToken)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidToken) {
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_mfa_token"})
+		}
+		log.Println("Error consuming mfa token:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// Validate the second factor
+	if err = u.VerifySecondFactor(ctx, h.Database, body.Code); err != nil {
+		if errors.Is(err, user.ErrInvalidCode) || errors.Is(err, user.ErrTOTPNotEnabled) {
+			token, err := user.IssueToken(ctx, h.Database, u.ID(), auth.PurposeMFA, mfaTokenMaxAge)
+			if err != nil {
+				log.Println("Error generating mfa token:", err)
+				return c.String(http.StatusInternalServerError, "Internal Server Error")
+			}
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_code", "mfa_token": token})
+		}
+		log.Println("Error verifying second factor:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	return h.issueTokens(ctx, c, u)
+}
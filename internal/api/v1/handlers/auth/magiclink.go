@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/mailer"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	magicLinkMaxAge = 15 * time.Minute // How long a magic link is valid for
+	defaultAppURL   = "https://easymirror.io"
+)
+
+// RequestMagicLink is a handler that emails a one-time login link to a user.
+//
+// To avoid leaking which emails are registered, it responds the same way
+// whether or not an account with the email exists.
+func (h *Handler) RequestMagicLink(c echo.Context) error {
+	body := &struct {
+		Email string `json:"email"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+	addr, err := mail.ParseAddress(strings.TrimSpace(body.Email))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_email"})
+	}
+	email := strings.ToLower(addr.Address)
+
+	// Rate limit per email
+	if !h.magicLinkLimiter.Allow(email) {
+		return c.JSON(http.StatusTooManyRequests, map[string]any{"success": false, "error": "too_many_requests"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	u, err := user.FromEmail(ctx, h.Database, email)
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		return c.JSON(http.StatusOK, map[string]any{"success": true})
+	case err != nil:
+		log.Println("Error looking up user by email:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+
+	// Create and send the link
+	token, err := user.IssueToken(ctx, h.Database, u.ID(), auth.PurposeMagicLink, magicLinkMaxAge)
+	if err != nil {
+		log.Println("Error issuing magic link:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Your EasyMirror login link",
+		Body: fmt.Sprintf(
+			"Click the link below to log in to EasyMirror.\n\n%v\n\nThis link expires in %v minutes and can only be used once. If you did not request it, you can ignore this email.\n",
+			appLink("/auth/magic-link", token),
+			int(magicLinkMaxAge.Minutes()),
+		),
+	}
+	if err = h.Mailer.Send(ctx, msg); err != nil {
+		log.Println("Error sending magic link:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// RedeemMagicLink is a handler that exchanges a magic link token for an access and refresh token.
+// It is a POST endpoint so link previews and email scanners cannot burn the token.
+func (h *Handler) RedeemMagicLink(c echo.Context) error {
+	body := &struct {
+		Token string `json:"token"`
+	}{}
+	if err := (&echo.DefaultBinder{}).BindBody(c, body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "bad request"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	u, err := user.ConsumeToken(ctx, h.Database, auth.PurposeMagicLink, body.Token)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidToken) {
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_token"})
+		}
+		log.Println("Error redeeming magic link:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	return h.completeLogin(ctx, c, u)
+}
+
+// appLink returns a link to the frontend with a token in the query
+func appLink(path, token string) string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = defaultAppURL
+	}
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(token)
+}
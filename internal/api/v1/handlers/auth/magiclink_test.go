@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/easymirror/easymirror-backend/internal/auth/oidc"
+	"github.com/easymirror/easymirror-backend/internal/challenge"
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/mailer"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/joho/godotenv"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// Load .env file
+	if err := godotenv.Load("../../../../../.env"); err != nil {
+		log.Println("no env file loaded.")
+	}
+}
+
+// linkToken reads the token out of the link in an email
+var linkToken = regexp.MustCompile(`[?&]token=([^\s&]+)`)
+
+// go test -v -timeout 30s -run ^TestMagicLink$ github.com/easymirror/easymirror-backend/internal/api/v1/handlers/auth
+func TestMagicLink(t *testing.T) {
+	database, err := db.InitDB()
+	if err != nil {
+		t.Fatalf("Error starting database: %v", err)
+	}
+	defer database.CloseConnections()
+
+	// Create a user with an email to log in with
+	u, err := user.Create(database)
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+	defer database.PostgresConn.Exec(`DELETE FROM users WHERE id=($1);`, u.ID())
+	email := u.ID().String() + "@example.com"
+	if err = u.SetEmail(context.Background(), database, email); err != nil {
+		t.Fatalf("Error setting email: %v", err)
+	}
+
+	outbox := &mailer.Outbox{}
+	h := NewHandler(database, outbox, oidc.FromEnv(), challenge.FromEnv())
+	e := echo.New()
+	e.POST("/magic-link", h.RequestMagicLink)
+	e.POST("/magic-link/redeem", h.RedeemMagicLink)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		res := httptest.NewRecorder()
+		e.ServeHTTP(res, req)
+		return res
+	}
+
+	// Request a link, it is only sent by email
+	res := post("/magic-link", `{"email": "`+email+`"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), "token")
+	msg, ok := outbox.Last()
+	if !ok {
+		t.Fatal("No email was sent")
+	}
+	assert.Equal(t, email, msg.To)
+	match := linkToken.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("No link in the email: %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The token logs in once
+	res = post("/magic-link/redeem", `{"token": "`+token+`"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "access_token")
+	res = post("/magic-link/redeem", `{"token": "`+token+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	// Unknown emails get the same answer, and no email
+	res = post("/magic-link", `{"email": "nobody-`+email+`"}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Len(t, outbox.Messages(), 1)
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/auth/oidc"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	oidcStateCookieName = "oidc_state"
+	oidcStateMaxAge     = 10 * time.Minute // How long a user has to log in at the provider
+)
+
+// oidcState is stored in a signed cookie while the user is at the identity provider
+type oidcState struct {
+	jwt.RegisteredClaims
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (s *oidcState) Registered() *jwt.RegisteredClaims { return &s.RegisteredClaims }
+
+// OIDCProviders is a handler that returns the names of all configured identity providers
+func (h *Handler) OIDCProviders(c echo.Context) error {
+	names := h.Providers.Names()
+	sort.Strings(names)
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "providers": names})
+}
+
+// OIDCStart is a handler that sends the user to an identity provider to log in
+func (h *Handler) OIDCStart(c echo.Context) error {
+	provider, err := h.Providers.Get(c.Param("provider"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "unknown_provider"})
+	}
+
+	// Generate the state, nonce and PKCE verifier
+	state := &oidcState{Provider: provider.Name()}
+	if state.State, err = oidc.RandomString(24); err != nil {
+		log.Println("Error generating state:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	if state.Nonce, err = oidc.RandomString(24); err != nil {
+		log.Println("Error generating nonce:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		log.Println("Error generating PKCE:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	state.Verifier = verifier
+
+	// Build the URL
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	authURL, err := provider.AuthCodeURL(ctx, state.State, state.Nonce, challenge)
+	if err != nil {
+		log.Println("Error building auth url:", err)
+		return c.JSON(http.StatusBadGateway, map[string]any{"success": false, "error": "provider_unavailable"})
+	}
+
+	// Remember the state in a signed cookie
+	cookie, err := auth.SignPurposeClaims(auth.PurposeOIDCState, state, oidcStateMaxAge)
+	if err != nil {
+		log.Println("Error signing state:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    cookie,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   int(oidcStateMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback is a handler for the redirect back from an identity provider.
+// It validates the response, links the identity to a user and logs them in.
+func (h *Handler) OIDCCallback(c echo.Context) error {
+	provider, err := h.Providers.Get(c.Param("provider"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "unknown_provider"})
+	}
+	if e := c.QueryParam("error"); e != "" {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": e})
+	}
+
+	// Validate the state
+	cookie, err := c.Cookie(oidcStateCookieName)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "missing_state"})
+	}
+	state := &oidcState{}
+	if err = auth.ParsePurposeClaims(auth.PurposeOIDCState, cookie.Value, state); err != nil ||
+		state.Provider != provider.Name() || state.State != c.QueryParam("state") {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_state"})
+	}
+	c.SetCookie(&http.Cookie{Name: oidcStateCookieName, Path: "/api/v1/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	// Exchange the code
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	claims, err := provider.Exchange(ctx, c.QueryParam("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		log.Println("Error exchanging code:", err)
+		if errors.Is(err, oidc.ErrInvalidIDToken) {
+			return c.JSON(http.StatusUnauthorized, map[string]any{"success": false, "error": "invalid_id_token"})
+		}
+		return c.JSON(http.StatusBadGateway, map[string]any{"success": false, "error": "exchange_failed"})
+	}
+
+	// Link the identity to a user
+	u, err := user.FromIdentity(ctx, h.Database, provider.Name(), claims.Subject, claims.Email, claims.EmailVerified)
+	if err != nil {
+		log.Println("Error linking identity:", err)
+		return c.String(http.StatusInternalServerError, "Internal Server Error")
+	}
+	return h.completeLogin(ctx, c, u)
+}
@@ -0,0 +1,93 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/easymirror/easymirror-backend/internal/vault"
+	"github.com/labstack/echo/v4"
+)
+
+// ListCredentials is a handler that returns the hosts a user stored credentials for.
+// The credentials themselves are never returned.
+func (h *Handler) ListCredentials(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	entries, err := vault.ListCredentials(ctx, h.Database, user.ID())
+	if err != nil {
+		log.Println("Error listing credentials:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// PutCredentials is a handler that stores the user's own credentials for a host.
+// Mirrors to that host will then be uploaded to the user's account.
+func (h *Handler) PutCredentials(c echo.Context) error {
+	if h.Vault == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]any{"success": false, "error": "vault_disabled"})
+	}
+
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	host := c.Param("host")
+	creds := hosts.Credentials{
+		APIKey:   c.FormValue("api_key"),
+		Username: c.FormValue("username"),
+		Password: c.FormValue("password"),
+	}
+	if err = upload.ValidateCredentials(host, creds); err != nil {
+		if errors.Is(err, upload.ErrUnknownHost) {
+			return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "unknown_host"})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": err.Error()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = vault.PutCredentials(ctx, h.Database, h.Vault, user.ID(), host, creds); err != nil {
+		log.Println("Error storing credentials:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// DeleteCredentials is a handler that removes the user's credentials for a host.
+// Mirrors to that host go back to the default account.
+func (h *Handler) DeleteCredentials(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = vault.DeleteCredentials(ctx, h.Database, user.ID(), c.Param("host")); err != nil {
+		if errors.Is(err, vault.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+		}
+		log.Println("Error deleting credentials:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
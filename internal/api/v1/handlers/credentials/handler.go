@@ -0,0 +1,11 @@
+package credentials
+
+import (
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/vault"
+)
+
+type Handler struct {
+	*db.Database
+	Vault *vault.Keyring
+}
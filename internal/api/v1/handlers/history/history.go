@@ -2,17 +2,22 @@ package history
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
 	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/easymirror/easymirror-backend/internal/workspace"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-// GetHistory returns a list of items a user has uploaded
+// GetHistory returns a list of items a user has uploaded.
+// If the workspace query param is set, the items of that workspace are returned instead.
 func (h *Handler) GetHistory(c echo.Context) error {
 	// Get the user-id from the JWT token
 	user, err := user.FromEcho(c)
@@ -31,7 +36,19 @@ func (h *Handler) GetHistory(c echo.Context) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
-	links, err := user.MirrorLinks(ctx, h.Database, pageNum)
+	var links []mirrorlink.MirrorLink
+	if ws := c.QueryParam("workspace"); ws != "" {
+		workspaceID, err := uuid.Parse(ws)
+		if err != nil {
+			return historyError(c, workspace.ErrNotMember)
+		}
+		links, err = user.WorkspaceMirrorLinks(ctx, h.Database, workspaceID, pageNum)
+		if err != nil {
+			return historyError(c, err)
+		}
+		return c.JSON(http.StatusOK, links)
+	}
+	links, err = user.MirrorLinks(ctx, h.Database, pageNum)
 	if err != nil {
 		log.Println("Error getting links:", err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
@@ -66,7 +83,9 @@ func (h *Handler) UpdateHistoryItem(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 	if err = user.UpdateMirrorLinkName(ctx, h.Database, id, name); err != nil {
-		// TODO return response based on error
+		if isPermissionError(err) {
+			return historyError(c, err)
+		}
 		log.Println("Failed to update mirror link name:", err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
 	}
@@ -92,7 +111,9 @@ func (h *Handler) DeleteHistoryItem(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 	if err = user.DeleteMirrorLink(ctx, h.Database, id); err != nil {
-		// TODO return response based on error
+		if isPermissionError(err) {
+			return historyError(c, err)
+		}
 		log.Println("Failed to delete mirror link:", err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
 	}
@@ -119,7 +140,9 @@ func (h *Handler) GetFiles(c echo.Context) error {
 	defer cancel()
 	files, err := user.GetFiles(ctx, h.Database, id)
 	if err != nil {
-		// TODO return response based on error
+		if isPermissionError(err) {
+			return historyError(c, err)
+		}
 		log.Println("Failed to get files in link:", err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
 	}
@@ -127,3 +150,67 @@ func (h *Handler) GetFiles(c echo.Context) error {
 	// Return
 	return c.JSON(http.StatusOK, files)
 }
+
+// MoveHistoryItem moves a history item into a workspace.
+// An empty workspace_id moves it out of its workspace, back to the user that created it.
+func (h *Handler) MoveHistoryItem(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	// Get the ID of the history item and the target workspace
+	id := c.Param("id")
+	var workspaceID *uuid.UUID
+	if ws := c.FormValue("workspace_id"); ws != "" {
+		parsed, err := uuid.Parse(ws)
+		if err != nil {
+			return historyError(c, workspace.ErrNotMember)
+		}
+		workspaceID = &parsed
+	}
+
+	// Move the item
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = user.MoveMirrorLink(ctx, h.Database, id, workspaceID); err != nil {
+		if isPermissionError(err) {
+			return historyError(c, err)
+		}
+		log.Println("Failed to move mirror link:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	// Return response
+	resp := map[string]any{}
+	resp["success"] = true
+	return c.JSON(http.StatusOK, resp)
+}
+
+// isPermissionError returns true if err means the user is not allowed to see or change something
+func isPermissionError(err error) bool {
+	return errors.Is(err, user.ErrMirrorNotFound) ||
+		errors.Is(err, user.ErrForbidden) ||
+		errors.Is(err, workspace.ErrNotMember)
+}
+
+// historyError returns the response for a permission error
+func historyError(c echo.Context, err error) error {
+	resp := map[string]any{}
+	resp["success"] = false
+	switch {
+	case errors.Is(err, user.ErrMirrorNotFound):
+		resp["error"] = "mirror link not found"
+		return c.JSON(http.StatusNotFound, resp)
+	case errors.Is(err, workspace.ErrNotMember):
+		resp["error"] = "workspace not found"
+		return c.JSON(http.StatusNotFound, resp)
+	case errors.Is(err, user.ErrForbidden):
+		resp["error"] = "not allowed"
+		return c.JSON(http.StatusForbidden, resp)
+	}
+	log.Println("Unexpected history error:", err)
+	return c.String(http.StatusInternalServerError, "Internal server error")
+}
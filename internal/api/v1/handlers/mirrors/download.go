@@ -0,0 +1,116 @@
+package mirrors
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/easymirror/easymirror-backend/internal/seal"
+	"github.com/labstack/echo/v4"
+)
+
+// keyHeader is the header the key of an encrypted mirror is sent in.
+// It is never logged nor stored, and only lives for the request.
+const keyHeader = "X-Mirror-Key"
+
+// Download is a handler for incoming `/mirror/:id/download` requests
+//
+// It downloads an encrypted file of a mirror from its host and streams it back decrypted with the key of the request
+func (h *Handler) Download(c echo.Context) error {
+	key, err := seal.ParseKey(c.Request().Header.Get(keyHeader))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_key"})
+	}
+
+	// The request's context, so the download stops when the client goes away
+	ctx := c.Request().Context()
+	f, err := mirrorlink.GetSealedFile(ctx, h.Database, c.Param("id"), c.QueryParam("host"), c.QueryParam("name"))
+	if errors.Is(err, mirrorlink.ErrFileNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+	} else if err != nil {
+		log.Println("Error getting sealed file:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	source := &locationsReader{ctx: ctx, client: httpx.Client(f.Host), locations: f.Locations}
+	defer source.Close()
+	plain := bufio.NewReader(seal.Decrypt(key, seal.Params{ChunkSize: f.ChunkSize, NoncePrefix: f.NoncePrefix}, source))
+
+	// Decrypt the first chunk before answering, so a wrong key can still be told apart from a working download
+	if _, err := plain.Peek(1); err != nil && err != io.EOF {
+		if errors.Is(err, seal.ErrDecrypt) {
+			return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "wrong_key"})
+		}
+		log.Println("Error downloading sealed file:", err)
+		return c.JSON(http.StatusBadGateway, map[string]any{"success": false, "error": "host_unavailable"})
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": f.PlainName}))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(f.PlainSize, 10))
+	return c.Stream(http.StatusOK, echo.MIMEOctetStream, plain)
+}
+
+// locationsReader reads the parts of a file from their URLs one after another
+type locationsReader struct {
+	ctx       context.Context
+	client    *http.Client
+	locations []string
+	body      io.ReadCloser
+}
+
+func (r *locationsReader) Read(b []byte) (int, error) {
+	for {
+		if r.body == nil {
+			if len(r.locations) == 0 {
+				return 0, io.EOF
+			}
+			if err := r.next(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := r.body.Read(b)
+		if err == io.EOF {
+			r.body.Close()
+			r.body = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// next starts to download the next location
+func (r *locationsReader) next() error {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.locations[0], nil)
+	if err != nil {
+		return fmt.Errorf("new request error: %w", err)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do error: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	r.locations, r.body = r.locations[1:], resp.Body
+	return nil
+}
+
+func (r *locationsReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
@@ -0,0 +1,84 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/session"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// ListSessions is a handler that returns all devices the user is logged in on
+func (h *Handler) ListSessions(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	sessions, err := session.List(ctx, h.Database, user.ID(), currentSession(c))
+	if err != nil {
+		log.Println("Error listing sessions:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession is a handler that logs out a given session.
+// Access tokens that were already issued for it stay valid until they expire.
+func (h *Handler) RevokeSession(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = session.Revoke(ctx, h.Database, user.ID(), c.Param("id")); err != nil {
+		if errors.Is(err, session.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+		}
+		log.Println("Error revoking session:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// RevokeOtherSessions is a handler that logs out every session except the current one
+func (h *Handler) RevokeOtherSessions(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	n, err := session.RevokeAll(ctx, h.Database, user.ID(), currentSession(c))
+	if err != nil {
+		log.Println("Error revoking sessions:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "revoked": n})
+}
+
+// currentSession returns the session ID of the access token used for the request
+func currentSession(c echo.Context) string {
+	token, ok := c.Get("jwt-token").(*jwt.Token)
+	if !ok {
+		return ""
+	}
+	return auth.SessionID(token)
+}
@@ -0,0 +1,101 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/pool"
+	"github.com/easymirror/easymirror-backend/internal/vault"
+	"github.com/google/uuid"
+)
+
+// ErrNoCapacity is returned when none of the operator's accounts on a host has quota left
+var ErrNoCapacity = errors.New("no host account with enough quota left")
+
+// login is how a mirror job logs in to a host
+type login struct {
+	hosts.Credentials
+	lease *pool.Lease // Set when an account of the operator's pool is used
+}
+
+// done reports the result of the upload to the pool the account came from, if any
+func (l login) done(uploaded int64, err error) {
+	if l.lease != nil {
+		l.lease.Done(uploaded, err)
+	}
+}
+
+// siteLogins returns how to log in to each of the sites of a plan, to upload the files planned for it
+func (h *Handler) siteLogins(ctx context.Context, userID uuid.UUID, plan map[mirrorHost][]hosts.File) (map[mirrorHost]login, error) {
+	logins := make(map[mirrorHost]login, len(plan))
+	for site, files := range plan {
+		l, err := h.hostLogin(ctx, userID, site, hosts.TotalSize(files))
+		if err != nil {
+			// Give back the accounts that were already picked
+			for _, picked := range logins {
+				picked.done(0, nil)
+			}
+			return nil, fmt.Errorf("%v: %w", site, err)
+		}
+		logins[site] = l
+	}
+	return logins, nil
+}
+
+// hostLogin returns the credentials the user stored for a host in the vault.
+// Users without stored credentials upload to an account from the operator's pool.
+func (h *Handler) hostLogin(ctx context.Context, userID uuid.UUID, host mirrorHost, size int64) (login, error) {
+	if h.Vault != nil {
+		creds, err := vault.GetCredentials(ctx, h.Database, h.Vault, userID, string(host))
+		if !errors.Is(err, vault.ErrNotFound) {
+			return login{Credentials: creds}, err
+		}
+	}
+
+	p := h.Pools.Get(string(host))
+	if p == nil {
+		return login{}, nil // The operator has no account here, upload anonymously
+	}
+	lease, err := p.Next(size)
+	if errors.Is(err, pool.ErrExhausted) {
+		return login{}, ErrNoCapacity
+	} else if err != nil {
+		return login{}, err
+	}
+	return login{Credentials: lease.Credentials, lease: lease}, nil
+}
+
+// defaultAccounts returns the operator's accounts from the single account env variables.
+// They are used when no HOST_ACCOUNTS_FILE is configured.
+func defaultAccounts() []pool.Account {
+	var accounts []pool.Account
+	add := func(host mirrorHost, creds hosts.Credentials) {
+		if creds != (hosts.Credentials{}) {
+			accounts = append(accounts, pool.Account{ID: string(host) + "-default", Host: string(host), Credentials: creds})
+		}
+	}
+	add(BunkrHost, hosts.Credentials{APIKey: os.Getenv("BUNKR_API_KEY")})
+	add(PixelDrainHost, hosts.Credentials{APIKey: os.Getenv("PIXELDRAIN_API_KEY")})
+	add(CyberfileHost, hosts.Credentials{Username: os.Getenv("CYBERFILE_USERNAME"), Password: os.Getenv("CYBERFILE_PASSWORD")})
+	return accounts
+}
+
+// ValidateCredentials makes sure credentials have what a host needs to log in
+func ValidateCredentials(host string, creds hosts.Credentials) error {
+	switch mirrorHost(host) {
+	case BunkrHost, PixelDrainHost, GofileHost:
+		if creds.APIKey == "" {
+			return errors.New("api_key is required")
+		}
+	case CyberfileHost:
+		if creds.Username == "" || creds.Password == "" {
+			return errors.New("username and password are required")
+		}
+	default:
+		return ErrUnknownHost
+	}
+	return nil
+}
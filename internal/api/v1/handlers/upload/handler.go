@@ -2,30 +2,48 @@ package upload
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/hosts/pool"
+	"github.com/easymirror/easymirror-backend/internal/vault"
 )
 
 type Handler struct {
 	*db.Database
 	S3Client *s3.Client
+	Vault    *vault.Keyring // Decrypts the host credentials of users, nil if the vault is not configured
+	Pools    pool.Pools     // The operator's host accounts, used for users without their own credentials
+
+	MaxRetention time.Duration // How long staged files are kept at most, see mirrorlink.Retention
 }
 
-// NewHandler returns a new upload handler with a S3Client
-func NewHandler(db *db.Database) *Handler {
+// NewHandler returns a new upload handler with a S3Client.
+// The keyring decrypts the host credentials users stored in the vault, it is nil if the vault is not configured.
+func NewHandler(db *db.Database, keyring *vault.Keyring) (*Handler, error) {
 
 	// Using the SDK's default configuration, loading additional config
 	// and credentials values from the environment variables, shared
 	// credentials, and shared configuration files
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("LoadDefaultConfig error: %w", err)
+	}
+
+	pools, err := pool.FromEnv(pool.DBStore{Database: db}, defaultAccounts())
+	if err != nil {
+		return nil, fmt.Errorf("pool.FromEnv error: %w", err)
 	}
 
 	return &Handler{
 		Database: db,
 		S3Client: s3.NewFromConfig(cfg),
-	}
+		Vault:    keyring,
+		Pools:    pools,
+
+		MaxRetention: maxRetentionFromEnv(),
+	}, nil
 }
@@ -2,20 +2,32 @@ package upload
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/easymirror/easymirror-backend/internal/authz"
 	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
 	"github.com/easymirror/easymirror-backend/internal/hosts/bunkr"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+	"github.com/easymirror/easymirror-backend/internal/hosts/limit"
 	"github.com/easymirror/easymirror-backend/internal/hosts/pixeldrain"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/easymirror/easymirror-backend/internal/seal"
 	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -28,9 +40,26 @@ const (
 	CyberfileHost  mirrorHost = "cyberfile"
 )
 
+var (
+	ErrNoSourceFiles   = errors.New("no source files left to mirror")
+	ErrNothingAccepted = errors.New("none of the hosts takes any of the files")
+	ErrUnknownHost     = errors.New("unknown host")
+)
+
+// Options change how the files of a mirror are uploaded
+type Options struct {
+	Split       hosts.SplitMode      // How to split files that are too large for a host into parts, hosts.SplitNone skips them
+	Archive     hosts.ArchiveFormat  // Also packs the files into a single archive, unless it is hosts.ArchiveNone
+	ArchiveOnly bool                 // Uploads the archive in place of the files
+	Key         []byte               // Encrypts the files with the key before they are uploaded, see package seal
+	Verify      hosts.VerifyMode     // Checks the uploads against the originals, corrupt files are uploaded again a few times
+	Retention   mirrorlink.Retention // How long the staged files are kept afterwards for later mirrors, an empty one keeps the mirror's retention
+}
+
 const (
-	maxMirrorTasks = 3 // The max number of gorountines when mirroring
-	taskTimeout    = 1 * time.Hour
+	maxMirrorTasks   = 3 // The max number of gorountines when mirroring
+	taskTimeout      = 1 * time.Hour
+	maxVerifyRetries = 2 // Times corrupt files are uploaded again
 )
 
 // Mirror handles incoming PUT requests for mirroring sites.
@@ -44,96 +73,621 @@ func (h *Handler) Mirror(c echo.Context) error {
 
 	// Parse the body
 	body := &struct {
-		MirrorID string       `json:"id"`
-		Sites    []mirrorHost `json:"sites"`
+		MirrorID    string       `json:"id"`
+		Sites       []mirrorHost `json:"sites"`
+		Split       string       `json:"split"`          // "bytes" or "zip" to split files that are too large for a host
+		Archive     string       `json:"archive"`        // "zip" or "tar.zst" to also upload the files as a single archive
+		ArchiveOnly bool         `json:"archive_only"`   // Upload the archive in place of the files
+		Encrypt     bool         `json:"encrypt"`        // Encrypt the files before they are uploaded
+		Key         string       `json:"key"`            // Key to encrypt with, to keep the key of an earlier mirror of the files
+		Verify      string       `json:"verify"`         // "full" or "sample" to check the uploads against the originals
+		Retention   string       `json:"retention"`      // "immediate", "days" or "until_success" to keep the staged files for later mirrors
+		Days        int          `json:"retention_days"` // Days to keep the staged files with the "days" retention
 	}{}
 	err = (&echo.DefaultBinder{}).BindBody(c, &body)
 	if err != nil {
 		log.Println("Error binding body: ", err)
 		return err
 	}
-	fmt.Println(user) // TODO: Delete this
-	// TODO Validate user has access to mirror ID
-
-	// Get files from AWS S3 bucket
-	files, err := getFilesInS3Dir(h.S3Client, body.MirrorID)
+	split, err := hosts.ParseSplitMode(body.Split)
 	if err != nil {
-		log.Println("Error getting folder:", err)
-		return c.String(http.StatusInternalServerError, "Internal server error")
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_split"})
+	}
+	archive, err := hosts.ParseArchiveFormat(body.Archive)
+	if err != nil || (body.ArchiveOnly && archive == hosts.ArchiveNone) {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_archive"})
+	}
+	verify, err := hosts.ParseVerifyMode(body.Verify)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_verify"})
+	}
+	retention, err := mirrorlink.ParseRetention(body.Retention, body.Days, h.MaxRetention)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_retention"})
+	}
+	var key []byte
+	if body.Key != "" {
+		if key, err = seal.ParseKey(body.Key); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_key"})
+		}
+	} else if body.Encrypt {
+		if key, err = seal.NewKey(); err != nil {
+			log.Println("Error creating key:", err)
+			return c.String(http.StatusInternalServerError, "Internal server error")
+		}
 	}
 
-	// Generate presigned URLs for each file
-	presignedLinks := genPresignURIs(h.S3Client, files, body.MirrorID)
+	// Make sure the user is allowed to mirror the files
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = authz.CheckMirror(ctx, h.Database, user.ID(), body.MirrorID, authz.Mirror); err != nil {
+		return authzError(c, err)
+	}
 
 	// Mirror the files
-	go mirrorFiles(h.Database, h.S3Client, body.MirrorID, body.Sites, presignedLinks)
+	skipped, err := h.StartMirror(user.ID(), body.MirrorID, body.Sites, Options{Split: split, Archive: archive, ArchiveOnly: body.ArchiveOnly, Key: key, Verify: verify, Retention: retention})
+	if err != nil {
+		if errors.Is(err, ErrNoSourceFiles) {
+			return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "no_files"})
+		} else if errors.Is(err, ErrNothingAccepted) {
+			return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "no_files_accepted", "skipped": skipped})
+		} else if errors.Is(err, ErrNoCapacity) {
+			return c.JSON(http.StatusServiceUnavailable, map[string]any{"success": false, "error": "no_capacity"})
+		}
+		log.Println("Error starting mirror:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
 
 	// Return Response
 	response := map[string]any{
 		"success":   true,
 		"mirror_id": body.MirrorID,
+		"skipped":   skipped,
+	}
+	if key != nil {
+		// The key is only ever handed to the user, the share URL carries it in its fragment so it never reaches a server
+		response["key"] = seal.EncodeKey(key)
+		response["share_fragment"] = "key=" + seal.EncodeKey(key)
 	}
 	return c.JSON(http.StatusOK, response)
 }
 
-// mirrorFiles uploads files to the users other sites
-func mirrorFiles(db *db.Database, s3client *s3.Client, mirrorID string, sites []mirrorHost, sourceURIs []string) {
-	// Make sure sites are unique so we only upload once to the host
-	siteMap := map[mirrorHost]bool{}
-	for _, chosen := range sites {
-		siteMap[chosen] = true
+// StartMirror starts mirroring the files of a given mirror ID to other sites in the background, with the user's vault accounts or the operator's pool.
+// It returns the files each host turns down, which are skipped there, or ErrNoSourceFiles if the staged files are gone.
+func (h *Handler) StartMirror(userID uuid.UUID, mirrorID string, sites []mirrorHost, opts Options) ([]hosts.Skip, error) {
+	// Get files from AWS S3 bucket
+	files, err := getFilesInS3Dir(h.S3Client, mirrorID)
+	if err != nil {
+		return nil, fmt.Errorf("getFilesInS3Dir error: %w", err)
 	}
 
-	// Start TX
-	tx, err := db.PostgresConn.Begin()
+	// Look up the names of the files, the objects are only keyed by ID
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	records, err := mirrorlink.GetFilesFromMirror(ctx, h.Database, mirrorID)
 	if err != nil {
-		log.Println("Error creating transaction:", err)
-		return
+		return nil, fmt.Errorf("GetFilesFromMirror error: %w", err)
+	}
+	sizes := make(map[string]int64, len(files))
+	for _, obj := range files {
+		if obj.Key != nil && obj.Size != nil {
+			sizes[*obj.Key] = *obj.Size
+		}
+	}
+	byKey := make(map[string]mirrorlink.File, len(records))
+	for _, r := range records {
+		key := objectKey(mirrorID, r.ID)
+		byKey[key] = r
+
+		// Presigned uploads only learn their size once the object is in the bucket
+		if size, ok := sizes[key]; ok && size != r.SizeBytes {
+			if err := mirrorlink.SetFileSize(ctx, h.Database, r.ID, size); err != nil {
+				log.Println("Error updating file size:", err)
+			}
+		}
+	}
+
+	// Generate presigned URLs for each file
+	sources := genPresignURIs(h.S3Client, files, mirrorID, byKey)
+	if len(sources) == 0 {
+		return nil, ErrNoSourceFiles
+	}
+	if opts.Archive != hosts.ArchiveNone {
+		archive := hosts.ArchiveFile(mirrorID, opts.Archive, sources)
+		if opts.ArchiveOnly {
+			sources = []hosts.File{archive}
+		} else {
+			sources = append(sources, archive)
+		}
+	}
+	if opts.Key != nil {
+		for i, f := range sources {
+			if sources[i], err = hosts.Encrypt(f, opts.Key); err != nil {
+				return nil, fmt.Errorf("Encrypt error: %w", err)
+			}
+		}
+	}
+
+	// Check the files against every host before queueing anything
+	hostNames := make([]string, len(sites))
+	for i, site := range sites {
+		hostNames[i] = string(site)
+	}
+	planned, skipped := hosts.Plan(hostNames, sources, opts.Split)
+	if len(planned) == 0 {
+		return skipped, ErrNothingAccepted
+	}
+	plan := make(map[mirrorHost][]hosts.File, len(planned))
+	for host, files := range planned {
+		plan[mirrorHost(host)] = files
+	}
+
+	// Pick the accounts to upload with
+	logins, err := h.siteLogins(ctx, userID, plan)
+	if err != nil {
+		return skipped, err
+	}
+
+	// Keep the staged files until the upload finished
+	retention, err := h.stagingRetention(ctx, mirrorID, opts.Retention)
+	if err != nil {
+		return skipped, err
+	}
+	if err = mirrorlink.StartStaging(ctx, h.Database, mirrorID, retention); err != nil {
+		return skipped, fmt.Errorf("StartStaging error: %w", err)
+	}
+
+	go mirrorFiles(h.Database, mirrorID, logins, plan, opts.Verify, func(succeeded bool) { h.settleStaging(mirrorID, succeeded) })
+	return skipped, nil
+}
+
+// ListHosts is a handler that returns what each host accepts, so clients can warn about files before mirroring
+func (h *Handler) ListHosts(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{"success": true, "hosts": hosts.All()})
+}
+
+// ParseHosts converts a list of host names into hosts that can be mirrored to
+func ParseHosts(names []string) ([]mirrorHost, error) {
+	hosts := make([]mirrorHost, 0, len(names))
+	for _, name := range names {
+		switch h := mirrorHost(name); h {
+		case GofileHost, BunkrHost, PixelDrainHost, CyberfileHost:
+			hosts = append(hosts, h)
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownHost, name)
+		}
 	}
+	return hosts, nil
+}
 
-	// Delete from AWS S3 when done
-	defer deleteFromS3(s3client, mirrorID)
+// mirrorFiles uploads the planned files to the users other sites, logging in with the given account for each site.
+// What is recorded about each site is committed in a transaction of its own, so a site that fails does not lose
+// the records of the others. settle is called once it is done, with whether every site took its files.
+func mirrorFiles(db *db.Database, mirrorID string, sites map[mirrorHost]login, plan map[mirrorHost][]hosts.File, verify hosts.VerifyMode, settle func(succeeded bool)) {
+	// Apply the retention of the staged files when done
+	var succeeded bool
+	defer func() { settle(succeeded) }()
 
 	// Begin the mirroring process
 	ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
 	defer cancel()
+	track(plan)
+	hashFiles(ctx, db, plan)
 	var wg sync.WaitGroup
+	var failed atomic.Bool
 	sem := make(chan int, maxMirrorTasks)
-	for host := range siteMap {
+	for host, l := range sites {
+		host, l, sources := host, l, plan[host] // Captured by the goroutine below
 		wg.Add(1)
 		sem <- 1 // will block if there is MAX ints in sem / until
 
-		switch host {
-		case BunkrHost:
-			go func() {
-				defer wg.Done()
-				defer func() { <-sem }() // removes an int from sem, allowing another to proceed
-				_, err := bunkr.UploadTx(ctx, tx, mirrorID, sourceURIs)
-				if err != nil {
-					log.Println("Error uploading to bunk:", err)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }() // removes an int from sem, allowing another to proceed
+
+			if uploadable(host) != nil {
+				// TODO: Gofile and Cyberfile
+				failed.Store(true)
+				l.done(0, nil)
+				return
+			}
+
+			// Send every request of this upload through the same proxy
+			ctx := httpx.WithSession(ctx, mirrorID+"/"+string(host))
+
+			// Content that is still on the host from an earlier mirror is not uploaded again
+			findRemotes(ctx, db, mirrorID, host, sources)
+
+			// Wait for the host to have room for another upload, or for it to recover
+			release, err := limit.For(string(host)).Acquire(ctx)
+			if err != nil {
+				log.Printf("Gave up waiting for %v: %v", host, err)
+				failed.Store(true)
+				l.done(0, nil)
+				return
+			}
+
+			// Start TX
+			tx, err := db.PostgresConn.Begin()
+			if err != nil {
+				release(nil)
+				log.Println("Error creating transaction:", err)
+				failed.Store(true)
+				l.done(0, nil)
+				return
+			}
+
+			err = uploadTo(ctx, tx, host, l.Credentials, mirrorID, sources)
+			var partial *hosts.UploadError
+			if errors.As(err, &partial) {
+				// Record the files that were uploaded, the others are checked for below
+				log.Printf("Error uploading to %v: %v", host, err)
+				err = nil
+			}
+			if err == nil && verify != hosts.VerifyNone {
+				var checks []mirrorlink.FileCheck
+				sources, checks = verifyFiles(ctx, tx, host, l.Credentials, mirrorID, verify, sources)
+				for _, c := range checks {
+					if c.Status == mirrorlink.CheckCorrupt {
+						failed.Store(true) // The staged files are still needed to fix them
+					}
 				}
-			}()
-		case PixelDrainHost:
-			go func() {
-				defer wg.Done()
-				defer func() { <-sem }() // removes an int from sem, allowing another to proceed
-				_, err := pixeldrain.UploadTX(ctx, tx, mirrorID, sourceURIs)
-				if err != nil {
-					log.Println("Error uploading to pixel drain:", err)
+				err = recordChecks(tx, mirrorID, checks)
+			}
+			if err == nil {
+				err = recordParts(tx, host, sources)
+			}
+			if err == nil {
+				err = recordArchives(tx, mirrorID, host, sources)
+			}
+			if err == nil {
+				err = recordSealedFiles(tx, mirrorID, host, sources)
+			}
+			if err == nil {
+				err = recordRemotes(tx, host, sources)
+			}
+			release(err)
+			if err != nil {
+				tx.Rollback()
+				log.Printf("Error uploading to %v: %v", host, err)
+				failed.Store(true)
+				l.done(0, err)
+				return
+			}
+
+			// Save/Commit the mirror link of the host to the `host_links` table
+			if err = tx.Commit(); err != nil {
+				log.Printf("Error committing tx of %v: %v", host, err)
+				failed.Store(true)
+			}
+			if missing := notUploaded(sources); len(missing) > 0 {
+				log.Printf("%v files did not reach %v: %v", len(missing), host, strings.Join(missing, ", "))
+				failed.Store(true) // The staged files are still needed to upload them again
+			}
+			l.done(uploadedSize(sources), nil)
+		}()
+	}
+	wg.Wait() // Wait for all tasks to be finished
+	succeeded = !failed.Load()
+}
+
+// uploadable returns ErrUnknownHost for hosts uploadTo can't upload to yet
+func uploadable(host mirrorHost) error {
+	switch host {
+	case BunkrHost, PixelDrainHost:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownHost, host)
+}
+
+// uploadTo uploads files to a host with a given TX, but does NOT commit it
+func uploadTo(ctx context.Context, tx *sql.Tx, host mirrorHost, creds hosts.Credentials, mirrorID string, files []hosts.File) error {
+	var err error
+	switch host {
+	case BunkrHost:
+		_, err = bunkr.UploadTx(ctx, tx, creds, mirrorID, files)
+	case PixelDrainHost:
+		_, err = pixeldrain.UploadTX(ctx, tx, creds, mirrorID, files)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnknownHost, host)
+	}
+	return err
+}
+
+// verifyFiles compares the files uploaded to a host with their originals, and uploads them again while some are corrupt.
+// It returns the files of the last upload, with the results of their checks.
+func verifyFiles(ctx context.Context, tx *sql.Tx, host mirrorHost, creds hosts.Credentials, mirrorID string, mode hosts.VerifyMode, files []hosts.File) ([]hosts.File, []mirrorlink.FileCheck) {
+	for attempt := 1; ; attempt++ {
+		checks, corrupt := checkFiles(ctx, host, mode, files, attempt)
+		if len(corrupt) == 0 {
+			return files, checks
+		}
+		if attempt > maxVerifyRetries {
+			log.Printf("Giving up on %v corrupt files on %v", len(corrupt), host)
+			forget(files, corrupt)
+			return files, checks
+		}
+
+		log.Printf("Uploading to %v again, %v files are corrupt", host, len(corrupt))
+		retry := retryFiles(files, corrupt)
+		if err := uploadTo(ctx, tx, host, creds, mirrorID, retry); err != nil {
+			// Keep the last upload, corrupt files and all
+			log.Printf("Error uploading to %v again: %v", host, err)
+			forget(files, corrupt)
+			return files, checks
+		}
+		files = retry
+	}
+}
+
+// checkFiles compares the files uploaded to a host with their originals.
+// It returns the checks of the files, with the names of the ones that are corrupt.
+// Files that were not uploaded, e.g. because their content was reused, or that could not be checked are left out.
+func checkFiles(ctx context.Context, host mirrorHost, mode hosts.VerifyMode, files []hosts.File, attempt int) ([]mirrorlink.FileCheck, map[string]bool) {
+	client := httpx.Client(string(host))
+	checks := []mirrorlink.FileCheck{}
+	corrupt := map[string]bool{}
+	for _, f := range files {
+		err := hosts.Verify(ctx, client, mode, f, httpx.OpenSource)
+		if errors.Is(err, hosts.ErrNotUploaded) {
+			continue
+		}
+		c := mirrorlink.FileCheck{
+			Host:      string(host),
+			Name:      f.DisplayName(),
+			Status:    mirrorlink.CheckVerified,
+			Attempts:  attempt,
+			CheckedAt: time.Now().UTC(),
+		}
+		if errors.Is(err, hosts.ErrCorrupt) {
+			c.Status, c.Detail = mirrorlink.CheckCorrupt, err.Error()
+			corrupt[f.Name] = true
+		} else if err != nil {
+			log.Printf("Error verifying %q on %v: %v", f.DisplayName(), host, err)
+			continue
+		}
+		checks = append(checks, c)
+	}
+	return checks, corrupt
+}
+
+// retryFiles returns the files of a host to upload again because some are corrupt.
+// Files that were verified are reused where the host can, so only the corrupt ones are uploaded again.
+func retryFiles(files []hosts.File, corrupt map[string]bool) []hosts.File {
+	retry := make([]hosts.File, len(files))
+	for i, f := range files {
+		retry[i] = f.Retry()
+		if f.Reusable() {
+			retry[i].Remote.Reuse = f.Remote.ID()
+			if corrupt[f.Name] {
+				retry[i].Remote.Reuse = ""
+			}
+		}
+	}
+	return retry
+}
+
+// forget keeps corrupt files from being reused by later mirrors
+func forget(files []hosts.File, corrupt map[string]bool) {
+	for _, f := range files {
+		if corrupt[f.Name] && f.Remote != nil {
+			f.Remote.SetID("", "")
+		}
+	}
+}
+
+// recordChecks records the checks of the files of a mirror with a given TX, but does NOT commit it
+func recordChecks(tx *sql.Tx, mirrorID string, checks []mirrorlink.FileCheck) error {
+	for _, c := range checks {
+		if err := mirrorlink.AddCheckTx(tx, mirrorID, c); err != nil {
+			return fmt.Errorf("AddCheckTx error: %w", err)
+		}
+	}
+	return nil
+}
+
+// track gives each planned file an upload of its own, to learn where it was uploaded to
+func track(plan map[mirrorHost][]hosts.File) {
+	for _, files := range plan {
+		for i := range files {
+			files[i].Upload = &hosts.Upload{}
+		}
+	}
+}
+
+// hashFiles gets the planned files ready to be recorded on the hosts once they are there, see recordRemotes.
+// Files are hashed while they are uploaded, only the ones headed to a host that can reuse content and
+// that were never hashed are read from the bucket first, once for all hosts, to look them up on the host.
+// Encrypted files are not hashed, a hash of their content would tell what is in them.
+func hashFiles(ctx context.Context, db *db.Database, plan map[mirrorHost][]hosts.File) {
+	sums := map[string]string{}
+	for host, files := range plan {
+		c, ok := hosts.Lookup(string(host))
+		reuse := ok && c.Reuse
+		for i, f := range files {
+			if f.ID == "" || f.Archive != nil || f.Encryption != nil {
+				continue
+			}
+			if f.SHA256 == "" && reuse {
+				sum, ok := sums[f.ID]
+				if !ok {
+					var err error
+					if sum, err = hashFile(ctx, db, f); err != nil {
+						log.Printf("Error hashing %q: %v", f.Name, err)
+					}
+					sums[f.ID] = sum // Files that could not be hashed are uploaded like before
 				}
-			}()
-		case GofileHost:
-		case CyberfileHost:
+				f.SHA256 = sum
+			}
+			f.Remote = &hosts.Remote{}
+			plan[host][i] = f
 		}
 	}
-	wg.Wait() // Wait for all tasks to be finished
+}
+
+// hashFile hashes a file and records its hash
+func hashFile(ctx context.Context, db *db.Database, f hosts.File) (string, error) {
+	fileID, err := uuid.Parse(f.ID)
+	if err != nil {
+		return "", fmt.Errorf("uuid error: %w", err)
+	}
+	sum, err := hosts.Hash(ctx, f, httpx.OpenSource)
+	if err != nil {
+		return "", fmt.Errorf("Hash error: %w", err)
+	}
+	if err = mirrorlink.SetFileHash(ctx, db, fileID, sum); err != nil {
+		return "", fmt.Errorf("SetFileHash error: %w", err)
+	}
+	return sum, nil
+}
 
-	// Save/Commit mirror links to the `host_links` table
-	if err = tx.Commit(); err != nil {
-		log.Println("Error committing tx:", err)
-		tx.Rollback()
+// findRemotes looks up which files are already on a host, for hosts that can reuse them
+func findRemotes(ctx context.Context, db *db.Database, mirrorID string, host mirrorHost, files []hosts.File) {
+	if c, ok := hosts.Lookup(string(host)); !ok || !c.Reuse {
 		return
 	}
+	for _, f := range files {
+		if !f.Reusable() {
+			continue
+		}
+		remoteID, err := mirrorlink.FindHostFile(ctx, db, mirrorID, string(host), f.SHA256)
+		if err != nil {
+			if !errors.Is(err, mirrorlink.ErrFileNotFound) {
+				log.Println("Error finding file on host:", err)
+			}
+			continue
+		}
+		f.Remote.Reuse = remoteID
+	}
+}
+
+// recordRemotes records the hashes of the files that were learnt while they were uploaded, and the IDs the files have
+// on a host, with a given TX, but does NOT commit it, so later mirrors of the same content can reuse them
+func recordRemotes(tx *sql.Tx, host mirrorHost, files []hosts.File) error {
+	for _, f := range files {
+		fileID, err := uuid.Parse(f.ID)
+		if err != nil || f.Remote == nil {
+			continue
+		}
+		sum := f.ContentHash()
+		if sum == "" {
+			continue
+		}
+		if f.SHA256 == "" {
+			if err = mirrorlink.SetFileHashTx(tx, fileID, sum); err != nil {
+				return fmt.Errorf("SetFileHashTx error: %w", err)
+			}
+		}
+		if !f.Recordable() || f.Remote.ID() == "" {
+			continue
+		}
+		if err = mirrorlink.AddHostFileTx(tx, fileID, string(host), f.Remote.ID(), f.Remote.URL()); err != nil {
+			return fmt.Errorf("AddHostFileTx error: %w", err)
+		}
+	}
+	return nil
+}
+
+// reused returns whether the content of a file was already on the host, so it was not uploaded again
+func reused(f hosts.File) bool {
+	return f.Reusable() && f.Remote.Reuse != "" && f.Remote.ID() == f.Remote.Reuse
+}
+
+// uploadedSize returns the combined size of the files that were uploaded, leaving out the ones that were reused
+func uploadedSize(files []hosts.File) int64 {
+	total := hosts.TotalSize(files)
+	for _, f := range files {
+		if reused(f) {
+			total -= f.Size
+		}
+	}
+	return total
+}
+
+// notUploaded returns the names of the files that are not on the host, i.e. that were neither uploaded nor reused
+func notUploaded(files []hosts.File) []string {
+	names := []string{}
+	for _, f := range files {
+		if len(f.Upload.Locations()) == 0 && !reused(f) {
+			names = append(names, f.DisplayName())
+		}
+	}
+	return names
+}
+
+// recordParts records the parts of the files that were split to fit on a host with a given TX, but does NOT commit it
+func recordParts(tx *sql.Tx, host mirrorHost, files []hosts.File) error {
+	for _, f := range files {
+		if f.Split == hosts.SplitNone {
+			continue
+		}
+		fileID, err := uuid.Parse(f.ID)
+		if err != nil {
+			log.Printf("Not recording the parts of %q, it has no file record", f.Name)
+			continue
+		}
+		if err = mirrorlink.AddPartsTx(tx, fileID, string(host), f.Split, f.Parts()); err != nil {
+			return fmt.Errorf("AddPartsTx error: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordArchives records the checksums of the archives uploaded to a host with a given TX, but does NOT commit it.
+// Archives that were not read to the end, e.g. because a file could not be packed, are not recorded.
+func recordArchives(tx *sql.Tx, mirrorID string, host mirrorHost, files []hosts.File) error {
+	for _, f := range files {
+		if f.Archive == nil {
+			continue
+		}
+		sum, size, ok := f.Archive.Checksum()
+		if !ok {
+			continue
+		}
+		err := mirrorlink.AddArchiveTx(tx, mirrorID, mirrorlink.Archive{
+			Host:      string(host),
+			Format:    string(f.Archive.Format),
+			Name:      f.Name,
+			SizeBytes: size,
+			SHA256:    sum,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("AddArchiveTx error: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordSealedFiles records the encrypted files uploaded to a host with a given TX, but does NOT commit it.
+// Files that did not make it to the host are not recorded.
+func recordSealedFiles(tx *sql.Tx, mirrorID string, host mirrorHost, files []hosts.File) error {
+	for _, f := range files {
+		e := f.Encryption
+		if e == nil || len(f.Upload.Locations()) == 0 {
+			continue
+		}
+		sf := mirrorlink.SealedFile{
+			Host:        string(host),
+			Name:        f.Name,
+			PlainName:   e.PlainName,
+			PlainSize:   e.PlainSize,
+			SizeBytes:   f.Size,
+			ChunkSize:   e.Params.ChunkSize,
+			NoncePrefix: e.Params.NoncePrefix,
+			Locations:   f.Upload.Locations(),
+			CreatedAt:   time.Now().UTC(),
+		}
+		if f.Archive != nil {
+			// The size of an archive is only known once it was made
+			_, size, _ := f.Archive.Checksum()
+			sf.PlainSize, sf.SizeBytes = size, e.Params.SealedSize(size)
+		}
+		if err := mirrorlink.AddSealedFileTx(tx, mirrorID, sf); err != nil {
+			return fmt.Errorf("AddSealedFileTx error: %w", err)
+		}
+	}
+	return nil
 }
 
 // getPresignURL creates a presigned URL for a given file key so users can make GET requests to
@@ -158,6 +712,9 @@ func deleteFromS3(s3client *s3.Client, mirrorID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get files: %w", err)
 	}
+	if len(objects) == 0 {
+		return nil // Nothing left
+	}
 	ids := make([]types.ObjectIdentifier, len(objects))
 	for i, obj := range objects {
 		ids[i] = types.ObjectIdentifier{Key: obj.Key}
@@ -188,9 +745,11 @@ func getFilesInS3Dir(s3client *s3.Client, mirrorID string) ([]types.Object, erro
 	return result.Contents, err
 }
 
-// genPresignURIs generates presigned URIs for objects in a given mirror ID
-func genPresignURIs(s3client *s3.Client, files []types.Object, mirrorID string) []string {
-	var presignedLinks []string
+// genPresignURIs generates presigned URIs for objects in a given mirror ID.
+// records maps object keys to the records of the files, which hold the names the files should have on the hosts.
+// Objects that were staged before keys were ID based are named after their key.
+func genPresignURIs(s3client *s3.Client, files []types.Object, mirrorID string, records map[string]mirrorlink.File) []hosts.File {
+	var sources []hosts.File
 	for _, file := range files {
 		// Create presigned URLs for each file in bucket
 		if file.Key == nil {
@@ -206,7 +765,16 @@ func genPresignURIs(s3client *s3.Client, files []types.Object, mirrorID string)
 			log.Println("Error creating presigned url:", err)
 			continue
 		}
-		presignedLinks = append(presignedLinks, url)
+		var id, sum string
+		name := mirrorlink.CleanName(path.Base(*file.Key))
+		if r, ok := records[*file.Key]; ok {
+			id, name, sum = r.ID.String(), r.Name, r.SHA256
+		}
+		var size int64
+		if file.Size != nil {
+			size = *file.Size
+		}
+		sources = append(sources, hosts.File{ID: id, Name: name, URI: url, Size: size, SHA256: sum})
 	}
-	return presignedLinks
+	return sources
 }
@@ -0,0 +1,66 @@
+package upload
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/hoststest"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// Load .env file
+	if err := godotenv.Load("../../../../../.env"); err != nil {
+		log.Println("no env file loaded.")
+	}
+}
+
+// go test -v -timeout 30s -run ^TestMirrorFilesPartial$ github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload
+func TestMirrorFilesPartial(t *testing.T) {
+	// start database
+	database, err := db.InitDB()
+	if err != nil {
+		t.Fatalf("Error starting database: %v", err)
+	}
+	defer database.CloseConnections()
+
+	// The host links of the mirror are recorded against it
+	userID, mirrorID := uuid.New(), uuid.NewString()
+	if _, err := database.PostgresConn.Exec(`INSERT INTO users (id) values (($1))`, userID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.PostgresConn.Exec(`INSERT INTO mirroring_links values (($1), ($2), ($3), ($4), ($5))`, mirrorID, userID, "Partial mirror", time.Now(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := hoststest.NewServer(t)
+	fake := hoststest.NewBunkr(srv)
+	srv.Install(t)
+
+	// One of the files can't be read, so it never reaches the host
+	missing := srv.AddSource("notes.txt", []byte("some notes"))
+	missing.URI += ".missing"
+	plan := map[mirrorHost][]hosts.File{
+		BunkrHost: {srv.AddSource("photo.png", []byte("png bytes")), missing},
+	}
+	sites := map[mirrorHost]login{BunkrHost: {Credentials: hosts.Credentials{APIKey: "token"}}}
+
+	var settled []bool
+	mirrorFiles(database, mirrorID, sites, plan, hosts.VerifyNone, func(succeeded bool) { settled = append(settled, succeeded) })
+	assert.Equal(t, []bool{false}, settled) // The staged files are kept to upload notes.txt again
+
+	albums := fake.Albums()
+	require.Len(t, albums, 1)
+	assert.Equal(t, map[string][]byte{"photo.png": []byte("png bytes")}, albums[0].Files)
+
+	var link string
+	err = database.PostgresConn.QueryRow(`SELECT bunkr FROM host_links WHERE mirror_id=($1)`, mirrorID).Scan(&link)
+	require.NoError(t, err)
+	assert.Equal(t, "https://bunkr.sk/a/fake300000", link) // The file that was uploaded is still recorded
+}
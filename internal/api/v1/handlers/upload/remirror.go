@@ -0,0 +1,59 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+)
+
+var ErrEncryptedMirror = errors.New("the key of an encrypted mirror is not stored, its files can't be uploaded again")
+
+// RemirrorHost uploads the files of a mirror to a host again, e.g. after its link died, into the accounts of the user that created it.
+// The files are split and archived like they were on the host before, as far as that is known.
+// ErrNoSourceFiles is returned if no copy of the files was retained, ErrEncryptedMirror for encrypted mirrors,
+// and ErrUnknownHost for hosts files can't be uploaded to yet.
+func (h *Handler) RemirrorHost(ctx context.Context, mirrorID, host string) error {
+	sites, err := ParseHosts([]string{host})
+	if err != nil {
+		return err
+	} else if err = uploadable(sites[0]); err != nil {
+		return err
+	}
+	encrypted, err := mirrorlink.IsEncrypted(ctx, h.Database, mirrorID)
+	if err != nil {
+		return fmt.Errorf("IsEncrypted error: %w", err)
+	} else if encrypted {
+		return ErrEncryptedMirror
+	}
+	owner, err := mirrorlink.GetOwnership(ctx, h.Database, mirrorID)
+	if err != nil {
+		return fmt.Errorf("GetOwnership error: %w", err)
+	}
+
+	// Upload the files the way they were uploaded to the host before
+	var opts Options
+	splitFiles, err := mirrorlink.GetSplitFiles(ctx, h.Database, mirrorID)
+	if err != nil {
+		return fmt.Errorf("GetSplitFiles error: %w", err)
+	}
+	for _, f := range splitFiles {
+		if f.Host == host {
+			opts.Split = hosts.SplitMode(f.Mode)
+		}
+	}
+	archives, err := mirrorlink.GetArchives(ctx, h.Database, mirrorID)
+	if err != nil {
+		return fmt.Errorf("GetArchives error: %w", err)
+	}
+	for _, a := range archives {
+		if a.Host == host {
+			opts.Archive = hosts.ArchiveFormat(a.Format)
+		}
+	}
+
+	_, err = h.StartMirror(owner.CreatedByID, mirrorID, sites, opts)
+	return err
+}
@@ -0,0 +1,170 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMaxRetention = 30 * (24 * time.Hour) // How long staged files are kept at most by default
+	sweepBatch          = 500                   // Mirrors whose staged files are deleted per sweep
+)
+
+// maxRetentionFromEnv returns how long staged files are kept at most, from `STAGING_MAX_RETENTION` (ie: `720h`)
+func maxRetentionFromEnv() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("STAGING_MAX_RETENTION"))
+	if err != nil || d <= 0 {
+		return defaultMaxRetention
+	}
+	return d
+}
+
+// stagingRetention returns the retention to upload the staged files of a mirror with:
+// the one that was asked for, the one the mirror already has, or the default one
+func (h *Handler) stagingRetention(ctx context.Context, mirrorID string, asked mirrorlink.Retention) (mirrorlink.Retention, error) {
+	if asked.Policy != "" {
+		return asked, nil
+	}
+	r, err := mirrorlink.GetRetention(ctx, h.Database, mirrorID)
+	if errors.Is(err, mirrorlink.ErrNoRetention) {
+		return mirrorlink.DefaultRetention, nil
+	} else if err != nil {
+		return mirrorlink.Retention{}, fmt.Errorf("GetRetention error: %w", err)
+	}
+	return mirrorlink.Retention{Policy: r.Policy, Days: r.Days}, nil
+}
+
+// settleStaging applies the retention of the staged files of a mirror once an upload of them finished.
+// succeeded tells whether every host of the upload took the files.
+// The files are deleted right away if they expired, the sweeper deletes them later otherwise, see SweepStaging.
+// Nothing is deleted while other uploads of the files are still running, the last one to finish settles them.
+func (h *Handler) settleStaging(mirrorID string, succeeded bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	r, uploads, err := mirrorlink.FinishStaging(ctx, h.Database, mirrorID)
+	if errors.Is(err, mirrorlink.ErrNoRetention) {
+		// The mirror was deleted in the meantime
+		if err := deleteFromS3(h.S3Client, mirrorID); err != nil {
+			log.Println("Error deleting staged files:", err)
+		}
+		return
+	} else if err != nil {
+		log.Println("Error finishing staging:", err)
+		return
+	} else if uploads > 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := r.Expiry(now, succeeded, h.MaxRetention)
+	if !expiresAt.After(now) {
+		if err = h.deleteStaging(ctx, mirrorID); err == nil {
+			return
+		}
+		log.Println("Error deleting staged files:", err) // The sweeper tries again
+	}
+	if err = mirrorlink.SetStagingExpiry(ctx, h.Database, mirrorID, expiresAt); err != nil {
+		log.Println("Error setting staging expiry:", err)
+	}
+}
+
+// deleteStaging deletes the staged files of a mirror and records that they are gone
+func (h *Handler) deleteStaging(ctx context.Context, mirrorID string) error {
+	if err := deleteFromS3(h.S3Client, mirrorID); err != nil {
+		return fmt.Errorf("deleteFromS3 error: %w", err)
+	}
+	if err := mirrorlink.MarkStagingDeleted(ctx, h.Database, mirrorID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("MarkStagingDeleted error: %w", err)
+	}
+	return nil
+}
+
+// SweepStaging deletes the staged files of mirrors whose retention expired.
+// Staged files without a retention, e.g. files that were staged but never mirrored or of deleted mirrors,
+// are deleted once they are older than the max retention.
+func (h *Handler) SweepStaging(ctx context.Context) error {
+	now := time.Now().UTC()
+	expired, err := mirrorlink.GetExpiredStaging(ctx, h.Database, now, now.Add(-h.MaxRetention), sweepBatch)
+	if err != nil {
+		return fmt.Errorf("GetExpiredStaging error: %w", err)
+	}
+	var deleted int
+	for _, mirrorID := range expired {
+		if err := h.deleteStaging(ctx, mirrorID); err != nil {
+			log.Printf("Error deleting staged files of %v: %v", mirrorID, err)
+			continue
+		}
+		deleted++
+	}
+
+	// Staged files nothing keeps track of
+	prefixes, err := listStagingPrefixes(ctx, h.S3Client)
+	if err != nil {
+		return fmt.Errorf("listStagingPrefixes error: %w", err)
+	}
+	for _, mirrorID := range prefixes {
+		if _, err := uuid.Parse(mirrorID); err != nil {
+			continue // Not staged by us
+		}
+		_, err := mirrorlink.GetRetention(ctx, h.Database, mirrorID)
+		if err == nil {
+			continue
+		} else if !errors.Is(err, mirrorlink.ErrNoRetention) {
+			return fmt.Errorf("GetRetention error: %w", err)
+		}
+		objects, err := getFilesInS3Dir(h.S3Client, mirrorID)
+		if err != nil {
+			return fmt.Errorf("getFilesInS3Dir error: %w", err)
+		}
+		var newest time.Time
+		for _, obj := range objects {
+			if obj.LastModified != nil && obj.LastModified.After(newest) {
+				newest = *obj.LastModified
+			}
+		}
+		if newest.After(now.Add(-h.MaxRetention)) {
+			continue
+		}
+		if err := deleteFromS3(h.S3Client, mirrorID); err != nil {
+			log.Printf("Error deleting staged files of %v: %v", mirrorID, err)
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted the staged files of %v mirrors", deleted)
+	}
+	return nil
+}
+
+// listStagingPrefixes returns the mirror IDs files are staged under in the S3 bucket
+func listStagingPrefixes(ctx context.Context, s3client *s3.Client) ([]string, error) {
+	var prefixes []string
+	paginator := s3.NewListObjectsV2Paginator(s3client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(os.Getenv("S3_BUCKET_NAME")),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list prefixes: %w", err)
+		}
+		for _, p := range page.CommonPrefixes {
+			if p.Prefix != nil {
+				prefixes = append(prefixes, strings.TrimSuffix(*p.Prefix, "/"))
+			}
+		}
+	}
+	return prefixes, nil
+}
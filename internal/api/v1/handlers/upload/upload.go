@@ -3,21 +3,23 @@ package upload
 import (
 	"bytes"
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/easymirror/easymirror-backend/internal/authz"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
 	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/easymirror/easymirror-backend/internal/workspace"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
@@ -50,16 +52,20 @@ func (h *Handler) Init(c echo.Context) error {
 	// Generate a new mirror link in the database
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	workspaceID, err := h.uploadWorkspace(ctx, c, user.ID())
+	if err != nil {
+		return workspaceError(c, err)
+	}
 	tx, err := h.PostgresConn.BeginTx(ctx, nil)
 	if err != nil {
 		log.Println("Error creating transaction:", err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
 	}
 	_, err = tx.Exec(`
-	INSERT INTO mirroring_links (id, created_by_id, upload_date)
+	INSERT INTO mirroring_links (id, created_by_id, upload_date, workspace_id)
 	VALUES
-	(($1), ($2), ($3));
-`, mirrorID, user.ID(), time.Now().UTC())
+	(($1), ($2), ($3), ($4));
+`, mirrorID, user.ID(), time.Now().UTC(), workspaceID)
 	if err != nil {
 		log.Println("Error creating new mirror link:", err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
@@ -93,6 +99,10 @@ func (h *Handler) PresignUri(c echo.Context) error {
 		// Generate a new mirror link if there is none
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
+		workspaceID, err := h.uploadWorkspace(ctx, c, user.ID())
+		if err != nil {
+			return workspaceError(c, err)
+		}
 		tx, err := h.PostgresConn.BeginTx(ctx, nil)
 		if err != nil {
 			log.Println("Error creating transaction:", err)
@@ -100,10 +110,10 @@ func (h *Handler) PresignUri(c echo.Context) error {
 		}
 		mirrorID = uuid.NewString()
 		_, err = tx.Exec(`
-		INSERT INTO mirroring_links (id, created_by_id, upload_date)
+		INSERT INTO mirroring_links (id, created_by_id, upload_date, workspace_id)
 		VALUES
-		(($1), ($2), ($3));
-	`, mirrorID, user.ID(), time.Now().UTC())
+		(($1), ($2), ($3), ($4));
+	`, mirrorID, user.ID(), time.Now().UTC(), workspaceID)
 		if err != nil {
 			log.Println("Error creating new mirror link:", err)
 			return c.String(http.StatusInternalServerError, "Internal server error")
@@ -113,12 +123,27 @@ func (h *Handler) PresignUri(c echo.Context) error {
 			log.Println("Error committing to database:", err)
 			return c.String(http.StatusInternalServerError, "Internal server error")
 		}
+	} else {
+		// Only allow uploading into mirror links the user has access to
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err = authz.CheckMirror(ctx, h.Database, user.ID(), mirrorID, authz.Upload); err != nil {
+			return authzError(c, err)
+		}
 	}
 
-	// TODO: Validate if the ID exists?
+	// Record the file. The display name is cleaned and made unique,
+	// the object itself is stored under an opaque key so user input never ends up in it.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	file, err := mirrorlink.AddFile(ctx, h.Database, mirrorID, filename, 0)
+	if err != nil {
+		log.Println("Error adding file:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
 
 	// Generate a presign URL
-	presignURL, err := putPresignURL(h.S3Client, presignExp, mirrorID, filename)
+	presignURL, err := putPresignURL(h.S3Client, presignExp, objectKey(mirrorID, file.ID))
 	if err != nil {
 		log.Println("Error creating new presign url:", err)
 		return c.String(http.StatusInternalServerError, "Internal server error")
@@ -130,13 +155,15 @@ func (h *Handler) PresignUri(c echo.Context) error {
 		ValidUntil string `json:"valid_until"`
 	}
 	type Response struct {
-		Success  bool   `json:"success"`
-		MirrorID string `json:"mirror_id"`
-		Upload   Upload `json:"upload"`
+		Success  bool            `json:"success"`
+		MirrorID string          `json:"mirror_id"`
+		File     mirrorlink.File `json:"file"`
+		Upload   Upload          `json:"upload"`
 	}
 	r := &Response{
 		Success:  true,
 		MirrorID: mirrorID,
+		File:     *file,
 		Upload: Upload{
 			URI:        presignURL,
 			ValidUntil: time.Now().Add(presignExp).Format(time.RFC3339),
@@ -205,15 +232,19 @@ func (h *Handler) Upload(c echo.Context) error {
 			continue
 		}
 
-		// Upload to AWS S3
-		if err = uploadToBucket(h.S3Client, srcBytes, mirrorID.String(), file.Filename); err != nil {
-			log.Println("Could not upload file to bucket:", err)
+		// Upload file data to database
+		f, err := mirrorlink.AddFileTx(tx, mirrorID.String(), file.Filename, file.Size)
+		if err != nil {
+			log.Println("Error uploading to database:", err)
 			continue
 		}
 
-		// Upload file data to database
-		if err = addFileData(tx, file, mirrorID.String()); err != nil {
-			log.Println("Error uploading to database:", err)
+		// Upload to AWS S3
+		if err = uploadToBucket(h.S3Client, srcBytes, objectKey(mirrorID.String(), f.ID)); err != nil {
+			log.Println("Could not upload file to bucket:", err)
+			if _, err = tx.Exec(`DELETE FROM files WHERE id=($1);`, f.ID); err != nil {
+				log.Println("Error removing file from database:", err)
+			}
 		}
 	}
 
@@ -234,21 +265,59 @@ func (h *Handler) Upload(c echo.Context) error {
 	return c.JSON(http.StatusOK, resp)
 }
 
-// addFileData adds a file's data to the database
-func addFileData(tx *sql.Tx, file *multipart.FileHeader, mirrorID string) error {
-	_, err := tx.Exec(`
-		INSERT INTO files (id, name, size_bytes, upload_date, mirror_link_id)
-		VALUES
-		(($1), ($2), ($3), ($4), ($5));
-		`, uuid.NewString(), file.Filename, file.Size, time.Now().UTC(), mirrorID)
+// uploadWorkspace returns the workspace a new mirror link should be created in, if any.
+// The user must be at least a member of the workspace to upload to it.
+func (h *Handler) uploadWorkspace(ctx context.Context, c echo.Context, userID uuid.UUID) (*uuid.UUID, error) {
+	ws := c.QueryParam("workspace")
+	if ws == "" {
+		return nil, nil
+	}
+	workspaceID, err := uuid.Parse(ws)
 	if err != nil {
-		return fmt.Errorf("tx.Exec error: %v", err)
+		return nil, workspace.ErrNotMember
 	}
-	return nil
+	role, err := workspace.MemberRole(ctx, h.Database, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !role.AtLeast(workspace.RoleMember) {
+		return nil, workspace.ErrForbidden
+	}
+	return &workspaceID, nil
+}
+
+// workspaceError returns the response for an error from uploadWorkspace
+func workspaceError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, workspace.ErrNotMember):
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "workspace not found"})
+	case errors.Is(err, workspace.ErrForbidden):
+		return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "viewers cannot upload to this workspace"})
+	}
+	log.Println("Error getting workspace role:", err)
+	return c.String(http.StatusInternalServerError, "Internal server error")
+}
+
+// authzError returns the response for an error from authz.CheckMirror
+func authzError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, authz.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "not_found"})
+	case errors.Is(err, authz.ErrForbidden):
+		return c.JSON(http.StatusForbidden, map[string]any{"success": false, "error": "forbidden"})
+	}
+	log.Println("Error checking mirror access:", err)
+	return c.String(http.StatusInternalServerError, "Internal server error")
+}
+
+// objectKey returns the key a file is staged under in the S3 bucket.
+// Keys only contain IDs, the name of the file is kept in the database.
+func objectKey(mirrorID string, fileID uuid.UUID) string {
+	return mirrorID + "/" + fileID.String()
 }
 
 // uploadToBucket uploads a given file to the AWS S3 bucket
-func uploadToBucket(c *s3.Client, srcBytes []byte, mirrorID, fileName string) error {
+func uploadToBucket(c *s3.Client, srcBytes []byte, key string) error {
 	// We use a manager to upload data to an object in a bucket.
 	// The upload manager breaks large data into parts and uploads the parts concurrently.
 	contentBuffer := bytes.NewReader(srcBytes)
@@ -258,7 +327,7 @@ func uploadToBucket(c *s3.Client, srcBytes []byte, mirrorID, fileName string) er
 
 	_, err := uploader.Upload(context.TODO(), &s3.PutObjectInput{
 		Bucket: aws.String(os.Getenv("S3_BUCKET_NAME")),
-		Key:    aws.String(filepath.Join(mirrorID, fileName)),
+		Key:    aws.String(key),
 		Body:   contentBuffer,
 	})
 	if err != nil {
@@ -267,12 +336,12 @@ func uploadToBucket(c *s3.Client, srcBytes []byte, mirrorID, fileName string) er
 	return nil
 }
 
-func putPresignURL(s3client *s3.Client, expiration time.Duration, mirrorID, filename string) (string, error) {
+func putPresignURL(s3client *s3.Client, expiration time.Duration, key string) (string, error) {
 	presignClient := s3.NewPresignClient(s3client)
 	presignedUrl, err := presignClient.PresignPutObject(context.Background(),
 		&s3.PutObjectInput{
 			Bucket: aws.String(os.Getenv("S3_BUCKET_NAME")),
-			Key:    aws.String(filepath.Join(mirrorID, filename)),
+			Key:    aws.String(key),
 		},
 		s3.WithPresignExpires(expiration))
 	if err != nil {
@@ -0,0 +1,7 @@
+package workspaces
+
+import "github.com/easymirror/easymirror-backend/internal/db"
+
+type Handler struct {
+	*db.Database
+}
@@ -0,0 +1,228 @@
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/easymirror/easymirror-backend/internal/workspace"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// CreateWorkspace is a handler that creates a new workspace owned by the user
+func (h *Handler) CreateWorkspace(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ws, err := workspace.Create(ctx, h.Database, user.ID(), c.FormValue("name"))
+	if err != nil {
+		return workspaceError(c, err)
+	}
+	return c.JSON(http.StatusCreated, ws)
+}
+
+// ListWorkspaces is a handler that returns all workspaces the user is a member of
+func (h *Handler) ListWorkspaces(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	workspaces, err := workspace.List(ctx, h.Database, user.ID())
+	if err != nil {
+		log.Println("Error listing workspaces:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	return c.JSON(http.StatusOK, workspaces)
+}
+
+// DeleteWorkspace is a handler that deletes a workspace.
+// Mirror links in the workspace go back to the users that created them.
+func (h *Handler) DeleteWorkspace(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return workspaceError(c, workspace.ErrNotMember)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = workspace.Delete(ctx, h.Database, workspaceID, user.ID()); err != nil {
+		return workspaceError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// ListMembers is a handler that returns all members of a workspace
+func (h *Handler) ListMembers(c echo.Context) error {
+	// Get the user-id from the JWT token
+	user, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return workspaceError(c, workspace.ErrNotMember)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	members, err := workspace.Members(ctx, h.Database, workspaceID, user.ID())
+	if err != nil {
+		return workspaceError(c, err)
+	}
+	return c.JSON(http.StatusOK, members)
+}
+
+// AddMember is a handler that adds a user to a workspace by their verified email address
+func (h *Handler) AddMember(c echo.Context) error {
+	// Get the user-id from the JWT token
+	actor, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return workspaceError(c, workspace.ErrNotMember)
+	}
+	role, err := workspace.ParseRole(c.FormValue("role"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_role"})
+	}
+
+	// Only users that verified their email can be added,
+	// otherwise anyone could claim an address and get invited in its place.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	email, err := user.NormalizeEmail(c.FormValue("email"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_email"})
+	}
+	member, err := user.FromEmail(ctx, h.Database, email)
+	if errors.Is(err, user.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "user_not_found"})
+	} else if err != nil {
+		log.Println("Error getting user by email:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	if verified, err := member.EmailVerified(ctx, h.Database); err != nil {
+		log.Println("Error checking email verification:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	} else if !verified {
+		return c.JSON(http.StatusNotFound, map[string]any{"success": false, "error": "user_not_found"})
+	}
+
+	if err = workspace.AddMember(ctx, h.Database, workspaceID, actor.ID(), member.ID(), role); err != nil {
+		return workspaceError(c, err)
+	}
+	return c.JSON(http.StatusCreated, map[string]any{"success": true, "user_id": member.ID()})
+}
+
+// UpdateMember is a handler that changes the role of a workspace member
+func (h *Handler) UpdateMember(c echo.Context) error {
+	// Get the user-id from the JWT token
+	actor, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	workspaceID, memberID, err := memberParams(c)
+	if err != nil {
+		return workspaceError(c, err)
+	}
+	role, err := workspace.ParseRole(c.FormValue("role"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"success": false, "error": "invalid_role"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = workspace.UpdateMemberRole(ctx, h.Database, workspaceID, actor.ID(), memberID, role); err != nil {
+		return workspaceError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// RemoveMember is a handler that removes a member from a workspace.
+// Members can use it with their own ID to leave a workspace.
+func (h *Handler) RemoveMember(c echo.Context) error {
+	// Get the user-id from the JWT token
+	actor, err := user.FromEcho(c)
+	if err != nil {
+		log.Println("Error getting user from JWT:", err)
+		return c.String(http.StatusInternalServerError, "Internal server error")
+	}
+	workspaceID, memberID, err := memberParams(c)
+	if err != nil {
+		return workspaceError(c, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = workspace.RemoveMember(ctx, h.Database, workspaceID, actor.ID(), memberID); err != nil {
+		return workspaceError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"success": true})
+}
+
+// memberParams parses the workspace and member IDs from the path
+func memberParams(c echo.Context) (uuid.UUID, uuid.UUID, error) {
+	workspaceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, workspace.ErrNotMember
+	}
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, workspace.ErrNotFound
+	}
+	return workspaceID, memberID, nil
+}
+
+// workspaceError returns the response for an error from the workspace package.
+// Users that are not a member can't tell if a workspace exists.
+func workspaceError(c echo.Context, err error) error {
+	resp := map[string]any{"success": false}
+	switch {
+	case errors.Is(err, workspace.ErrNotMember):
+		resp["error"] = "workspace_not_found"
+		return c.JSON(http.StatusNotFound, resp)
+	case errors.Is(err, workspace.ErrNotFound):
+		resp["error"] = "member_not_found"
+		return c.JSON(http.StatusNotFound, resp)
+	case errors.Is(err, workspace.ErrForbidden):
+		resp["error"] = "forbidden"
+		return c.JSON(http.StatusForbidden, resp)
+	case errors.Is(err, workspace.ErrInvalidName):
+		resp["error"] = err.Error()
+		return c.JSON(http.StatusBadRequest, resp)
+	case errors.Is(err, workspace.ErrLimitHit):
+		resp["error"] = "limit_reached"
+		return c.JSON(http.StatusConflict, resp)
+	case errors.Is(err, workspace.ErrIsMember):
+		resp["error"] = "already_member"
+		return c.JSON(http.StatusConflict, resp)
+	}
+	log.Println("Workspace error:", err)
+	return c.String(http.StatusInternalServerError, "Internal server error")
+}
@@ -1,11 +1,18 @@
 package router
 
 import (
+	"context"
 	"errors"
+	"log"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/easymirror/easymirror-backend/internal/apikey"
 	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/user"
+	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 )
@@ -22,13 +29,23 @@ func generateUnauthorizedResponse(c echo.Context, action string) error {
 	return c.JSON(http.StatusUnauthorized, response)
 }
 
-// jwtConfig provides a config middleware for authenticating JWT tokens
-func jwtConfig() echojwt.Config {
+// jwtConfig provides a config middleware for authenticating JWT tokens and API keys.
+// API keys are only accepted when a database is given.
+func jwtConfig(database *db.Database) echojwt.Config {
+	signingKey := []byte(os.Getenv("JWT_ACCESS_SECRET"))
 	return echojwt.Config{
-		SigningKey:    []byte(os.Getenv("JWT_ACCESS_SECRET")),
+		SigningKey:    signingKey,
 		SigningMethod: echojwt.AlgorithmHS256,
-		TokenLookup:   "header:Authorization:Bearer ,cookie:user_session",
+		TokenLookup:   "header:Authorization:Bearer ,header:X-API-Key,cookie:user_session",
 		ContextKey:    "jwt-token",
+		ParseTokenFunc: func(c echo.Context, token string) (interface{}, error) {
+			if apikey.IsKey(token) {
+				return parseAPIKey(c.Request().Context(), database, token)
+			}
+			return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+				return signingKey, nil
+			}, jwt.WithValidMethods([]string{echojwt.AlgorithmHS256}))
+		},
 		// ContinueOnIgnoredError: true, // Set this to `true` so it can go to the correct handler
 		ErrorHandler: func(c echo.Context, err error) error {
 			if errors.Is(err, echojwt.ErrJWTInvalid) {
@@ -41,3 +58,92 @@ func jwtConfig() echojwt.Config {
 		},
 	}
 }
+
+// parseAPIKey validates an API key and converts it into a token usable by the handlers
+func parseAPIKey(ctx context.Context, database *db.Database, token string) (*jwt.Token, error) {
+	if database == nil {
+		return nil, errors.New("api keys are not supported")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	key, err := apikey.Validate(ctx, database, token)
+	if err != nil {
+		return nil, err
+	}
+	return auth.APIKeyToken(key.UserID().String(), key.Scopes), nil
+}
+
+// requireScope makes sure requests authenticated with an API key were granted the given scope.
+// Regular access tokens are always allowed through.
+func requireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := c.Get("jwt-token").(*jwt.Token)
+			if !ok {
+				log.Println("Error with JWT token.")
+				return c.String(http.StatusInternalServerError, "Internal server error")
+			}
+			if auth.IsAPIKey(token) && !auth.HasScope(token, scope) {
+				response := map[string]any{"success": false, "error": "missing_scope", "scope": scope}
+				return c.JSON(http.StatusForbidden, response)
+			}
+			return next(c)
+		}
+	}
+}
+
+// denyAPIKeys blocks requests that were authenticated with an API key.
+// This is used for endpoints that should only be reachable from an interactive session.
+func denyAPIKeys(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if token, ok := c.Get("jwt-token").(*jwt.Token); ok && auth.IsAPIKey(token) {
+			response := map[string]any{"success": false, "error": "api_key_not_allowed"}
+			return c.JSON(http.StatusForbidden, response)
+		}
+		return next(c)
+	}
+}
+
+// requireVerifiedEmail only lets users with a verified email address through.
+// It guards features that need a reachable owner, such as host credentials and billing.
+func requireVerifiedEmail(database *db.Database) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			u, err := user.FromEcho(c)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+			defer cancel()
+			verified, err := u.EmailVerified(ctx, database)
+			if err != nil {
+				log.Println("Error checking email verification:", err)
+				return c.String(http.StatusInternalServerError, "Internal server error")
+			}
+			if !verified {
+				response := map[string]any{"success": false, "error": "email_not_verified"}
+				return c.JSON(http.StatusForbidden, response)
+			}
+			return next(c)
+		}
+	}
+}
+
+// requireRole only lets users with at least the given role through.
+// The role is read from the access token, so role changes apply on the next refresh.
+func requireRole(min auth.Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token, ok := c.Get("jwt-token").(*jwt.Token)
+			if !ok {
+				log.Println("Error with JWT token.")
+				return c.String(http.StatusInternalServerError, "Internal server error")
+			}
+			if auth.IsAPIKey(token) || !auth.RoleFromToken(token).AtLeast(min) {
+				response := map[string]any{"success": false, "error": "forbidden"}
+				return c.JSON(http.StatusForbidden, response)
+			}
+			return next(c)
+		}
+	}
+}
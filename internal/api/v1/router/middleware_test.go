@@ -26,7 +26,7 @@ func init() {
 func TestJWTConfig(t *testing.T) {
 	// Setup server
 	e := echo.New()
-	e.Use(echojwt.WithConfig(jwtConfig()))
+	e.Use(echojwt.WithConfig(jwtConfig(nil)))
 	e.GET("/", func(c echo.Context) error {
 		token, ok := c.Get("jwt-token").(*jwt.Token) // by default token is stored under `user` key
 		if !ok {
@@ -38,7 +38,7 @@ func TestJWTConfig(t *testing.T) {
 	// Test with a valid auth header
 	t.Run("Valid Auth Header", func(t *testing.T) {
 		// Generate a valid JWT Token
-		token, err := auth.GenerateJWT("test_user_id")
+		token, err := auth.GenerateSessionJWT("test_user_id", "test_session_id", auth.RoleUser)
 		if err != nil {
 			t.Fatalf("Error generating JWT: %v", err)
 		}
@@ -84,3 +84,61 @@ func TestJWTConfig(t *testing.T) {
 		assert.NotEmpty(t, res.Header().Get("Authorization"), "Authorization header was not set") // assert that `Authorization` is set in header
 	})
 }
+
+// go test -v -timeout 30s -run ^TestRequireScope$ github.com/easymirror/easymirror-backend/internal/api/v1/router
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Token    *jwt.Token
+		Expected int
+	}{
+		{Name: "API key with scope", Token: auth.APIKeyToken("test_user_id", []string{"upload", "history"}), Expected: http.StatusOK},
+		{Name: "API key without scope", Token: auth.APIKeyToken("test_user_id", []string{"history"}), Expected: http.StatusForbidden},
+		{Name: "Access token", Token: &jwt.Token{Valid: true, Claims: jwt.MapClaims{"sub": "test_user_id"}}, Expected: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			res := httptest.NewRecorder()
+			c := e.NewContext(req, res)
+			c.Set("jwt-token", test.Token)
+
+			handler := requireScope("upload")(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+			if err := handler(c); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.Expected, res.Code)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestRequireRole$ github.com/easymirror/easymirror-backend/internal/api/v1/router
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Token    *jwt.Token
+		Expected int
+	}{
+		{Name: "Admin", Token: &jwt.Token{Valid: true, Claims: jwt.MapClaims{"sub": "test_user_id", "role": "admin"}}, Expected: http.StatusOK},
+		{Name: "Moderator", Token: &jwt.Token{Valid: true, Claims: jwt.MapClaims{"sub": "test_user_id", "role": "moderator"}}, Expected: http.StatusOK},
+		{Name: "User", Token: &jwt.Token{Valid: true, Claims: jwt.MapClaims{"sub": "test_user_id", "role": "user"}}, Expected: http.StatusForbidden},
+		{Name: "No role", Token: &jwt.Token{Valid: true, Claims: jwt.MapClaims{"sub": "test_user_id"}}, Expected: http.StatusForbidden},
+		{Name: "API key", Token: auth.APIKeyToken("test_user_id", []string{"upload"}), Expected: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			res := httptest.NewRecorder()
+			c := e.NewContext(req, res)
+			c.Set("jwt-token", test.Token)
+
+			handler := requireRole(auth.RoleModerator)(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+			if err := handler(c); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.Expected, res.Code)
+		})
+	}
+}
@@ -4,18 +4,29 @@ import (
 	"net/http"
 
 	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/account"
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/admin"
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/apikeys"
 	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/auth"
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/credentials"
 	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/history"
 	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/mirrors"
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/sessions"
 	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload"
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/workspaces"
+	"github.com/easymirror/easymirror-backend/internal/apikey"
+	jwtauth "github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/auth/oidc"
 	"github.com/easymirror/easymirror-backend/internal/build"
+	"github.com/easymirror/easymirror-backend/internal/challenge"
 	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/mailer"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 )
 
-// Register registers all routes for all versions of the API
-func Register(e *echo.Echo, db *db.Database) {
+// Register registers all routes for all versions of the API.
+// Emails, e.g. magic links and password resets, are sent with the given mailer.
+func Register(e *echo.Echo, db *db.Database, uploads *upload.Handler, m mailer.Mailer) {
 	// Start the API groups
 	api := e.Group("/api")
 
@@ -25,35 +36,101 @@ func Register(e *echo.Echo, db *db.Database) {
 		api.GET("/build-info", buildInfo)
 	}
 
-	v1 := api.Group("/v1", echojwt.WithConfig(jwtConfig()))
+	v1 := api.Group("/v1", echojwt.WithConfig(jwtConfig(db)))
 	{
 		// Auth endpounts
-		auth := auth.Handler{Database: db}
+		auth := auth.NewHandler(db, m, oidc.FromEnv(), challenge.FromEnv())
+		api.GET("/v1/auth/challenge", auth.NewChallenge)
 		api.GET("/v1/auth/init", auth.NewJWT)
 		api.GET("/v1/auth/refresh", auth.RefreshJWT)
+		api.POST("/v1/auth/login", auth.Login)
+		api.POST("/v1/auth/login/2fa", auth.LoginSecondFactor)
+		api.POST("/v1/auth/magic-link", auth.RequestMagicLink)
+		api.POST("/v1/auth/magic-link/redeem", auth.RedeemMagicLink)
+		api.POST("/v1/auth/verify-email", auth.VerifyEmail)
+		api.POST("/v1/auth/password-reset", auth.RequestPasswordReset)
+		api.POST("/v1/auth/password-reset/confirm", auth.ConfirmPasswordReset)
+		api.GET("/v1/auth/oidc", auth.OIDCProviders)
+		api.GET("/v1/auth/oidc/:provider", auth.OIDCStart)
+		api.GET("/v1/auth/oidc/:provider/callback", auth.OIDCCallback)
 
 		// Upload endpoints
-		upload := upload.NewHandler(db)
-		v1.GET("/mirror/new", upload.Init)
-		v1.GET("/mirror", upload.PresignUri)
-		v1.PUT("/mirror", upload.Mirror)
+		upload := uploads
+		v1.GET("/mirror/new", upload.Init, requireScope(apikey.ScopeUpload))
+		v1.GET("/mirror", upload.PresignUri, requireScope(apikey.ScopeUpload))
+		v1.PUT("/mirror", upload.Mirror, requireScope(apikey.ScopeUpload))
+		api.GET("/v1/hosts", upload.ListHosts)
 
 		// Account endpoints
 		account := &account.Handler{Database: db}
-		v1.GET("/user", account.GetUserInfo)
-		v1.PATCH("/user/update", account.UpdateUser)
+		v1.GET("/user", account.GetUserInfo, requireScope(apikey.ScopeAccount))
+		v1.PATCH("/user/update", account.UpdateUser, requireScope(apikey.ScopeAccount))
+		v1.PUT("/user/password", account.UpdatePassword, denyAPIKeys)
+		v1.PUT("/user/email", auth.UpdateEmail, denyAPIKeys)
+		v1.POST("/user/email/verify", auth.ResendVerification, denyAPIKeys)
+		v1.POST("/user/2fa/totp", account.EnrollTOTP, denyAPIKeys)
+		v1.POST("/user/2fa/totp/confirm", account.ConfirmTOTP, denyAPIKeys)
+		v1.DELETE("/user/2fa/totp", account.DisableTOTP, denyAPIKeys)
+		v1.POST("/user/2fa/recovery-codes", account.RegenerateRecoveryCodes, denyAPIKeys)
+
+		// API key endpoints
+		keys := &apikeys.Handler{Database: db}
+		v1.GET("/user/api-keys", keys.ListKeys, denyAPIKeys)
+		v1.POST("/user/api-keys", keys.CreateKey, denyAPIKeys)
+		v1.DELETE("/user/api-keys/:id", keys.RevokeKey, denyAPIKeys)
+
+		// Session endpoints
+		sessions := &sessions.Handler{Database: db}
+		v1.GET("/user/sessions", sessions.ListSessions, denyAPIKeys)
+		v1.DELETE("/user/sessions", sessions.RevokeOtherSessions, denyAPIKeys)
+		v1.DELETE("/user/sessions/:id", sessions.RevokeSession, denyAPIKeys)
+
+		// Host credential endpoints
+		credentials := &credentials.Handler{Database: db, Vault: upload.Vault}
+		v1.GET("/user/credentials", credentials.ListCredentials, denyAPIKeys)
+		v1.PUT("/user/credentials/:host", credentials.PutCredentials, denyAPIKeys, requireVerifiedEmail(db))
+		v1.DELETE("/user/credentials/:host", credentials.DeleteCredentials, denyAPIKeys)
 
 		// Mirrors endpoints
 		mirrors := mirrors.Handler{Database: db}
 		api.GET("/v1/mirror/:id", mirrors.GetMirror)
+		api.GET("/v1/mirror/:id/download", mirrors.Download)
 
 		// History Endpoints
 		history := &history.Handler{Database: db}
-		v1.GET("/history", history.GetHistory)
-		v1.GET("/history/:id", history.GetFiles)
-		v1.PATCH("/history/:id", history.UpdateHistoryItem)
-		v1.DELETE("/history/:id", history.DeleteHistoryItem)
+		v1.GET("/history", history.GetHistory, requireScope(apikey.ScopeHistory))
+		v1.GET("/history/:id", history.GetFiles, requireScope(apikey.ScopeHistory))
+		v1.PATCH("/history/:id", history.UpdateHistoryItem, requireScope(apikey.ScopeHistory))
+		v1.DELETE("/history/:id", history.DeleteHistoryItem, requireScope(apikey.ScopeHistory))
+		v1.PUT("/history/:id/workspace", history.MoveHistoryItem, requireScope(apikey.ScopeHistory))
+
+		// Workspace endpoints
+		workspaces := &workspaces.Handler{Database: db}
+		v1.GET("/workspaces", workspaces.ListWorkspaces, denyAPIKeys)
+		v1.POST("/workspaces", workspaces.CreateWorkspace, denyAPIKeys)
+		v1.DELETE("/workspaces/:id", workspaces.DeleteWorkspace, denyAPIKeys)
+		v1.GET("/workspaces/:id/members", workspaces.ListMembers, denyAPIKeys)
+		v1.POST("/workspaces/:id/members", workspaces.AddMember, denyAPIKeys)
+		v1.PATCH("/workspaces/:id/members/:user_id", workspaces.UpdateMember, denyAPIKeys)
+		v1.DELETE("/workspaces/:id/members/:user_id", workspaces.RemoveMember, denyAPIKeys)
 
+		// Admin endpoints
+		admin := &admin.Handler{Database: db, Uploads: upload}
+		adm := api.Group("/admin", echojwt.WithConfig(jwtConfig(db)), requireRole(jwtauth.RoleModerator))
+		adm.GET("/users", admin.SearchUsers)
+		adm.GET("/users/:id", admin.GetUser)
+		adm.POST("/users/:id/suspend", admin.SuspendUser)
+		adm.DELETE("/users/:id/suspend", admin.UnsuspendUser)
+		adm.PUT("/users/:id/role", admin.SetRole, requireRole(jwtauth.RoleAdmin))
+		adm.GET("/mirrors/:id", admin.GetMirror)
+		adm.GET("/mirrors/:id/health", admin.GetLinkHealth)
+		adm.POST("/mirrors/:id/remirror", admin.Remirror, requireRole(jwtauth.RoleAdmin))
+		adm.DELETE("/mirrors/:id", admin.DeleteMirror)
+		adm.GET("/hosts/limits", admin.ListHostLimits)
+		adm.GET("/hosts/proxies", admin.ListHostProxies)
+		adm.GET("/hosts/metrics", admin.ListHostMetrics)
+		adm.GET("/hosts/accounts", admin.ListHostAccounts)
+		adm.POST("/hosts/accounts/:host/:id/enable", admin.EnableHostAccount, requireRole(jwtauth.RoleAdmin))
 	}
 }
 
@@ -0,0 +1,321 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	prefix      = "em_" // Every API key starts with this prefix so it can be told apart from a JWT
+	secretBytes = 32    // Number of random bytes in the secret part of a key
+	maxPerUser  = 25    // Max number of active keys a single user can hold
+)
+
+// Scopes that can be granted to an API key
+const (
+	ScopeUpload  = "upload"
+	ScopeHistory = "history"
+	ScopeAccount = "account"
+)
+
+var (
+	ErrNotFound    = errors.New("api key not found")
+	ErrInvalid     = errors.New("api key invalid")
+	ErrExpired     = errors.New("api key expired")
+	ErrRevoked     = errors.New("api key revoked")
+	ErrBadScope    = errors.New("unknown scope")
+	ErrLimitHit    = errors.New("too many api keys")
+	ErrSuspended   = errors.New("api key owner suspended")
+	validScopesSet = map[string]bool{ScopeUpload: true, ScopeHistory: true, ScopeAccount: true}
+)
+
+// Key represents a user-managed API key. The secret is never stored, only its hash.
+type Key struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	userID     uuid.UUID
+}
+
+// UserID returns the ID of the user that owns the key
+func (k Key) UserID() uuid.UUID { return k.userID }
+
+// HasScope returns true if the key was granted the given scope
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKey returns true if the given credential looks like an API key rather than a JWT
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, prefix)
+}
+
+// ParseScopes validates and de-duplicates a list of requested scopes
+func ParseScopes(scopes []string) ([]string, error) {
+	seen := map[string]bool{}
+	parsed := []string{}
+	for _, s := range scopes {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		if !validScopesSet[s] {
+			return nil, fmt.Errorf("%w: %q", ErrBadScope, s)
+		}
+		seen[s] = true
+		parsed = append(parsed, s)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: no scopes", ErrBadScope)
+	}
+	return parsed, nil
+}
+
+// Create generates a new API key for a user and stores its hash in the database.
+// The plain-text key is returned only once and cannot be recovered afterwards.
+func Create(ctx context.Context, db *db.Database, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*Key, string, error) {
+	if db == nil {
+		return nil, "", errors.New("database is nil")
+	}
+
+	// Make sure the user is not hoarding keys
+	var count int
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM api_keys
+		WHERE user_id=($1) AND revoked_at IS NULL;
+	`, userID).Scan(&count)
+	if err != nil {
+		return nil, "", fmt.Errorf("count error: %w", err)
+	}
+	if count >= maxPerUser {
+		return nil, "", ErrLimitHit
+	}
+
+	// Generate the key
+	key := &Key{
+		ID:        uuid.New(),
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+		userID:    userID,
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", fmt.Errorf("newSecret error: %w", err)
+	}
+
+	// Save to database
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("BeginTx error: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO api_keys (id, user_id, name, key_hash, scopes, created_at, expires_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($7));
+	`, key.ID, userID, name, hashSecret(secret), strings.Join(scopes, " "), key.CreatedAt, expiresAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, "", fmt.Errorf("commit error: %w", err)
+	}
+	return key, format(key.ID, secret), nil
+}
+
+// List returns all active API keys belonging to a user
+func List(ctx context.Context, db *db.Database, userID uuid.UUID) ([]Key, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT id, name, scopes, created_at, expires_at, last_used_at FROM api_keys
+		WHERE user_id=($1) AND revoked_at IS NULL
+		ORDER BY created_at DESC;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []Key{}
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		k.userID = userID
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke revokes a given API key. Revoked keys can no longer be used to authenticate.
+func Revoke(ctx context.Context, db *db.Database, userID uuid.UUID, keyID string) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	id, err := uuid.Parse(keyID)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	res, err := db.PostgresConn.ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = ($1)
+		WHERE id = ($2)
+		AND user_id = ($3)
+		AND revoked_at IS NULL;
+	`, time.Now().UTC(), id, userID)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Validate looks up a plain-text API key, makes sure it is still usable and
+// records when it was last used.
+func Validate(ctx context.Context, db *db.Database, token string) (*Key, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	id, secret, err := parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the key from the database
+	var (
+		hash      string
+		revoked   sql.NullTime
+		suspended bool
+	)
+	row := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT api_keys.id, name, scopes, created_at, expires_at, last_used_at, user_id, key_hash, revoked_at, users.suspended_at IS NOT NULL
+		FROM api_keys INNER JOIN users ON users.id = api_keys.user_id
+		WHERE api_keys.id=($1);
+	`, id)
+	k, err := scanKey(row, &hash, &revoked, &suspended)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+
+	// Validate
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrInvalid
+	}
+	if revoked.Valid {
+		return nil, ErrRevoked
+	}
+	if suspended {
+		return nil, ErrSuspended
+	}
+	if k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	// Record usage
+	now := time.Now().UTC()
+	if _, err = db.PostgresConn.ExecContext(ctx, `UPDATE api_keys SET last_used_at=($1) WHERE id=($2);`, now, k.ID); err != nil {
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	k.LastUsedAt = &now
+	return k, nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanKey scans a row into a Key. Any extra destinations are scanned after the common columns.
+func scanKey(row scanner, extra ...any) (*Key, error) {
+	var (
+		k        Key
+		scopes   string
+		expires  sql.NullTime
+		lastUsed sql.NullTime
+	)
+	dest := []any{&k.ID, &k.Name, &scopes, &k.CreatedAt, &expires, &lastUsed}
+	if len(extra) > 0 {
+		dest = append(dest, &k.userID)
+		dest = append(dest, extra...)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	if expires.Valid {
+		k.ExpiresAt = &expires.Time
+	}
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	return &k, nil
+}
+
+// newSecret returns a random, URL safe secret
+func newSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret hashes a secret before it is stored or compared.
+// Secrets are long and random, so a fast hash is sufficient here.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// format builds the plain-text key handed to the user: `em_<id>_<secret>`
+func format(id uuid.UUID, secret string) string {
+	return prefix + strings.ReplaceAll(id.String(), "-", "") + "_" + secret
+}
+
+// parse splits a plain-text key into its ID and secret
+func parse(token string) (uuid.UUID, string, error) {
+	if !IsKey(token) {
+		return uuid.Nil, "", ErrInvalid
+	}
+	rawID, secret, ok := strings.Cut(strings.TrimPrefix(token, prefix), "_")
+	if !ok || secret == "" {
+		return uuid.Nil, "", ErrInvalid
+	}
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return uuid.Nil, "", ErrInvalid
+	}
+	return id, secret, nil
+}
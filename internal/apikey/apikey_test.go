@@ -0,0 +1,75 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestFormatAndParse$ github.com/easymirror/easymirror-backend/internal/apikey
+func TestFormatAndParse(t *testing.T) {
+	id := uuid.New()
+	secret, err := newSecret()
+	if err != nil {
+		t.Fatalf("Error generating secret: %v", err)
+	}
+
+	token := format(id, secret)
+	assert.True(t, IsKey(token))
+
+	parsedID, parsedSecret, err := parse(token)
+	if err != nil {
+		t.Fatalf("Error parsing key: %v", err)
+	}
+	assert.Equal(t, id, parsedID)
+	assert.Equal(t, secret, parsedSecret)
+	assert.Equal(t, hashSecret(secret), hashSecret(parsedSecret))
+}
+
+// go test -v -timeout 30s -run ^TestParseInvalid$ github.com/easymirror/easymirror-backend/internal/apikey
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9",
+		"em_",
+		"em_not-a-uuid_secret",
+		"em_" + "0123456789abcdef0123456789abcdef",
+		"em_" + "0123456789abcdef0123456789abcdef_",
+	}
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			_, _, err := parse(test)
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestParseScopes$ github.com/easymirror/easymirror-backend/internal/apikey
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		Input    []string
+		Expected []string
+		Err      error
+	}{
+		{Input: []string{"upload"}, Expected: []string{"upload"}},
+		{Input: []string{" Upload ", "history", "upload"}, Expected: []string{"upload", "history"}},
+		{Input: []string{"upload", "account", "history"}, Expected: []string{"upload", "account", "history"}},
+		{Input: []string{}, Err: ErrBadScope},
+		{Input: []string{"", "  "}, Err: ErrBadScope},
+		{Input: []string{"upload", "admin"}, Err: ErrBadScope},
+	}
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result, err := ParseScopes(test.Input)
+			if test.Err != nil {
+				assert.True(t, errors.Is(err, test.Err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
@@ -18,31 +18,30 @@ const (
 
 type AccessTokenData struct {
 	jwt.RegisteredClaims
-	Scope   string   `json:"scope"`
-	Gateway []string `json:"gty"`
+	Scope     string   `json:"scope"`
+	Gateway   []string `json:"gty"`
+	SessionID string   `json:"sid,omitempty"`
+	Role      Role     `json:"role,omitempty"`
 }
 
-// GenerateJWT is a wrapper function that generates valid access and refresh token based on the userID provided.
-func GenerateJWT(userID string) (*AuthToken, error) {
+// GenerateSessionJWT generates an access and refresh token that belong to a session.
+// The session ID is used as the ID of the refresh token and as the `sid` claim of the access token.
+func GenerateSessionJWT(userID, sessionID string, role Role) (*AuthToken, error) {
 	// Generate access token
-	accessSecret := []byte(os.Getenv("JWT_ACCESS_SECRET"))
-	accessTokenClaims := AccessTokenData{
-		Scope: "openid profile email offline_access upload",
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenMaxAge)),
-			Subject:   userID,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    issuer,
-		},
-	}
-	accessTokenStr, err := generateJWT(accessSecret, accessTokenClaims)
+	accessTokenStr, err := NewAccessToken(userID, sessionID, role)
 	if err != nil {
-		return nil, fmt.Errorf("SignedString error: %w", err)
+		return nil, err
 	}
 
 	// Generate refresh token
 	refreshSecret := []byte(os.Getenv("JWT_REFRESH_SECRET"))
-	refreshTokenClaims := &jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(time.Now()), Issuer: issuer, Subject: userID}
+	refreshTokenClaims := &jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(refreshTokenMaxAge)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    issuer,
+		Subject:   userID,
+		ID:        sessionID,
+	}
 	refreshTokenStr, err := generateJWT(refreshSecret, refreshTokenClaims)
 	if err != nil {
 		return nil, fmt.Errorf("SignedString error: %w", err)
@@ -83,34 +82,51 @@ func ValidateJWT(receivedToken string) (*jwt.Token, error) {
 	return token, nil
 }
 
-// RefreshAccessToken refreshes the access token using the refresh token
-func RefreshAccessToken(refreshTokenStr string) (string, error) {
+// ParseRefreshToken validates a refresh token and returns its claims.
+// The ID of the claims is the session the token belongs to, it is empty for tokens issued before sessions existed.
+func ParseRefreshToken(refreshTokenStr string) (*jwt.RegisteredClaims, error) {
 	refreshToken, err := jwt.ParseWithClaims(refreshTokenStr, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(os.Getenv("JWT_REFRESH_SECRET")), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	claims, ok := refreshToken.Claims.(*jwt.RegisteredClaims)
 	if !ok || !refreshToken.Valid {
-		return "", fmt.Errorf("invalid refresh token")
+		return nil, fmt.Errorf("invalid refresh token")
 	}
-	fmt.Println(claims)
+	return claims, nil
+}
 
-	// Generate new access token
+// NewAccessToken generates a new access token for a user and session.
+// The role should be read fresh from the database, so changes apply on the next refresh.
+func NewAccessToken(userID, sessionID string, role Role) (string, error) {
 	accessSecret := []byte(os.Getenv("JWT_ACCESS_SECRET"))
-	accessTokenClaims := &jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenMaxAge)),
-		Subject:   claims.Subject,
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		Issuer:    issuer,
+	accessTokenClaims := AccessTokenData{
+		Scope:     "openid profile email offline_access upload",
+		SessionID: sessionID,
+		Role:      role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenMaxAge)),
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    issuer,
+		},
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
-	newAccessToken, err := accessToken.SignedString(accessSecret)
+	accessTokenStr, err := generateJWT(accessSecret, accessTokenClaims)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("SignedString error: %w", err)
 	}
+	return accessTokenStr, nil
+}
 
-	return newAccessToken, nil
+// SessionID returns the `sid` claim of an access token, if any
+func SessionID(t *jwt.Token) string {
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	sid, _ := claims["sid"].(string)
+	return sid
 }
@@ -42,7 +42,7 @@ func TestGenerateJWT(t *testing.T) {
 // go test -v -timeout 30s -run ^TestValidateJWT$ github.com/easymirror/easymirror-backend/internal/auth
 func TestValidateJWT(t *testing.T) {
 	// Generate a JWT Token
-	token, err := GenerateJWT("some_id")
+	token, err := GenerateSessionJWT("some_id", "some_session", RoleUser)
 	if err != nil {
 		t.Fatalf("Error generating JWT: %v", err)
 	}
@@ -59,15 +59,41 @@ func TestValidateJWT(t *testing.T) {
 // go test -v -timeout 30s -run ^TestRefreshAccessToken$ github.com/easymirror/easymirror-backend/internal/auth
 func TestRefreshAccessToken(t *testing.T) {
 	// Generate a JWT
-	auth, err := GenerateJWT("some_id")
+	auth, err := GenerateSessionJWT("some_id", "some_session", RoleUser)
 	if err != nil {
 		t.Fatalf("Error generating JWT: %v", err)
 	}
 
 	// Refresh the token
-	newAuth, err := RefreshAccessToken(auth.RefreshToken)
+	claims, err := ParseRefreshToken(auth.RefreshToken)
+	if err != nil {
+		t.Fatalf("Error parsing refresh token: %v", err)
+	}
+	newAuth, err := NewAccessToken(claims.Subject, claims.ID, RoleUser)
 	if err != nil {
 		t.Fatalf("Error refreshing JWT: %v", err)
 	}
 	assert.NotEmpty(t, newAuth)
+
+	tkn, err := ValidateJWT(newAuth)
+	if err != nil {
+		t.Fatalf("Error validating JWT: %v", err)
+	}
+	assert.Equal(t, "some_session", SessionID(tkn))
+}
+
+// go test -v -timeout 30s -run ^TestRefreshTokenExpires$ github.com/easymirror/easymirror-backend/internal/auth
+func TestRefreshTokenExpires(t *testing.T) {
+	auth, err := GenerateSessionJWT("some_id", "some_session", RoleUser)
+	if err != nil {
+		t.Fatalf("Error generating JWT: %v", err)
+	}
+
+	claims, err := ParseRefreshToken(auth.RefreshToken)
+	if err != nil {
+		t.Fatalf("Error parsing refresh token: %v", err)
+	}
+	assert.NotNil(t, claims.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(refreshTokenMaxAge), claims.ExpiresAt.Time, time.Minute)
+	assert.Equal(t, "some_session", claims.ID)
 }
@@ -0,0 +1,119 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+)
+
+const redirectURL = "https://easymirror.io/auth/oidc/mock/callback"
+
+func newTestProvider(s *oidctest.Server) *Provider {
+	return NewProvider(Config{
+		Name:         "mock",
+		Issuer:       s.Issuer(),
+		ClientID:     s.ClientID,
+		ClientSecret: s.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"email"},
+	}, s.Client())
+}
+
+// go test -v -timeout 30s -run ^TestAuthorizationCodeFlow$ github.com/easymirror/easymirror-backend/internal/auth/oidc
+func TestAuthorizationCodeFlow(t *testing.T) {
+	s := oidctest.NewServer()
+	defer s.Close()
+	p := newTestProvider(s)
+	ctx := context.Background()
+
+	// Build the authorization URL
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := p.AuthCodeURL(ctx, "some-state", "some-nonce", challenge)
+	if err != nil {
+		t.Fatalf("Error building auth url: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	assert.Equal(t, s.Issuer()+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "some-state", u.Query().Get("state"))
+	assert.Equal(t, challenge, u.Query().Get("code_challenge"))
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email", u.Query().Get("scope"))
+
+	identity := oidctest.Identity{Subject: "user-123", Email: "user@example.com", EmailVerified: true}
+
+	t.Run("Valid", func(t *testing.T) {
+		code := s.Authorize(identity, challenge, "some-nonce", redirectURL)
+		claims, err := p.Exchange(ctx, code, verifier, "some-nonce")
+		if err != nil {
+			t.Fatalf("Error exchanging code: %v", err)
+		}
+		assert.Equal(t, "user-123", claims.Subject)
+		assert.Equal(t, "user@example.com", claims.Email)
+		assert.True(t, claims.EmailVerified)
+	})
+
+	t.Run("Wrong verifier", func(t *testing.T) {
+		code := s.Authorize(identity, challenge, "some-nonce", redirectURL)
+		_, err := p.Exchange(ctx, code, "wrong-verifier", "some-nonce")
+		assert.Error(t, err)
+	})
+
+	t.Run("Wrong nonce", func(t *testing.T) {
+		code := s.Authorize(identity, challenge, "other-nonce", redirectURL)
+		_, err := p.Exchange(ctx, code, verifier, "some-nonce")
+		assert.True(t, errors.Is(err, ErrInvalidIDToken))
+	})
+
+	t.Run("Code reuse", func(t *testing.T) {
+		code := s.Authorize(identity, challenge, "some-nonce", redirectURL)
+		if _, err := p.Exchange(ctx, code, verifier, "some-nonce"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := p.Exchange(ctx, code, verifier, "some-nonce")
+		assert.Error(t, err)
+	})
+}
+
+// go test -v -timeout 30s -run ^TestVerify$ github.com/easymirror/easymirror-backend/internal/auth/oidc
+func TestVerify(t *testing.T) {
+	s := oidctest.NewServer()
+	defer s.Close()
+	p := newTestProvider(s)
+	identity := oidctest.Identity{Subject: "user-123"}
+
+	tests := []struct {
+		Name  string
+		Token string
+		Valid bool
+	}{
+		{Name: "Valid", Token: s.IDToken(identity, s.ClientID, "n", time.Hour), Valid: true},
+		{Name: "Wrong audience", Token: s.IDToken(identity, "other-client", "n", time.Hour)},
+		{Name: "Expired", Token: s.IDToken(identity, s.ClientID, "n", -time.Hour)},
+		{Name: "Wrong nonce", Token: s.IDToken(identity, s.ClientID, "x", time.Hour)},
+		{Name: "No subject", Token: s.IDToken(oidctest.Identity{}, s.ClientID, "n", time.Hour)},
+		{Name: "Garbage", Token: "not.a.jwt"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := p.verify(context.Background(), test.Token, "n")
+			assert.Equal(t, test.Valid, err == nil, "err: %v", err)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestDiscoveryIssuerMismatch$ github.com/easymirror/easymirror-backend/internal/auth/oidc
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	s := oidctest.NewServer()
+	defer s.Close()
+	p := NewProvider(Config{Name: "mock", Issuer: s.Issuer() + "/other"}, s.Client())
+	_, err := p.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Registry holds all configured identity providers
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry returns a registry with the given providers
+func NewRegistry(providers ...*Provider) *Registry {
+	r := &Registry{providers: map[string]*Provider{}}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+// FromEnv builds a registry from environment variables.
+//
+// `OIDC_PROVIDERS` is a comma separated list of provider names. Each provider is then configured with
+// `OIDC_<NAME>_ISSUER`, `OIDC_<NAME>_CLIENT_ID`, `OIDC_<NAME>_CLIENT_SECRET`, `OIDC_<NAME>_REDIRECT_URL`
+// and optionally `OIDC_<NAME>_SCOPES`.
+func FromEnv() *Registry {
+	providers := []*Provider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		env := func(key string) string {
+			return os.Getenv(fmt.Sprintf("OIDC_%v_%v", strings.ToUpper(name), key))
+		}
+		scopes := strings.Fields(strings.ReplaceAll(env("SCOPES"), ",", " "))
+		if len(scopes) == 0 {
+			scopes = []string{"email", "profile"}
+		}
+		providers = append(providers, NewProvider(Config{
+			Name:         name,
+			Issuer:       env("ISSUER"),
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			RedirectURL:  env("REDIRECT_URL"),
+			Scopes:       scopes,
+		}, nil))
+	}
+	return NewRegistry(providers...)
+}
+
+// Get returns the provider with a given name
+func (r *Registry) Get(name string) (*Provider, error) {
+	p, ok := r.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+	return p, nil
+}
+
+// Names returns the names of all configured providers
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	return names
+}
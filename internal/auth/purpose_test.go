@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestPurposeToken$ github.com/easymirror/easymirror-backend/internal/auth
+func TestPurposeToken(t *testing.T) {
+	token, id, err := NewPurposeToken(PurposeMFA, "some_id", time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		claims, err := ParsePurposeToken(PurposeMFA, token)
+		if err != nil {
+			t.Fatalf("Error parsing token: %v", err)
+		}
+		assert.Equal(t, "some_id", claims.Subject)
+		assert.Equal(t, id, claims.ID)
+	})
+
+	t.Run("Wrong purpose", func(t *testing.T) {
+		_, err := ParsePurposeToken("other", token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Not an access token", func(t *testing.T) {
+		_, err := ValidateJWT(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired, _, err := NewPurposeToken(PurposeMFA, "some_id", -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ParsePurposeToken(PurposeMFA, expired)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Role is the access level of a user. It is carried in the `role` claim of access tokens.
+type Role string
+
+const (
+	RoleUser      Role = "user"      // Regular user, the default
+	RoleModerator Role = "moderator" // Can inspect users and mirrors, suspend users and delete mirrors
+	RoleAdmin     Role = "admin"     // Can do everything, including changing roles
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// roleRanks orders roles from least to most privileged
+var roleRanks = map[Role]int{RoleUser: 0, RoleModerator: 1, RoleAdmin: 2}
+
+// ParseRole validates a role. An empty role is treated as a regular user.
+func ParseRole(s string) (Role, error) {
+	if s == "" {
+		return RoleUser, nil
+	}
+	r := Role(s)
+	if _, ok := roleRanks[r]; !ok {
+		return "", ErrUnknownRole
+	}
+	return r, nil
+}
+
+// AtLeast returns true if the role is as privileged as, or more privileged than min.
+// Unknown roles are never privileged.
+func (r Role) AtLeast(min Role) bool {
+	rank, ok := roleRanks[r]
+	if !ok {
+		return false
+	}
+	return rank >= roleRanks[min]
+}
+
+// RoleFromToken returns the role of an access token.
+// Tokens without a role, such as the ones created from API keys, belong to regular users.
+func RoleFromToken(t *jwt.Token) Role {
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return RoleUser
+	}
+	s, _ := claims["role"].(string)
+	r, err := ParseRole(s)
+	if err != nil {
+		return RoleUser
+	}
+	return r
+}
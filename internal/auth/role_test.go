@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestRoleAtLeast$ github.com/easymirror/easymirror-backend/internal/auth
+func TestRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		Role, Min Role
+		Expected  bool
+	}{
+		{Role: RoleUser, Min: RoleUser, Expected: true},
+		{Role: RoleUser, Min: RoleModerator, Expected: false},
+		{Role: RoleModerator, Min: RoleModerator, Expected: true},
+		{Role: RoleModerator, Min: RoleAdmin, Expected: false},
+		{Role: RoleAdmin, Min: RoleModerator, Expected: true},
+		{Role: Role("root"), Min: RoleUser, Expected: false},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := test.Role.AtLeast(test.Min)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestRoleFromToken$ github.com/easymirror/easymirror-backend/internal/auth
+func TestRoleFromToken(t *testing.T) {
+	tests := []struct {
+		Claims   jwt.MapClaims
+		Expected Role
+	}{
+		{Claims: jwt.MapClaims{"role": "admin"}, Expected: RoleAdmin},
+		{Claims: jwt.MapClaims{}, Expected: RoleUser},               // Missing
+		{Claims: jwt.MapClaims{"role": "root"}, Expected: RoleUser}, // Unknown
+		{Claims: APIKeyToken("id", []string{"upload"}).Claims.(jwt.MapClaims), Expected: RoleUser},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := RoleFromToken(jwt.NewWithClaims(jwt.SigningMethodHS256, test.Claims))
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Package authz decides what a user may do with a mirror link.
+//
+// The rules live in Can, which is a pure function so every rule can be tested without a database.
+// CheckMirror loads what Can needs and is what handlers should call.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/easymirror/easymirror-backend/internal/workspace"
+	"github.com/google/uuid"
+)
+
+// Action is something a user can do with a mirror link
+type Action int
+
+const (
+	View   Action = iota // See the mirror link and its files in the history
+	Upload               // Add files to the mirror link
+	Mirror               // Send the files of the mirror link to other hosts
+	Edit                 // Rename the mirror link or move it to another workspace
+	Delete               // Delete the mirror link
+)
+
+var (
+	// ErrNotFound is returned when the mirror link doesn't exist or the user can't see it.
+	// Both cases look the same so nobody can probe for mirror IDs they don't have access to.
+	ErrNotFound  = errors.New("mirror link not found")
+	ErrForbidden = errors.New("not allowed")
+)
+
+// minRoles is the lowest workspace role that may do an action with the workspace's mirror links
+var minRoles = map[Action]workspace.Role{
+	View:   workspace.RoleViewer,
+	Upload: workspace.RoleMember,
+	Mirror: workspace.RoleMember,
+	Edit:   workspace.RoleMember,
+	Delete: workspace.RoleAdmin,
+}
+
+// Can returns nil if a user may do an action with a mirror link.
+// role is the user's role in the mirror link's workspace, or empty if they are not a member.
+//
+// The creator of a mirror link can always do everything with it.
+// Workspace members can do what their role allows, anybody else gets ErrNotFound.
+func Can(userID uuid.UUID, owner mirrorlink.Ownership, role workspace.Role, action Action) error {
+	min, ok := minRoles[action]
+	if !ok {
+		return fmt.Errorf("unknown action %d", action)
+	}
+	if userID == uuid.Nil {
+		return ErrNotFound
+	}
+	if owner.CreatedByID == userID {
+		return nil
+	}
+	if owner.WorkspaceID == nil || !role.AtLeast(workspace.RoleViewer) {
+		return ErrNotFound
+	}
+	if !role.AtLeast(min) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CheckMirror returns nil if a user may do an action with a mirror link.
+// It returns ErrNotFound or ErrForbidden if they may not.
+func CheckMirror(ctx context.Context, db *db.Database, userID uuid.UUID, mirrorID string, action Action) error {
+	owner, err := mirrorlink.GetOwnership(ctx, db, mirrorID)
+	if errors.Is(err, mirrorlink.ErrNotFound) {
+		return ErrNotFound
+	} else if err != nil {
+		return fmt.Errorf("GetOwnership error: %w", err)
+	}
+
+	var role workspace.Role
+	if owner.WorkspaceID != nil && owner.CreatedByID != userID {
+		role, err = workspace.MemberRole(ctx, db, *owner.WorkspaceID, userID)
+		if err != nil && !errors.Is(err, workspace.ErrNotMember) {
+			return fmt.Errorf("MemberRole error: %w", err)
+		}
+	}
+	return Can(userID, *owner, role, action)
+}
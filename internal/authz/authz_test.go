@@ -0,0 +1,62 @@
+package authz
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/easymirror/easymirror-backend/internal/workspace"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestCan$ github.com/easymirror/easymirror-backend/internal/authz
+func TestCan(t *testing.T) {
+	creator := uuid.New()
+	other := uuid.New()
+	wsID := uuid.New()
+	personal := mirrorlink.Ownership{CreatedByID: creator}
+	shared := mirrorlink.Ownership{CreatedByID: creator, WorkspaceID: &wsID}
+
+	tests := []struct {
+		User     uuid.UUID
+		Owner    mirrorlink.Ownership
+		Role     workspace.Role
+		Action   Action
+		Expected error
+	}{
+		{User: creator, Owner: personal, Action: View},
+		{User: creator, Owner: personal, Action: Upload},
+		{User: creator, Owner: personal, Action: Mirror},
+		{User: creator, Owner: personal, Action: Delete},
+		{User: creator, Owner: shared, Action: Delete},
+		{User: creator, Owner: shared, Role: workspace.RoleViewer, Action: Edit}, // The creator still edits after becoming a viewer
+		{User: other, Owner: personal, Action: View, Expected: ErrNotFound},
+		{User: other, Owner: personal, Action: Mirror, Expected: ErrNotFound},
+		{User: other, Owner: personal, Role: workspace.RoleOwner, Action: View, Expected: ErrNotFound}, // A role without a workspace is ignored
+		{User: other, Owner: shared, Action: View, Expected: ErrNotFound},
+		{User: other, Owner: shared, Action: Upload, Expected: ErrNotFound},
+		{User: other, Owner: shared, Role: workspace.Role("guest"), Action: View, Expected: ErrNotFound},
+		{User: other, Owner: shared, Role: workspace.RoleViewer, Action: View},
+		{User: other, Owner: shared, Role: workspace.RoleViewer, Action: Upload, Expected: ErrForbidden},
+		{User: other, Owner: shared, Role: workspace.RoleViewer, Action: Mirror, Expected: ErrForbidden},
+		{User: other, Owner: shared, Role: workspace.RoleViewer, Action: Edit, Expected: ErrForbidden},
+		{User: other, Owner: shared, Role: workspace.RoleMember, Action: Upload},
+		{User: other, Owner: shared, Role: workspace.RoleMember, Action: Mirror},
+		{User: other, Owner: shared, Role: workspace.RoleMember, Action: Edit},
+		{User: other, Owner: shared, Role: workspace.RoleMember, Action: Delete, Expected: ErrForbidden},
+		{User: other, Owner: shared, Role: workspace.RoleAdmin, Action: Delete},
+		{User: other, Owner: shared, Role: workspace.RoleOwner, Action: Delete},
+		{User: uuid.Nil, Owner: mirrorlink.Ownership{}, Action: View, Expected: ErrNotFound},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := Can(test.User, test.Owner, test.Role, test.Action)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+
+	// Unknown action
+	assert.Error(t, Can(creator, personal, "", Action(99)))
+}
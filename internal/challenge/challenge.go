@@ -0,0 +1,72 @@
+// Package challenge gates expensive, unauthenticated endpoints behind a challenge-response step.
+//
+// The default is a hashcash-style proof of work: the server hands out a signed challenge
+// and the client must find a nonce so that sha256(challenge + ":" + nonce) starts with a number of zero bits.
+// Other verifiers (ie: a CAPTCHA) can be plugged in through the Verifier interface.
+package challenge
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMissing  = errors.New("challenge missing")
+	ErrInvalid  = errors.New("challenge invalid")
+	ErrReplayed = errors.New("challenge already used")
+)
+
+// Challenge is handed to a client that has to solve it
+type Challenge struct {
+	Algorithm  string `json:"algorithm"`  // Name of the verifier, ie: `sha256` for proof of work
+	Challenge  string `json:"challenge"`  // Opaque challenge that must be sent back with the solution
+	Difficulty int    `json:"difficulty"` // Number of leading zero bits the hash must have
+}
+
+// Response is a client's answer to a challenge
+type Response struct {
+	Challenge string `json:"challenge"`
+	Solution  string `json:"solution"`
+	RemoteIP  string `json:"-"`
+}
+
+// Verifier issues and verifies challenges
+type Verifier interface {
+	Issue(ctx context.Context) (*Challenge, error)
+	Verify(ctx context.Context, r Response) error
+}
+
+// FromEnv returns the verifier configured with `CHALLENGE_PROVIDER`.
+// It defaults to proof of work, with the difficulty set by `CHALLENGE_DIFFICULTY`.
+// Setting the provider to `none` disables the gate, which is useful for local development.
+func FromEnv() Verifier {
+	switch strings.ToLower(os.Getenv("CHALLENGE_PROVIDER")) {
+	case "none":
+		log.Println("CHALLENGE_PROVIDER is none, anonymous sign ups are not gated.")
+		return Fake{}
+	default:
+		difficulty, err := strconv.Atoi(os.Getenv("CHALLENGE_DIFFICULTY"))
+		if err != nil || difficulty <= 0 {
+			difficulty = DefaultDifficulty
+		}
+		return NewProofOfWork(difficulty)
+	}
+}
+
+// Fake is a verifier that accepts every response, unless Err is set.
+// It stands in for a real CAPTCHA during local development and in tests.
+type Fake struct {
+	Err error
+}
+
+func (f Fake) Issue(ctx context.Context) (*Challenge, error) {
+	return &Challenge{Algorithm: "none"}, nil
+}
+
+func (f Fake) Verify(ctx context.Context, r Response) error {
+	return f.Err
+}
@@ -0,0 +1,76 @@
+package challenge
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -timeout 30s -run ^TestProofOfWork$ github.com/easymirror/easymirror-backend/internal/challenge
+func TestProofOfWork(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pow := NewProofOfWork(8)
+
+	c, err := pow.Issue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 8, c.Difficulty)
+	solution, err := Solve(ctx, c)
+	require.NoError(t, err)
+
+	tests := []struct {
+		Input    Response
+		Expected error
+	}{
+		{Input: Response{}, Expected: ErrMissing},
+		{Input: Response{Challenge: "abc", Solution: solution}, Expected: ErrInvalid},
+		{Input: Response{Challenge: c.Challenge, Solution: solution}, Expected: nil},
+		{Input: Response{Challenge: c.Challenge, Solution: solution}, Expected: ErrReplayed}, // Replayed
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			err := pow.Verify(ctx, test.Input)
+			assert.ErrorIs(t, err, test.Expected)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestProofOfWorkDifficulty$ github.com/easymirror/easymirror-backend/internal/challenge
+func TestProofOfWorkDifficulty(t *testing.T) {
+	ctx := context.Background()
+
+	// A challenge issued with a lower difficulty is not accepted by a stricter verifier
+	easy, err := NewProofOfWork(1).Issue(ctx)
+	require.NoError(t, err)
+	solution, err := Solve(ctx, easy)
+	require.NoError(t, err)
+	for LeadingZeroBits(easy.Challenge, solution) >= 12 {
+		easy, _ = NewProofOfWork(1).Issue(ctx)
+		solution, _ = Solve(ctx, easy)
+	}
+	assert.ErrorIs(t, NewProofOfWork(12).Verify(ctx, Response{Challenge: easy.Challenge, Solution: solution}), ErrInvalid)
+}
+
+// go test -v -timeout 30s -run ^TestLeadingZeroBits$ github.com/easymirror/easymirror-backend/internal/challenge
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		Solution string
+		Expected int
+	}{
+		{Solution: "1", Expected: 0},  // sha256("abc:1") = bfcf...
+		{Solution: "3", Expected: 4},  // sha256("abc:3") = 0ffa...
+		{Solution: "40", Expected: 4}, // sha256("abc:40") = 08fd...
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := LeadingZeroBits("abc", test.Solution)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
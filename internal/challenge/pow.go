@@ -0,0 +1,111 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DefaultDifficulty = 20              // ~1M hashes on average, under a second in a browser
+	maxDifficulty     = 32              // Anything higher would take clients minutes
+	challengeMaxAge   = 5 * time.Minute // How long a client has to solve a challenge
+)
+
+// powClaims are signed into the challenge, so the server does not need to store them
+type powClaims struct {
+	jwt.RegisteredClaims
+	Difficulty int `json:"difficulty"`
+}
+
+func (c *powClaims) Registered() *jwt.RegisteredClaims { return &c.RegisteredClaims }
+
+// ProofOfWork is a hashcash-style verifier. Challenges are stateless signed tokens,
+// only the IDs of solved challenges are kept in memory to prevent replays.
+type ProofOfWork struct {
+	difficulty int
+	now        func() time.Time
+
+	mu   sync.Mutex
+	used map[string]time.Time // Challenge ID -> expiry
+}
+
+// NewProofOfWork returns a proof of work verifier with a given difficulty in bits
+func NewProofOfWork(difficulty int) *ProofOfWork {
+	if difficulty > maxDifficulty {
+		difficulty = maxDifficulty
+	}
+	return &ProofOfWork{difficulty: difficulty, now: time.Now, used: map[string]time.Time{}}
+}
+
+// Issue returns a new challenge
+func (p *ProofOfWork) Issue(ctx context.Context) (*Challenge, error) {
+	token, err := auth.SignPurposeClaims(auth.PurposeChallenge, &powClaims{Difficulty: p.difficulty}, challengeMaxAge)
+	if err != nil {
+		return nil, fmt.Errorf("sign error: %w", err)
+	}
+	return &Challenge{Algorithm: "sha256", Challenge: token, Difficulty: p.difficulty}, nil
+}
+
+// Verify checks that the solution solves the challenge and that the challenge was not used before
+func (p *ProofOfWork) Verify(ctx context.Context, r Response) error {
+	if r.Challenge == "" || r.Solution == "" {
+		return ErrMissing
+	}
+	claims := &powClaims{}
+	if err := auth.ParsePurposeClaims(auth.PurposeChallenge, r.Challenge, claims); err != nil {
+		return ErrInvalid
+	}
+	if claims.Difficulty < p.difficulty || LeadingZeroBits(r.Challenge, r.Solution) < claims.Difficulty {
+		return ErrInvalid
+	}
+
+	// Every challenge can only be solved once
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	for id, expiry := range p.used {
+		if now.After(expiry) {
+			delete(p.used, id)
+		}
+	}
+	if _, ok := p.used[claims.ID]; ok {
+		return ErrReplayed
+	}
+	p.used[claims.ID] = claims.ExpiresAt.Time
+	return nil
+}
+
+// LeadingZeroBits returns the number of leading zero bits of sha256(challenge + ":" + solution)
+func LeadingZeroBits(challenge, solution string) int {
+	sum := sha256.Sum256([]byte(challenge + ":" + solution))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Solve finds a solution for a challenge by brute force. This is what clients do,
+// it is mainly useful in tests and scripts.
+func Solve(ctx context.Context, c *Challenge) (string, error) {
+	for i := 0; ; i++ {
+		if i%4096 == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		solution := strconv.Itoa(i)
+		if LeadingZeroBits(c.Challenge, solution) >= c.Difficulty {
+			return solution, nil
+		}
+	}
+}
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS user_totp
+(
+    user_id uuid NOT NULL,
+    secret text NOT NULL,
+    created_at timestamp NOT NULL,
+    confirmed_at timestamp,
+    last_used_step bigint,
+    PRIMARY KEY (user_id),
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    code_hash text NOT NULL,
+    created_at timestamp NOT NULL,
+    used_at timestamp,
+    PRIMARY KEY (id),
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
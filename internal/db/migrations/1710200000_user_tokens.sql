@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS user_tokens
+(
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    purpose character varying(30) NOT NULL,
+    created_at timestamp NOT NULL,
+    expires_at timestamp NOT NULL,
+    used_at timestamp,
+    PRIMARY KEY (id),
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
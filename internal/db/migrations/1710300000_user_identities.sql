@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_identities
+(
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    provider character varying(30) NOT NULL,
+    subject text NOT NULL,
+    email text,
+    created_at timestamp NOT NULL,
+    last_login_at timestamp,
+    PRIMARY KEY (id),
+    CONSTRAINT provider_subject UNIQUE (provider, subject),
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
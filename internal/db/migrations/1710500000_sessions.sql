@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    device text,
+    user_agent text,
+    ip character varying(45),
+    created_at timestamp NOT NULL,
+    last_refresh_at timestamp NOT NULL,
+    revoked_at timestamp,
+    PRIMARY KEY (id),
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
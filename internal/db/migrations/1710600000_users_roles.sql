@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role character varying(20) NOT NULL DEFAULT 'user',
+    ADD COLUMN IF NOT EXISTS suspended_at timestamp;
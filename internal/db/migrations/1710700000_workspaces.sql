@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS workspaces
+(
+    id uuid NOT NULL,
+    name character varying(60) NOT NULL,
+    created_by_id uuid NOT NULL,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT created_by_id FOREIGN KEY (created_by_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members
+(
+    workspace_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role character varying(20) NOT NULL,
+    added_at timestamp NOT NULL,
+    PRIMARY KEY (workspace_id, user_id),
+    CONSTRAINT workspace_id FOREIGN KEY (workspace_id)
+        REFERENCES public.workspaces (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+ALTER TABLE mirroring_links
+    ADD COLUMN IF NOT EXISTS workspace_id uuid REFERENCES public.workspaces (id) ON DELETE SET NULL;
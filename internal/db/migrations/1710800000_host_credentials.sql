@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS host_credentials
+(
+    user_id uuid NOT NULL,
+    host character varying(30) NOT NULL,
+    key_id character varying(30) NOT NULL,
+    wrapped_key bytea NOT NULL,
+    ciphertext bytea NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (user_id, host),
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS host_account_usage
+(
+    account_id character varying(60) NOT NULL,
+    day character varying(10),
+    day_bytes bigint NOT NULL DEFAULT 0,
+    storage_bytes bigint NOT NULL DEFAULT 0,
+    disabled_at timestamp,
+    disabled_reason text,
+    PRIMARY KEY (account_id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS file_parts
+(
+    file_id uuid NOT NULL,
+    host character varying(30) NOT NULL,
+    part integer NOT NULL,
+    mode character varying(10) NOT NULL,
+    name text NOT NULL,
+    size_bytes bigint NOT NULL,
+    PRIMARY KEY (file_id, host, part),
+    CONSTRAINT file_id FOREIGN KEY (file_id)
+        REFERENCES public.files (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS archives
+(
+    mirror_id uuid NOT NULL,
+    host character varying(30) NOT NULL,
+    format character varying(10) NOT NULL,
+    name text NOT NULL,
+    size_bytes bigint NOT NULL,
+    sha256 character(64) NOT NULL,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (mirror_id, host),
+    CONSTRAINT mirror_id FOREIGN KEY (mirror_id)
+        REFERENCES public.mirroring_links (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
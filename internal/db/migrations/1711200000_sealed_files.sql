@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS sealed_files
+(
+    mirror_id uuid NOT NULL,
+    host character varying(30) NOT NULL,
+    name text NOT NULL,
+    plain_name text NOT NULL,
+    plain_size bigint NOT NULL,
+    size_bytes bigint NOT NULL,
+    chunk_size integer NOT NULL,
+    nonce_prefix bytea NOT NULL,
+    locations text NOT NULL,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (mirror_id, host, name),
+    CONSTRAINT mirror_id FOREIGN KEY (mirror_id)
+        REFERENCES public.mirroring_links (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
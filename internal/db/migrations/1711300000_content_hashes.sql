@@ -0,0 +1,17 @@
+ALTER TABLE files
+    ADD COLUMN IF NOT EXISTS sha256 character(64);
+
+CREATE INDEX IF NOT EXISTS files_sha256 ON files (sha256);
+
+CREATE TABLE IF NOT EXISTS host_files
+(
+    file_id uuid NOT NULL,
+    host character varying(30) NOT NULL,
+    remote_id text NOT NULL,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (file_id, host),
+    CONSTRAINT file_id FOREIGN KEY (file_id)
+        REFERENCES public.files (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS file_checks
+(
+    mirror_id uuid NOT NULL,
+    host character varying(30) NOT NULL,
+    name text NOT NULL,
+    status character varying(20) NOT NULL,
+    detail text NOT NULL,
+    attempts integer NOT NULL,
+    checked_at timestamp NOT NULL,
+    PRIMARY KEY (mirror_id, host, name),
+    CONSTRAINT mirror_id FOREIGN KEY (mirror_id)
+        REFERENCES public.mirroring_links (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
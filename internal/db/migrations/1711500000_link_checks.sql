@@ -0,0 +1,21 @@
+ALTER TABLE host_files
+    ADD COLUMN IF NOT EXISTS url text NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS link_checks
+(
+    id uuid NOT NULL,
+    mirror_id uuid NOT NULL,
+    host character varying(30) NOT NULL,
+    level character varying(10) NOT NULL,
+    url text NOT NULL,
+    status character varying(10) NOT NULL,
+    detail text NOT NULL,
+    checked_at timestamp NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT mirror_id FOREIGN KEY (mirror_id)
+        REFERENCES public.mirroring_links (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS link_checks_url ON link_checks (url, checked_at);
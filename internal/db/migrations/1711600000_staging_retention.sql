@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS staging_retention
+(
+    mirror_id uuid NOT NULL,
+    policy character varying(20) NOT NULL,
+    days integer NOT NULL,
+    uploads integer NOT NULL,
+    expires_at timestamp,
+    deleted_at timestamp,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (mirror_id),
+    CONSTRAINT mirror_id FOREIGN KEY (mirror_id)
+        REFERENCES public.mirroring_links (id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS staging_retention_expires_at ON staging_retention (expires_at);
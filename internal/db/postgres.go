@@ -42,6 +42,31 @@ func initPostgres() (*sql.DB, error) {
 		`CREATE TABLE IF NOT EXISTS mirroring_links ( id uuid NOT NULL, created_by_id uuid NOT NULL, nickname character varying(60), upload_date timestamp, duration_ms bigint, PRIMARY KEY (id), CONSTRAINT created_by_id FOREIGN KEY (created_by_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE NO ACTION NOT VALID );`,
 		`CREATE TABLE IF NOT EXISTS files ( id uuid NOT NULL, name text NOT NULL, size_bytes bigint NOT NULL, upload_date timestamp NOT NULL, mirror_link_id uuid, PRIMARY KEY (id), CONSTRAINT mirror_link_id FOREIGN KEY (mirror_link_id) REFERENCES public.mirroring_links (id) MATCH SIMPLE ON UPDATE NO ACTION ON DELETE SET NULL NOT VALID );`,
 		`CREATE TABLE IF NOT EXISTS host_links ( mirror_id uuid NOT NULL, bunkr text, gofile text, pixeldrain text, cyberfile text, saint_to text, cyberdrop text, PRIMARY KEY (mirror_id), CONSTRAINT mirror_id FOREIGN KEY (mirror_id) REFERENCES public.mirroring_links (id) );`,
+		`CREATE TABLE IF NOT EXISTS api_keys ( id uuid NOT NULL, user_id uuid NOT NULL, name character varying(60) NOT NULL, key_hash text NOT NULL, scopes text NOT NULL, created_at timestamp NOT NULL, expires_at timestamp, last_used_at timestamp, revoked_at timestamp, PRIMARY KEY (id), CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS user_totp ( user_id uuid NOT NULL, secret text NOT NULL, created_at timestamp NOT NULL, confirmed_at timestamp, last_used_step bigint, PRIMARY KEY (user_id), CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS recovery_codes ( id uuid NOT NULL, user_id uuid NOT NULL, code_hash text NOT NULL, created_at timestamp NOT NULL, used_at timestamp, PRIMARY KEY (id), CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS user_tokens ( id uuid NOT NULL, user_id uuid NOT NULL, purpose character varying(30) NOT NULL, created_at timestamp NOT NULL, expires_at timestamp NOT NULL, used_at timestamp, PRIMARY KEY (id), CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS user_identities ( id uuid NOT NULL, user_id uuid NOT NULL, provider character varying(30) NOT NULL, subject text NOT NULL, email text, created_at timestamp NOT NULL, last_login_at timestamp, PRIMARY KEY (id), CONSTRAINT provider_subject UNIQUE (provider, subject), CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at timestamp;`,
+		`CREATE TABLE IF NOT EXISTS sessions ( id uuid NOT NULL, user_id uuid NOT NULL, device text, user_agent text, ip character varying(45), created_at timestamp NOT NULL, last_refresh_at timestamp NOT NULL, revoked_at timestamp, PRIMARY KEY (id), CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS role character varying(20) NOT NULL DEFAULT 'user', ADD COLUMN IF NOT EXISTS suspended_at timestamp;`,
+		`CREATE TABLE IF NOT EXISTS workspaces ( id uuid NOT NULL, name character varying(60) NOT NULL, created_by_id uuid NOT NULL, created_at timestamp NOT NULL, PRIMARY KEY (id), CONSTRAINT created_by_id FOREIGN KEY (created_by_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS workspace_members ( workspace_id uuid NOT NULL, user_id uuid NOT NULL, role character varying(20) NOT NULL, added_at timestamp NOT NULL, PRIMARY KEY (workspace_id, user_id), CONSTRAINT workspace_id FOREIGN KEY (workspace_id) REFERENCES public.workspaces (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE, CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`ALTER TABLE mirroring_links ADD COLUMN IF NOT EXISTS workspace_id uuid REFERENCES public.workspaces (id) ON DELETE SET NULL;`,
+		`CREATE TABLE IF NOT EXISTS host_credentials ( user_id uuid NOT NULL, host character varying(30) NOT NULL, key_id character varying(30) NOT NULL, wrapped_key bytea NOT NULL, ciphertext bytea NOT NULL, created_at timestamp NOT NULL, updated_at timestamp NOT NULL, PRIMARY KEY (user_id, host), CONSTRAINT user_id FOREIGN KEY (user_id) REFERENCES public.users (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS host_account_usage ( account_id character varying(60) NOT NULL, day character varying(10), day_bytes bigint NOT NULL DEFAULT 0, storage_bytes bigint NOT NULL DEFAULT 0, disabled_at timestamp, disabled_reason text, PRIMARY KEY (account_id) );`,
+		`CREATE TABLE IF NOT EXISTS file_parts ( file_id uuid NOT NULL, host character varying(30) NOT NULL, part integer NOT NULL, mode character varying(10) NOT NULL, name text NOT NULL, size_bytes bigint NOT NULL, PRIMARY KEY (file_id, host, part), CONSTRAINT file_id FOREIGN KEY (file_id) REFERENCES public.files (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS archives ( mirror_id uuid NOT NULL, host character varying(30) NOT NULL, format character varying(10) NOT NULL, name text NOT NULL, size_bytes bigint NOT NULL, sha256 character(64) NOT NULL, created_at timestamp NOT NULL, PRIMARY KEY (mirror_id, host), CONSTRAINT mirror_id FOREIGN KEY (mirror_id) REFERENCES public.mirroring_links (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS sealed_files ( mirror_id uuid NOT NULL, host character varying(30) NOT NULL, name text NOT NULL, plain_name text NOT NULL, plain_size bigint NOT NULL, size_bytes bigint NOT NULL, chunk_size integer NOT NULL, nonce_prefix bytea NOT NULL, locations text NOT NULL, created_at timestamp NOT NULL, PRIMARY KEY (mirror_id, host, name), CONSTRAINT mirror_id FOREIGN KEY (mirror_id) REFERENCES public.mirroring_links (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`ALTER TABLE files ADD COLUMN IF NOT EXISTS sha256 character(64);`,
+		`CREATE INDEX IF NOT EXISTS files_sha256 ON files (sha256);`,
+		`CREATE TABLE IF NOT EXISTS host_files ( file_id uuid NOT NULL, host character varying(30) NOT NULL, remote_id text NOT NULL, created_at timestamp NOT NULL, PRIMARY KEY (file_id, host), CONSTRAINT file_id FOREIGN KEY (file_id) REFERENCES public.files (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE TABLE IF NOT EXISTS file_checks ( mirror_id uuid NOT NULL, host character varying(30) NOT NULL, name text NOT NULL, status character varying(20) NOT NULL, detail text NOT NULL, attempts integer NOT NULL, checked_at timestamp NOT NULL, PRIMARY KEY (mirror_id, host, name), CONSTRAINT mirror_id FOREIGN KEY (mirror_id) REFERENCES public.mirroring_links (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`ALTER TABLE host_files ADD COLUMN IF NOT EXISTS url text NOT NULL DEFAULT '';`,
+		`CREATE TABLE IF NOT EXISTS link_checks ( id uuid NOT NULL, mirror_id uuid NOT NULL, host character varying(30) NOT NULL, level character varying(10) NOT NULL, url text NOT NULL, status character varying(10) NOT NULL, detail text NOT NULL, checked_at timestamp NOT NULL, PRIMARY KEY (id), CONSTRAINT mirror_id FOREIGN KEY (mirror_id) REFERENCES public.mirroring_links (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE INDEX IF NOT EXISTS link_checks_url ON link_checks (url, checked_at);`,
+		`CREATE TABLE IF NOT EXISTS staging_retention ( mirror_id uuid NOT NULL, policy character varying(20) NOT NULL, days integer NOT NULL, uploads integer NOT NULL, expires_at timestamp, deleted_at timestamp, updated_at timestamp NOT NULL, PRIMARY KEY (mirror_id), CONSTRAINT mirror_id FOREIGN KEY (mirror_id) REFERENCES public.mirroring_links (id) MATCH SIMPLE ON UPDATE CASCADE ON DELETE CASCADE );`,
+		`CREATE INDEX IF NOT EXISTS staging_retention_expires_at ON staging_retention (expires_at);`,
 	}
 	for _, query := range queries {
 		_, err = tx.ExecContext(ctx, query)
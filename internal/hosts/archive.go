@@ -0,0 +1,194 @@
+package hosts
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ArchiveFormat is the format of an archive that packs the files of a mirror into a single download
+type ArchiveFormat string
+
+const (
+	ArchiveNone    ArchiveFormat = ""        // The files are only uploaded one by one
+	ArchiveZip     ArchiveFormat = "zip"     // The files are stored as is, most media does not compress
+	ArchiveTarZstd ArchiveFormat = "tar.zst" // A tarball compressed with zstd
+)
+
+var ErrUnknownArchiveFormat = errors.New("unknown archive format")
+
+// ParseArchiveFormat converts the name of an archive format, an empty name means ArchiveNone
+func ParseArchiveFormat(name string) (ArchiveFormat, error) {
+	switch f := ArchiveFormat(name); f {
+	case ArchiveNone, ArchiveZip, ArchiveTarZstd:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownArchiveFormat, name)
+}
+
+// Ext returns the extension of archives in the format
+func (f ArchiveFormat) Ext() string {
+	return "." + string(f)
+}
+
+// Opener opens a staged file at a URI for reading, like httpx.OpenSource
+type Opener func(ctx context.Context, uri string) (io.ReadCloser, error)
+
+// Archive packs files into a single file while it is read.
+// The archive is never held in memory or on disk, each file is read from its source when the archive gets to it.
+type Archive struct {
+	Format ArchiveFormat
+	Files  []File
+
+	mu   sync.Mutex
+	sum  string // Hex SHA-256 of the last archive read to the end
+	size int64
+	done bool
+}
+
+// NewArchive returns an archive of files
+func NewArchive(format ArchiveFormat, files []File) *Archive {
+	return &Archive{Format: format, Files: files}
+}
+
+// ArchiveFile returns a file that is an archive of files, with the combined size of the files as its size.
+// The archive is usually a bit bigger, or smaller when it is compressed.
+func ArchiveFile(name string, format ArchiveFormat, files []File) File {
+	return File{Name: name + format.Ext(), Size: TotalSize(files), Archive: NewArchive(format, files)}
+}
+
+// Checksum returns the hex SHA-256 and the size of the archive, once it was read to the end
+func (a *Archive) Checksum() (sum string, size int64, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sum, a.size, a.done
+}
+
+// Open opens a file for reading. Archives are made from their files, and encrypted files encrypted, as they are read.
+func (f File) Open(ctx context.Context, open Opener) (io.ReadCloser, error) {
+	var r io.ReadCloser
+	if f.Archive != nil {
+		r = f.Archive.open(ctx, open)
+	} else {
+		var err error
+		if r, err = open(ctx, f.URI); err != nil {
+			return nil, err
+		}
+	}
+	if f.Encryption != nil {
+		r = f.Encryption.encrypt(r)
+	}
+	return r, nil
+}
+
+// own returns the file with an archive, upload and remote of its own, so what is learnt uploading it to each host is kept apart
+func (f File) own() File {
+	if f.Archive != nil {
+		f.Archive = NewArchive(f.Archive.Format, f.Archive.Files)
+	}
+	if f.Upload != nil {
+		f.Upload = &Upload{}
+	}
+	if f.Remote != nil {
+		f.Remote = &Remote{Reuse: f.Remote.Reuse}
+	}
+	return f
+}
+
+// open starts writing the archive into a pipe and returns its reading end.
+// Closing the reader stops the archive from being written.
+func (a *Archive) open(ctx context.Context, open Opener) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		h := sha256.New()
+		counter := &countWriter{w: io.MultiWriter(w, h)}
+		err := a.write(ctx, counter, open)
+		if err == nil {
+			a.mu.Lock()
+			a.sum, a.size, a.done = hex.EncodeToString(h.Sum(nil)), counter.n, true
+			a.mu.Unlock()
+		}
+		w.CloseWithError(err) // Fails the read if a file could not be packed
+	}()
+	return r
+}
+
+// write writes the archive to w
+func (a *Archive) write(ctx context.Context, w io.Writer, open Opener) error {
+	switch a.Format {
+	case ArchiveZip:
+		zw := zip.NewWriter(w)
+		for _, f := range a.Files {
+			dst, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Store})
+			if err != nil {
+				return fmt.Errorf("zip header error: %w", err)
+			}
+			if err = copyFile(ctx, dst, f, open); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	case ArchiveTarZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return fmt.Errorf("zstd error: %w", err)
+		}
+		defer zw.Close() // Stops the encoder if a file could not be packed
+		tw := tar.NewWriter(zw)
+		for _, f := range a.Files {
+			err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     f.Name,
+				Size:     f.Size, // Tar needs the size up front, S3 tells us
+				Mode:     0o644,
+				ModTime:  time.Unix(0, 0),
+				Format:   tar.FormatPAX,
+			})
+			if err != nil {
+				return fmt.Errorf("tar header error: %w", err)
+			}
+			if err = copyFile(ctx, tw, f, open); err != nil {
+				return err
+			}
+		}
+		if err = tw.Close(); err != nil {
+			return fmt.Errorf("tar error: %w", err)
+		}
+		return zw.Close()
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownArchiveFormat, a.Format)
+}
+
+// copyFile copies a file from its source into an archive
+func copyFile(ctx context.Context, dst io.Writer, f File, open Opener) error {
+	src, err := open(ctx, f.URI)
+	if err != nil {
+		return fmt.Errorf("error opening %q: %w", f.Name, err)
+	}
+	defer src.Close()
+	if _, err = io.Copy(dst, src); err != nil {
+		return fmt.Errorf("error packing %q: %w", f.Name, err)
+	}
+	return nil
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,126 @@
+package hosts
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stagedFiles returns files with the content of each and an opener that reads them
+func stagedFiles(contents map[string]string) ([]File, Opener) {
+	var files []File
+	for _, name := range []string{"a.txt", "b.png", "missing.bin"} {
+		if content, ok := contents[name]; ok {
+			files = append(files, File{Name: name, URI: name, Size: int64(len(content))})
+		}
+	}
+	open := func(ctx context.Context, uri string) (io.ReadCloser, error) {
+		content, ok := contents[uri]
+		if !ok || uri == "missing.bin" {
+			return nil, errors.New("no such file")
+		}
+		return io.NopCloser(bytes.NewReader([]byte(content))), nil
+	}
+	return files, open
+}
+
+// readArchive reads a whole archive file and checks its checksum
+func readArchive(t *testing.T, f File, open Opener) []byte {
+	t.Helper()
+	r, err := f.Open(context.Background(), open)
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	sum, size, ok := f.Archive.Checksum()
+	require.True(t, ok)
+	want := sha256.Sum256(b)
+	assert.Equal(t, hex.EncodeToString(want[:]), sum)
+	assert.Equal(t, int64(len(b)), size)
+	return b
+}
+
+// go test -v -timeout 30s -run ^TestArchiveZip$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestArchiveZip(t *testing.T) {
+	files, open := stagedFiles(map[string]string{"a.txt": "some notes", "b.png": "png bytes"})
+	f := ArchiveFile("mirror", ArchiveZip, files)
+	assert.Equal(t, "mirror.zip", f.Name)
+	b := readArchive(t, f, open)
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	got := map[string]string{}
+	for _, zf := range zr.File {
+		r, err := zf.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		got[zf.Name] = string(content)
+	}
+	assert.Equal(t, map[string]string{"a.txt": "some notes", "b.png": "png bytes"}, got)
+}
+
+// go test -v -timeout 30s -run ^TestArchiveTarZstd$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestArchiveTarZstd(t *testing.T) {
+	files, open := stagedFiles(map[string]string{"a.txt": "some notes", "b.png": "png bytes"})
+	f := ArchiveFile("mirror", ArchiveTarZstd, files)
+	assert.Equal(t, "mirror.tar.zst", f.Name)
+	b := readArchive(t, f, open)
+
+	zr, err := zstd.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	got := map[string]string{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		got[h.Name] = string(content)
+	}
+	assert.Equal(t, map[string]string{"a.txt": "some notes", "b.png": "png bytes"}, got)
+}
+
+// go test -v -timeout 30s -run ^TestArchiveErrors$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestArchiveErrors(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTarZstd} {
+		t.Run(string(format), func(t *testing.T) {
+			files, open := stagedFiles(map[string]string{"a.txt": "some notes", "missing.bin": ""})
+			f := ArchiveFile("mirror", format, files)
+			r, err := f.Open(context.Background(), open)
+			require.NoError(t, err)
+			defer r.Close()
+			_, err = io.ReadAll(r)
+			assert.ErrorContains(t, err, "missing.bin")
+
+			_, _, ok := f.Archive.Checksum()
+			assert.False(t, ok)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestPlanArchive$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestPlanArchive(t *testing.T) {
+	files := []File{{Name: "a.mp4", Size: 1 << 30}, {Name: "b.mp4", Size: 1 << 30}, {Name: "c.mp4", Size: 1 << 30}}
+	archive := ArchiveFile("mirror", ArchiveZip, files)
+
+	plan, skips := Plan([]string{"bunkr", "pixeldrain"}, []File{archive}, SplitBytes)
+	assert.Equal(t, []Skip{{File: "mirror.zip", Host: "bunkr", Reason: SkipTooLarge}}, skips)
+	require.Len(t, plan["pixeldrain"], 1)
+	assert.NotSame(t, archive.Archive, plan["pixeldrain"][0].Archive)
+}
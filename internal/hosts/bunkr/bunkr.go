@@ -1,5 +1,29 @@
 package bunkr
 
+import (
+	"net/http"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+)
+
 const (
 	baseURI = "https://app.bunkrr.su/api" // base URI for bunkr's API
 )
+
+// client returns the client to send requests to bunkr with
+func client() *http.Client {
+	return httpx.Client("bunkr")
+}
+
+// headers returns the headers the dashboard sends to bunkr's API, with extra headers on top
+func headers(token string, extra http.Header) http.Header {
+	h := httpx.Browser.With(http.Header{
+		"accept":         {"application/json, text/plain, */*"},
+		"token":          {token},
+		"sec-fetch-site": {"same-origin"},
+		"sec-fetch-mode": {"cors"},
+		"sec-fetch-dest": {"empty"},
+		"referer":        {"https://app.bunkrr.su/"},
+	})
+	return httpx.Profile(h).With(extra)
+}
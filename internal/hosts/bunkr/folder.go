@@ -5,10 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"strconv"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
 )
 
 type Folder struct {
@@ -31,7 +32,7 @@ type Folder struct {
 
 // createFolder creates a new folder on Bunkr's API.
 // If successful, it returns the ID of the album
-func createFolder(ctx context.Context, name string, downloadable, public bool) (string, error) {
+func createFolder(ctx context.Context, token, name string, downloadable, public bool) (string, error) {
 	// Create Payload
 	p := &struct {
 		Name        string `json:"name"`
@@ -48,27 +49,18 @@ func createFolder(ctx context.Context, name string, downloadable, public bool) (
 		baseURI+"/albums",
 		bytes.NewBuffer(payload),
 	)
-	req.Header = http.Header{
-		"sec-ch-ua":          {`"Chromium";v="122", "Not(A:Brand";v="24", "Brave";v="122"`},
-		"accept":             {"application/json, text/plain, */*"},
-		"content-type":       {"application/json;charset=UTF-8"},
-		"sec-ch-ua-mobile":   {"?0"},
-		"user-agent":         {userAgent},
-		"token":              {os.Getenv("BUNKR_API_KEY")},
-		"sec-ch-ua-platform": {`"macOS"`},
-		"sec-gpc":            {"1"},
-		"accept-language":    {"en-US,en;q=0.6"},
-		"origin":             {"https://app.bunkrr.su"},
-		"sec-fetch-site":     {"same-origin"},
-		"sec-fetch-mode":     {"cors"},
-		"sec-fetch-dest":     {"empty"},
-		"referer":            {"https://app.bunkrr.su/"},
-		"accept-encoding":    {"gzip, deflate, br"},
-	}
-	resp, err := http.DefaultClient.Do(req)
+	req.Header = headers(token, http.Header{
+		"content-type": {"application/json;charset=UTF-8"},
+		"origin":       {"https://app.bunkrr.su"},
+	})
+	resp, err := client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error with request: %w", err)
 	}
+	if err = hosts.CheckStatus(resp); err != nil {
+		resp.Body.Close()
+		return "", err
+	}
 
 	// Parse Response
 	return parseCreateFolder(resp)
@@ -76,15 +68,13 @@ func createFolder(ctx context.Context, name string, downloadable, public bool) (
 
 func parseCreateFolder(resp *http.Response) (string, error) {
 	// Convert to JSON
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 	response := &struct {
 		Success          bool   `json:"success"`
 		ErrorDescription string `json:"description"`
 		ID               int    `json:"id"`
 	}{}
-	if err := json.Unmarshal(body, response); err != nil {
-		return "", fmt.Errorf("unmarshal error: %w", err)
+	if err := httpx.DecodeJSON(resp, response); err != nil {
+		return "", err
 	}
 
 	// Validate
@@ -95,7 +85,7 @@ func parseCreateFolder(resp *http.Response) (string, error) {
 }
 
 // getFolder returns a folder from Bunkr
-func getFolder(ctx context.Context, id string) (*Folder, error) {
+func getFolder(ctx context.Context, token, id string) (*Folder, error) {
 	// Make request
 	req, _ := http.NewRequestWithContext(
 		ctx,
@@ -103,24 +93,17 @@ func getFolder(ctx context.Context, id string) (*Folder, error) {
 		baseURI+"/albums/0",
 		nil,
 	)
-	req.Header = http.Header{
-		"sec-ch-ua":          {`"Chromium";v="122", "Not(A:Brand";v="24", "Brave";v="122"`},
-		"accept":             {"application/json, text/plain, */*"},
-		"sec-ch-ua-mobile":   {"?0"},
-		"user-agent":         {userAgent},
-		"token":              {os.Getenv("BUNKR_API_KEY")},
-		"sec-ch-ua-platform": {`"macOS"`},
-		"sec-gpc":            {"1"},
-		"accept-language":    {"en-US,en;q=0.6"},
-		"sec-fetch-site":     {"same-origin"},
-		"sec-fetch-mode":     {"cors"},
-		"sec-fetch-dest":     {"empty"},
-		"referer":            {"https://app.bunkrr.su/dashboard"},
-	}
-	resp, err := http.DefaultClient.Do(req)
+	req.Header = headers(token, http.Header{
+		"referer": {"https://app.bunkrr.su/dashboard"},
+	})
+	resp, err := client().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request error: %w", err)
 	}
+	if err = hosts.CheckStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
 
 	// Parse response
 	return parseGetFolder(resp, id)
@@ -129,16 +112,14 @@ func getFolder(ctx context.Context, id string) (*Folder, error) {
 // parseGetFolder parses the response from the `getFolder` function
 func parseGetFolder(resp *http.Response, id string) (*Folder, error) {
 	// Ready body into a JSON
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 	response := &struct {
 		Success    bool     `json:"success"`
 		Folders    []Folder `json:"albums"`
 		Count      int      `json:"count"`
 		HomeDomain string   `json:"homeDomain"`
 	}{}
-	if err := json.Unmarshal(body, response); err != nil {
-		return nil, fmt.Errorf("unmarshal error: %w", err)
+	if err := httpx.DecodeJSON(resp, response); err != nil {
+		return nil, err
 	}
 
 	// Look for the folder
@@ -2,23 +2,71 @@ package bunkr
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"testing"
 
-	"github.com/joho/godotenv"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/hoststest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func init() {
-	// Load Env
-	if err := godotenv.Load("../../../.env"); err != nil {
-		panic(err)
+// go test -v -timeout 30s -run ^TestCreateFolder$ github.com/easymirror/easymirror-backend/internal/hosts/bunkr
+func TestCreateFolder(t *testing.T) {
+	tests := []struct {
+		Token      string
+		FailStatus int
+		Err        bool
+		ErrIs      error
+	}{
+		{Token: "token"},
+		{Token: "wrong", Err: true, ErrIs: hosts.ErrUnauthorized},
+		{Token: "token", FailStatus: http.StatusOK, Err: true}, // API error
+		{Token: "token", FailStatus: http.StatusBadGateway, Err: true},
 	}
-}
 
-func TestCreateFolder(t *testing.T) {
-	id, err := createFolder(context.Background(), "Some Name", true, true)
-	if err != nil {
-		t.Fatalf("error creating folder: %v", err)
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			srv := hoststest.NewServer(t)
+			fake := hoststest.NewBunkr(srv)
+			fake.Token = "token"
+			if test.FailStatus != 0 {
+				fake.Fail(hoststest.BunkrAlbums, test.FailStatus)
+			}
+			srv.Install(t)
+
+			id, err := createFolder(context.Background(), test.Token, "Some Name", true, true)
+			if test.Err {
+				assert.Error(t, err)
+				if test.ErrIs != nil {
+					assert.ErrorIs(t, err, test.ErrIs)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "300000", id)
+			require.Len(t, fake.Albums(), 1)
+			assert.Equal(t, "Some Name", fake.Albums()[0].Name)
+		})
 	}
-	assert.NotEmpty(t, id)
+}
+
+// go test -v -timeout 30s -run ^TestGetFolder$ github.com/easymirror/easymirror-backend/internal/hosts/bunkr
+func TestGetFolder(t *testing.T) {
+	srv := hoststest.NewServer(t)
+	hoststest.NewBunkr(srv)
+	srv.Install(t)
+
+	ctx := context.Background()
+	id, err := createFolder(ctx, "token", "Some Name", true, true)
+	require.NoError(t, err)
+
+	folder, err := getFolder(ctx, "token", id)
+	require.NoError(t, err)
+	assert.Equal(t, "Some Name", folder.Name)
+	assert.Equal(t, "https://bunkr.sk/a/fake300000", folder.Link)
+
+	_, err = getFolder(ctx, "token", "1")
+	assert.Error(t, err)
 }
@@ -0,0 +1,12 @@
+package bunkr
+
+import (
+	"context"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+)
+
+// Probe checks whether a folder or file link on Bunkr still works
+func Probe(ctx context.Context, link string) (hosts.Health, error) {
+	return hosts.ProbeURL(ctx, client(), link)
+}
@@ -3,32 +3,28 @@ package bunkr
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
 
-	"github.com/easymirror/easymirror-backend/internal/common"
-)
-
-const (
-	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.36"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
 )
 
 // Upload upload's files to a folder on Bunkr.
-// If successful, the URI of the folder is returned
-func Upload(ctx context.Context, mirrorID string, sourceURIs []string) (string, error) {
-	if len(sourceURIs) == 0 {
+// If successful, the URI of the folder is returned, with a hosts.UploadError if some of the files could not be uploaded
+func Upload(ctx context.Context, creds hosts.Credentials, mirrorID string, files []hosts.File) (string, error) {
+	if len(files) == 0 {
 		return "", errors.New("no source uri")
 	}
 
 	// Create Folder
 	folderID, err := createFolder(
 		ctx,
+		creds.APIKey,
 		fmt.Sprintf("Mirror %v files", mirrorID),
 		true,
 		true,
@@ -38,30 +34,37 @@ func Upload(ctx context.Context, mirrorID string, sourceURIs []string) (string,
 	}
 
 	// Upload to folder
-	for _, uri := range sourceURIs {
-		if _, err := upload(ctx, folderID, uri); err != nil {
+	failed := map[string]error{}
+	var uploadErr error
+	for _, f := range files {
+		if _, err := upload(ctx, creds.APIKey, folderID, f); err != nil {
 			log.Println("Error uploading file:", err)
+			failed[f.DisplayName()], uploadErr = err, err
 		}
 	}
+	if len(failed) == len(files) {
+		return "", fmt.Errorf("no files uploaded: %w", uploadErr)
+	}
 
 	// Return URI of the folder
-	folder, err := getFolder(ctx, folderID)
+	folder, err := getFolder(ctx, creds.APIKey, folderID)
 	if err != nil {
 		return "", fmt.Errorf("error getting folder")
 	}
-	return folder.Link, nil
+	return folder.Link, hosts.PartialUpload(failed)
 }
 
 // UploadTx upload's files to a folder on Bunkr with a given TX. Adds an entry to the database but does not committ the TX.
-// If successful, the URI of the folder is returned
-func UploadTx(ctx context.Context, tx *sql.Tx, mirrorID string, sourceURIs []string) (string, error) {
-	if len(sourceURIs) == 0 {
+// If successful, the URI of the folder is returned, with a hosts.UploadError if some of the files could not be uploaded
+func UploadTx(ctx context.Context, tx *sql.Tx, creds hosts.Credentials, mirrorID string, files []hosts.File) (string, error) {
+	if len(files) == 0 {
 		return "", errors.New("no source uri")
 	}
 
 	// Create Folder
 	folderID, err := createFolder(
 		ctx,
+		creds.APIKey,
 		fmt.Sprintf("Mirror %v files", mirrorID),
 		true,
 		true,
@@ -71,14 +74,20 @@ func UploadTx(ctx context.Context, tx *sql.Tx, mirrorID string, sourceURIs []str
 	}
 
 	// Upload to folder
-	for _, uri := range sourceURIs {
-		if _, err := upload(ctx, folderID, uri); err != nil {
+	failed := map[string]error{}
+	var uploadErr error
+	for _, f := range files {
+		if _, err := upload(ctx, creds.APIKey, folderID, f); err != nil {
 			log.Println("Error uploading file:", err)
+			failed[f.DisplayName()], uploadErr = err, err
 		}
 	}
+	if len(failed) == len(files) {
+		return "", fmt.Errorf("no files uploaded: %w", uploadErr)
+	}
 
 	// Return URI of the folder
-	folder, err := getFolder(ctx, folderID)
+	folder, err := getFolder(ctx, creds.APIKey, folderID)
 	if err != nil {
 		return "", fmt.Errorf("error getting folder")
 	}
@@ -97,11 +106,11 @@ func UploadTx(ctx context.Context, tx *sql.Tx, mirrorID string, sourceURIs []str
 	if _, err = tx.Exec(statement, mirrorID, folder.Link); err != nil {
 		return "", fmt.Errorf("exec tx error: %w", err)
 	}
-	return folder.Link, nil
+	return folder.Link, hosts.PartialUpload(failed)
 }
 
 // getUploadLink returns a URI where files can be uploaded to
-func getUploadLink(ctx context.Context) (string, error) {
+func getUploadLink(ctx context.Context, token string) (string, error) {
 	// Make request
 	req, _ := http.NewRequestWithContext(
 		ctx,
@@ -109,25 +118,15 @@ func getUploadLink(ctx context.Context) (string, error) {
 		baseURI+"/node",
 		nil,
 	)
-	req.Header = http.Header{
-		"sec-ch-ua":          {`"Chromium";v="122", "Not(A:Brand";v="24", "Brave";v="122"`},
-		"accept":             {"application/json, text/plain, */*"},
-		"sec-ch-ua-mobile":   {"?0"},
-		"user-agent":         {userAgent},
-		"token":              {os.Getenv("BUNKR_API_KEY")},
-		"sec-ch-ua-platform": {`"macOS"`},
-		"sec-gpc":            {"1"},
-		"accept-language":    {"en-US,en;q=0.6"},
-		"sec-fetch-site":     {"same-origin"},
-		"sec-fetch-mode":     {"cors"},
-		"sec-fetch-dest":     {"empty"},
-		"referer":            {"https://app.bunkrr.su/"},
-		"accept-encoding":    {"gzip, deflate, br"},
-	}
-	resp, err := http.DefaultClient.Do(req)
+	req.Header = headers(token, nil)
+	resp, err := client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request error: %w", err)
 	}
+	if err = hosts.CheckStatus(resp); err != nil {
+		resp.Body.Close()
+		return "", err
+	}
 
 	// Parse response
 	return parseGetUploadLink(resp)
@@ -136,15 +135,13 @@ func getUploadLink(ctx context.Context) (string, error) {
 // parseGetUploadLink parses the response from the `getUploadLink` function.
 func parseGetUploadLink(resp *http.Response) (string, error) {
 	// Read Response into JSON
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 	response := &struct {
 		Success   bool   `json:"success"`
 		ErrorDesc string `json:"description"`
 		URL       string `json:"url"`
 	}{}
-	if err := json.Unmarshal(body, response); err != nil {
-		return "", fmt.Errorf("unmarshal error: %w", err)
+	if err := httpx.DecodeJSON(resp, response); err != nil {
+		return "", err
 	}
 
 	// Validate
@@ -154,20 +151,31 @@ func parseGetUploadLink(resp *http.Response) (string, error) {
 	return response.URL, nil
 }
 
-func upload(ctx context.Context, albumID string, presignURI string) (string, error) {
-	// Get upload link
-	uploadLink, err := getUploadLink(ctx)
+// upload upload's a file to an album, part by part if it is split to fit on Bunkr.
+// The link of the last file uploaded is returned.
+func upload(ctx context.Context, token, albumID string, f hosts.File) (string, error) {
+	// Get the file from the presigned URL.
+	source, err := f.Open(ctx, httpx.OpenSource)
 	if err != nil {
-		return "", fmt.Errorf("getUploadLink error: %w", err)
+		return "", fmt.Errorf("error getting body from presigned URL: %w", err)
 	}
+	defer source.Close()
 
-	// Get the file from the presigned URL.
-	req, _ := http.NewRequestWithContext(ctx, "GET", presignURI, nil)
-	resp1, err := http.DefaultClient.Do(req)
+	var link string
+	err = hosts.Send(ctx, f, source, func(ctx context.Context, name string, body io.Reader) (string, error) {
+		link, err = send(ctx, token, albumID, name, body)
+		return link, err
+	})
+	return link, err
+}
+
+// send streams a body into a new file in an album
+func send(ctx context.Context, token, albumID, name string, source io.Reader) (string, error) {
+	// Get upload link
+	uploadLink, err := getUploadLink(ctx, token)
 	if err != nil {
-		return "", fmt.Errorf("error getting body from presigned URL: %w", err)
+		return "", fmt.Errorf("getUploadLink error: %w", err)
 	}
-	defer resp1.Body.Close()
 
 	// We use an io.Pipe and a goroutine for writing from the file/response body
 	// and reading to the request concurrently.
@@ -176,55 +184,40 @@ func upload(ctx context.Context, albumID string, presignURI string) (string, err
 	r, w := io.Pipe()
 	m := multipart.NewWriter(w)
 	go func() {
-		defer w.Close()
-		defer m.Close()
-
-		// Get the filename form the URL
-		name, err := common.FilenameFromURI(presignURI)
-		if err != nil {
-			return
-		}
-
 		part, err := m.CreateFormFile("files[]", name)
-		if err != nil {
-			return
+		if err == nil {
+			_, err = io.Copy(part, source)
 		}
-		if _, err = io.Copy(part, resp1.Body); err != nil {
-			return
+		if err == nil {
+			err = m.Close()
 		}
+		w.CloseWithError(err) // Fails the upload request if the file could not be read
 	}()
 
 	// Make request
-	req, _ = http.NewRequestWithContext(
+	req, _ := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
 		uploadLink,
 		r,
 	)
-	req.Header = http.Header{
-		"sec-ch-ua":          {`"Chromium";v="122", "Not(A:Brand";v="24", "Brave";v="122"`},
-		"albumid":            {albumID},
-		"sec-ch-ua-mobile":   {"?0"},
-		"user-agent":         {userAgent},
-		"Content-Type":       {m.FormDataContentType()},
-		"accept":             {"application/json"},
-		"cache-control":      {"no-cache"},
-		"x-requested-with":   {"XMLHttpRequest"},
-		"token":              {os.Getenv("BUNKR_API_KEY")},
-		"sec-ch-ua-platform": {`"macOS"`},
-		"sec-gpc":            {"1"},
-		"accept-language":    {"en-US,en;q=0.6"},
-		"origin":             {"https://app.bunkrr.su"},
-		"sec-fetch-site":     {"cross-site"},
-		"sec-fetch-mode":     {"cors"},
-		"sec-fetch-dest":     {"empty"},
-		"referer":            {"https://app.bunkrr.su/"},
-		// "accept-encoding":    {"gzip, deflate, br"},
-	}
-	resp, err := http.DefaultClient.Do(req)
+	req.Header = headers(token, http.Header{
+		"albumid":          {albumID},
+		"Content-Type":     {m.FormDataContentType()},
+		"accept":           {"application/json"},
+		"cache-control":    {"no-cache"},
+		"x-requested-with": {"XMLHttpRequest"},
+		"origin":           {"https://app.bunkrr.su"},
+		"sec-fetch-site":   {"cross-site"},
+	})
+	resp, err := client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request error: %w", err)
 	}
+	if err = hosts.CheckStatus(resp); err != nil {
+		resp.Body.Close()
+		return "", err
+	}
 
 	// Parse Response
 	return parseUpload(resp)
@@ -233,8 +226,6 @@ func upload(ctx context.Context, albumID string, presignURI string) (string, err
 // parseUpload parses the response from the `upload` function
 func parseUpload(resp *http.Response) (string, error) {
 	// Convert into JSON
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 	response := &struct {
 		ErrorDescription string `json:"description"`
 		Success          bool   `json:"success"`
@@ -243,8 +234,8 @@ func parseUpload(resp *http.Response) (string, error) {
 			URL  string `json:"url"`
 		} `json:"files"`
 	}{}
-	if err := json.Unmarshal(body, response); err != nil {
-		return "", fmt.Errorf("unmarshal error: %w", err)
+	if err := httpx.DecodeJSON(resp, response); err != nil {
+		return "", err
 	}
 
 	// Validate
@@ -0,0 +1,144 @@
+package hosts
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Reasons a file is skipped on a host
+const (
+	SkipTooLarge    = "too_large"             // The file is bigger than the host's max file size
+	SkipExtension   = "extension_not_allowed" // The host does not take files with this extension
+	SkipUnavailable = "host_unavailable"      // Mirroring to the host is not supported yet
+)
+
+// Capabilities describe what a host accepts
+type Capabilities struct {
+	Host              string   `json:"host"`
+	Name              string   `json:"name"`
+	Mirroring         bool     `json:"mirroring"`                    // Whether files can be mirrored to the host yet
+	MaxFileSize       int64    `json:"max_file_size"`                // In bytes, 0 for no limit
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"` // Only these extensions are taken, any if empty
+	BlockedExtensions []string `json:"blocked_extensions,omitempty"` // These extensions are turned down
+	Folders           bool     `json:"folders"`                      // Whether the files of a mirror are grouped in a folder
+	Reuse             bool     `json:"reuse"`                        // Whether files already on the host can be put in a new folder without uploading them again
+	RetentionDays     int      `json:"retention_days"`               // Days a file is kept without downloads, 0 for forever
+}
+
+// catalog holds the capabilities of every host, as documented by the hosts
+var catalog = map[string]Capabilities{
+	"bunkr": {
+		Host:              "bunkr",
+		Name:              "Bunkr",
+		Mirroring:         true,
+		MaxFileSize:       2 << 30, // 2 GiB
+		BlockedExtensions: []string{".exe", ".bat", ".cmd", ".msi", ".scr", ".sh", ".apk"},
+		Folders:           true,
+	},
+	"pixeldrain": {
+		Host:          "pixeldrain",
+		Name:          "PixelDrain",
+		Mirroring:     true,
+		MaxFileSize:   20 << 30, // 20 GiB
+		Folders:       true,
+		Reuse:         true,
+		RetentionDays: 60,
+	},
+	"gofile": {
+		Host:          "gofile",
+		Name:          "Gofile",
+		Folders:       true,
+		RetentionDays: 10,
+	},
+	"cyberfile": {
+		Host:        "cyberfile",
+		Name:        "Cyberfile",
+		MaxFileSize: 5 << 30, // 5 GiB
+		Folders:     true,
+	},
+}
+
+// Lookup returns the capabilities of a host
+func Lookup(host string) (Capabilities, bool) {
+	c, ok := catalog[host]
+	return c, ok
+}
+
+// All returns the capabilities of every host, sorted by host
+func All() []Capabilities {
+	all := make([]Capabilities, 0, len(catalog))
+	for _, c := range catalog {
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Host < all[j].Host })
+	return all
+}
+
+// Check returns why the host would turn down a file, or an empty string if it takes it
+func (c Capabilities) Check(f File) string {
+	ext := strings.ToLower(path.Ext(f.Name))
+	switch {
+	case !c.Mirroring:
+		return SkipUnavailable
+	case c.MaxFileSize > 0 && f.Size > c.MaxFileSize:
+		return SkipTooLarge
+	case len(c.AllowedExtensions) > 0 && !contains(c.AllowedExtensions, ext):
+		return SkipExtension
+	case contains(c.BlockedExtensions, ext):
+		return SkipExtension
+	}
+	return ""
+}
+
+// contains returns whether an extension is in a list
+func contains(exts []string, ext string) bool {
+	for _, e := range exts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Skip is a file that will not be mirrored to a host
+type Skip struct {
+	File   string `json:"file"`
+	Host   string `json:"host"`
+	Reason string `json:"reason"`
+}
+
+// Plan checks the files against every host before anything is uploaded.
+// It returns the files each host takes, leaving out hosts that take none, and the files that are skipped where.
+// Files that are too large for a host are split into parts for it with split, unless it is SplitNone.
+func Plan(hostNames []string, files []File, split SplitMode) (map[string][]File, []Skip) {
+	plan := make(map[string][]File, len(hostNames))
+	var skips []Skip
+	seen := map[string]bool{}
+	for _, host := range hostNames {
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		c, ok := Lookup(host)
+		if !ok {
+			c = Capabilities{Host: host}
+		}
+		for _, f := range files {
+			reason := c.Check(f)
+			if reason == SkipTooLarge {
+				if parts, ok := c.splitFor(f, split); ok {
+					plan[host] = append(plan[host], parts)
+					continue
+				}
+			}
+			if reason != "" {
+				skips = append(skips, Skip{File: f.DisplayName(), Host: host, Reason: reason})
+				continue
+			}
+			plan[host] = append(plan[host], f.own()) // Each host reads an archive of its own
+		}
+	}
+	return plan, skips
+}
@@ -0,0 +1,60 @@
+package hosts
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestCheck$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestCheck(t *testing.T) {
+	bunkr, _ := Lookup("bunkr")
+	gofile, _ := Lookup("gofile")
+	images := Capabilities{Mirroring: true, AllowedExtensions: []string{".png", ".jpg"}}
+	tests := []struct {
+		Caps     Capabilities
+		File     File
+		Expected string
+	}{
+		{Caps: bunkr, File: File{Name: "video.mp4", Size: 1 << 30}, Expected: ""},
+		{Caps: bunkr, File: File{Name: "video.mp4", Size: 3 << 30}, Expected: SkipTooLarge},
+		{Caps: bunkr, File: File{Name: "setup.EXE", Size: 10}, Expected: SkipExtension},
+		{Caps: images, File: File{Name: "cat.PNG", Size: 10}, Expected: ""},
+		{Caps: images, File: File{Name: "cat.gif", Size: 10}, Expected: SkipExtension},
+		{Caps: images, File: File{Name: "README", Size: 10}, Expected: SkipExtension},
+		{Caps: gofile, File: File{Name: "video.mp4", Size: 10}, Expected: SkipUnavailable},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := test.Caps.Check(test.File)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestPlan$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestPlan(t *testing.T) {
+	small := File{Name: "small.mp4", Size: 1 << 20}
+	large := File{Name: "large.mp4", Size: 3 << 30}
+	tool := File{Name: "tool.exe", Size: 1 << 20}
+
+	plan, skips := Plan([]string{"bunkr", "pixeldrain", "bunkr", "gofile"}, []File{small, large, tool}, SplitNone)
+	assert.Equal(t, map[string][]File{
+		"bunkr":      {small},
+		"pixeldrain": {small, large, tool},
+	}, plan)
+	assert.ElementsMatch(t, []Skip{
+		{File: "large.mp4", Host: "bunkr", Reason: SkipTooLarge},
+		{File: "tool.exe", Host: "bunkr", Reason: SkipExtension},
+		{File: "small.mp4", Host: "gofile", Reason: SkipUnavailable},
+		{File: "large.mp4", Host: "gofile", Reason: SkipUnavailable},
+		{File: "tool.exe", Host: "gofile", Reason: SkipUnavailable},
+	}, skips)
+
+	// Unknown hosts take nothing
+	plan, skips = Plan([]string{"example"}, []File{small}, SplitNone)
+	assert.Empty(t, plan)
+	assert.Equal(t, []Skip{{File: "small.mp4", Host: "example", Reason: SkipUnavailable}}, skips)
+}
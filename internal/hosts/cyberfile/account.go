@@ -1,6 +1,10 @@
 package cyberfile
 
-import "context"
+import (
+	"context"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+)
 
 type Account interface {
 	Username() string                                   // Username is a getter function that returns the username
@@ -21,6 +25,11 @@ func NewAccount(username, password string) Account {
 	return newAccount(username, password)
 }
 
+// FromCredentials creates a new account from stored host credentials
+func FromCredentials(creds hosts.Credentials) Account {
+	return newAccount(creds.Username, creds.Password)
+}
+
 // newAccount creates a new account with given credentials
 func newAccount(username, password string) *account {
 	return &account{
@@ -2,13 +2,13 @@ package cyberfile
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"os"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
 )
 
 // getAccessToken gets and sets an access token to the account
@@ -19,8 +19,8 @@ func (a *account) GetAccessToken(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("url parse error: %w", err)
 	}
 	q := u.Query()
-	q.Set("username", os.Getenv("CYBERFILE_USERNAME"))
-	q.Set("password", os.Getenv("CYBERFILE_PASSWORD"))
+	q.Set("username", a.username)
+	q.Set("password", a.password)
 	u.RawQuery = q.Encode()
 
 	// Make request
@@ -30,10 +30,14 @@ func (a *account) GetAccessToken(ctx context.Context) (string, error) {
 		u.String(),
 		nil,
 	)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error with request: %w", err)
 	}
+	if err = hosts.CheckStatus(resp); err != nil {
+		resp.Body.Close()
+		return "", err
+	}
 
 	// Parse Response
 	return parseAccessToken(resp, a)
@@ -41,8 +45,6 @@ func (a *account) GetAccessToken(ctx context.Context) (string, error) {
 
 // parseAccessToken parses the response from the getAccessToken token
 func parseAccessToken(resp *http.Response, a *account) (string, error) {
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 	response := &struct {
 		Data struct {
 			AccessToken string `json:"access_token"`
@@ -53,8 +55,8 @@ func parseAccessToken(resp *http.Response, a *account) (string, error) {
 		Error    string `json:"response"`
 	}{}
 
-	if err := json.Unmarshal(body, response); err != nil {
-		return "", fmt.Errorf("unmarshal error: %w", err)
+	if err := httpx.DecodeJSON(resp, response); err != nil {
+		return "", err
 	}
 
 	// Parse and validate
@@ -2,34 +2,53 @@ package cyberfile
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"net/http"
 	"testing"
 
-	"github.com/joho/godotenv"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/hoststest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func init() {
-	// Load Env
-	if err := godotenv.Load("../../../.env"); err != nil {
-		panic(err)
-	}
-}
-
 // go test -v -timeout 30s -run ^TestGetAuthToken$ github.com/easymirror/easymirror-backend/internal/hosts/cyberfile
 func TestGetAuthToken(t *testing.T) {
-	// Create new account
-	a := account{
-		username: os.Getenv("CYBERFILE_USERNAME"),
-		password: os.Getenv("CYBERFILE_PASSWORD"),
+	tests := []struct {
+		Password   string
+		FailStatus int
+		Err        bool
+		ErrIs      error
+	}{
+		{Password: "secret"},
+		{Password: "wrong", Err: true},
+		{Password: "secret", FailStatus: http.StatusForbidden, Err: true, ErrIs: hosts.ErrUnauthorized},
+		{Password: "secret", FailStatus: http.StatusInternalServerError, Err: true},
 	}
 
-	// Run test
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	token, err := a.GetAccessToken(ctx)
-	if err != nil {
-		t.Fatalf("Failed to get token: %v", err)
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			srv := hoststest.NewServer(t)
+			fake := hoststest.NewCyberfile(srv)
+			fake.Username, fake.Password = "user", "secret"
+			if test.FailStatus != 0 {
+				fake.Fail(hoststest.CyberfileAuthorize, test.FailStatus)
+			}
+			srv.Install(t)
+
+			a := account{username: "user", password: test.Password}
+			token, err := a.GetAccessToken(context.Background())
+			if test.Err {
+				assert.Error(t, err)
+				if test.ErrIs != nil {
+					assert.ErrorIs(t, err, test.ErrIs)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, token)
+			assert.Equal(t, token, a.AccessToken())
+			assert.Equal(t, "1234", a.AccountID())
+		})
 	}
-	assert.NotEmpty(t, token)
 }
@@ -1,5 +1,16 @@
 package cyberfile
 
+import (
+	"net/http"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+)
+
 const (
 	baseURI = "https://api.cyberfile.me/api/v2" // Base uri for API
 )
+
+// client returns the client to send requests to cyberfile with
+func client() *http.Client {
+	return httpx.Client("cyberfile")
+}
@@ -2,12 +2,13 @@ package cyberfile
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
 )
 
 type folderResponse struct {
@@ -53,10 +54,14 @@ func createFolder(ctx context.Context, a Account, mirrorID string) (string, erro
 		u.String(),
 		nil,
 	)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error with request: %w", err)
 	}
+	if err = hosts.CheckStatus(resp); err != nil {
+		resp.Body.Close()
+		return "", err
+	}
 
 	// Parse response
 	return parseCreateFolder(resp)
@@ -65,11 +70,9 @@ func createFolder(ctx context.Context, a Account, mirrorID string) (string, erro
 // createFolder parses the response from the createFolder function
 func parseCreateFolder(resp *http.Response) (string, error) {
 	// Read the body into a JSON struct
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 	response := &folderResponse{}
-	if err := json.Unmarshal(body, response); err != nil {
-		return "", fmt.Errorf("umarshal error: %w", err)
+	if err := httpx.DecodeJSON(resp, response); err != nil {
+		return "", err
 	}
 
 	// Parse & Validate response
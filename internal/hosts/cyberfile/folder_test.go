@@ -2,32 +2,32 @@ package cyberfile
 
 import (
 	"context"
-	"os"
 	"testing"
 
-	"github.com/joho/godotenv"
+	"github.com/easymirror/easymirror-backend/internal/hosts/hoststest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func init() {
-	// Load Env
-	if err := godotenv.Load("../../../.env"); err != nil {
-		panic(err)
-	}
-}
-
 // go test -v -timeout 30s -run ^TestCreateFolder$ github.com/easymirror/easymirror-backend/internal/hosts/cyberfile
 func TestCreateFolder(t *testing.T) {
+	srv := hoststest.NewServer(t)
+	fake := hoststest.NewCyberfile(srv)
+	srv.Install(t)
+
 	// Create account
-	a := newAccount(os.Getenv("CYBERFILE_USERNAME"), os.Getenv("CYBERFILE_PASSWORD"))
+	a := newAccount("user", "secret")
 	if _, err := a.GetAccessToken(context.Background()); err != nil {
 		t.Fatalf("Failed to get access token: %v", err)
 	}
 
 	// Run Test
 	id, err := createFolder(context.Background(), a, "some_mirror_id")
-	if err != nil {
-		t.Fatalf("Failed to create folder: %v", err)
-	}
-	assert.NotEmpty(t, id)
+	require.NoError(t, err)
+	assert.Equal(t, "1", id)
+	assert.Equal(t, []string{"Mirror some_mirror_id files"}, fake.Folders())
+
+	// An account without a token is turned down
+	_, err = createFolder(context.Background(), newAccount("user", "secret"), "some_mirror_id")
+	assert.Error(t, err)
 }
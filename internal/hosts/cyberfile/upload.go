@@ -1,9 +1,13 @@
 package cyberfile
 
-import "context"
+import (
+	"context"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+)
 
 // upload upload's a given file to Cyberfile's API.
-func upload(ctx context.Context, a Account, mirrorID, presignURI, folderID string) {
+func upload(ctx context.Context, a Account, mirrorID string, f hosts.File, folderID string) {
 	// TODO: Prepare file
 	// TODO: Make request
 	// TODO: Parse response
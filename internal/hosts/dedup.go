@@ -0,0 +1,101 @@
+package hosts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+)
+
+// Remote is the copy of a file's content on a host, so content that is already there is not uploaded again
+type Remote struct {
+	Reuse string // ID of the same content on the host from an earlier mirror, empty to upload it
+
+	mu  sync.Mutex
+	id  string // ID of the file on the host once it was uploaded or reused
+	url string // Public URL of the file on the host
+}
+
+// ID returns the ID of the file on the host, once it was uploaded or reused
+func (r *Remote) ID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+// URL returns the public URL of the file on the host, once it was uploaded or reused
+func (r *Remote) URL() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.url
+}
+
+// SetID keeps the ID and public URL of the file on the host, for host adapters to call once the file is there
+func (r *Remote) SetID(id, url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.id, r.url = id, url
+}
+
+// Reusable returns whether the content of a file can be reused on a host, which takes its hash before it is uploaded
+func (f File) Reusable() bool {
+	return f.SHA256 != "" && f.Recordable()
+}
+
+// Recordable returns whether the copy of a file on a host can be recorded once it is there, for later mirrors to reuse.
+// Only whole files are, archives and encrypted files differ with every mirror.
+func (f File) Recordable() bool {
+	return f.Remote != nil && f.Archive == nil && f.Encryption == nil && f.Split == SplitNone
+}
+
+// ContentHash returns the hex SHA-256 of a file's content, known before it was uploaded or learnt while it was
+func (f File) ContentHash() string {
+	if f.SHA256 != "" {
+		return f.SHA256
+	}
+	return f.Upload.SHA256()
+}
+
+// Hash returns the hex SHA-256 of a file's content, reading it from its source
+func Hash(ctx context.Context, f File, open Opener) (string, error) {
+	source, err := f.Open(ctx, open)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, source); err != nil {
+		return "", fmt.Errorf("read error: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashingReader hashes what is read through it
+type hashingReader struct {
+	r io.Reader
+	h hash.Hash
+	n int64
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{r: r, h: sha256.New()}
+}
+
+func (r *hashingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.h.Write(b[:n])
+	r.n += int64(n)
+	return n, err
+}
+
+// sum returns the hex SHA-256 of what was read, or empty if not all size bytes were.
+// A nil reader hashed nothing.
+func (r *hashingReader) sum(size int64) string {
+	if r == nil || r.n != size {
+		return ""
+	}
+	return hex.EncodeToString(r.h.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package hosts
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -timeout 30s -run ^TestHash$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestHash(t *testing.T) {
+	files, open := stagedFiles(map[string]string{"a.txt": "some notes"})
+	sum, err := Hash(context.Background(), files[0], open)
+	require.NoError(t, err)
+	want := sha256.Sum256([]byte("some notes"))
+	assert.Equal(t, hex.EncodeToString(want[:]), sum)
+
+	files[0].URI += ".missing"
+	_, err = Hash(context.Background(), files[0], open)
+	assert.Error(t, err)
+}
+
+// go test -v -timeout 30s -run ^TestReusable$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestReusable(t *testing.T) {
+	f := File{Name: "a.txt", SHA256: "some-sum", Remote: &Remote{}}
+	encrypted, err := Encrypt(f, make([]byte, 32))
+	require.NoError(t, err)
+
+	tests := []struct {
+		File     File
+		Expected bool
+	}{
+		{File: f, Expected: true},
+		{File: File{Name: "a.txt", Remote: &Remote{}}, Expected: false}, // Not hashed
+		{File: File{Name: "a.txt", SHA256: "some-sum", Remote: &Remote{}, Split: SplitBytes}, Expected: false},
+		{File: ArchiveFile("mirror", ArchiveZip, []File{f}), Expected: false},
+		{File: encrypted, Expected: false},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := test.File.Reusable()
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestSendHash$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestSendHash(t *testing.T) {
+	content := strings.Repeat("0123456789", 30000)
+	files, open := stagedFiles(map[string]string{"a.txt": content})
+	sum := sha256.Sum256([]byte(content))
+	encrypted, err := Encrypt(files[0], make([]byte, 32))
+	require.NoError(t, err)
+
+	tests := []struct {
+		File     File
+		Expected string
+	}{
+		{File: files[0], Expected: hex.EncodeToString(sum[:])},
+		{File: File{Name: "a.txt", URI: "a.txt", Size: files[0].Size, Split: SplitBytes, PartSize: 100000}, Expected: hex.EncodeToString(sum[:])},
+		{File: File{Name: "a.txt", URI: "a.txt", Size: files[0].Size, Split: SplitZip, PartSize: 100000}, Expected: hex.EncodeToString(sum[:])},
+		{File: File{Name: "a.txt", URI: "a.txt", Size: files[0].Size + 1}, Expected: ""}, // Not all of it was read
+		{File: encrypted, Expected: ""},
+		{File: ArchiveFile("mirror", ArchiveZip, files), Expected: ""},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			f := newFakeUploads(t).send(t, test.File.own(), open)
+			assert.Equal(t, test.Expected, f.Upload.SHA256())
+			assert.Equal(t, test.Expected, f.ContentHash())
+		})
+	}
+}
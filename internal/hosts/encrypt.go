@@ -0,0 +1,43 @@
+package hosts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/easymirror/easymirror-backend/internal/seal"
+)
+
+// Encryption encrypts a file on its way to a host, see package seal
+type Encryption struct {
+	Key       []byte
+	Params    seal.Params
+	PlainName string // Name of the file before it was encrypted
+	PlainSize int64
+}
+
+// Encrypt returns a file that is encrypted with key while it is read.
+// It gets a random name, so hosts learn nothing but its size.
+func Encrypt(f File, key []byte) (File, error) {
+	p, err := seal.NewParams()
+	if err != nil {
+		return f, err
+	}
+	name := make([]byte, 8)
+	if _, err = rand.Read(name); err != nil {
+		return f, fmt.Errorf("rand error: %w", err)
+	}
+	f.Encryption = &Encryption{Key: key, Params: p, PlainName: f.Name, PlainSize: f.Size}
+	f.Name = hex.EncodeToString(name) + ".enc"
+	f.Size = p.SealedSize(f.Size)
+	return f, nil
+}
+
+// encrypt returns r encrypted, closing r when it is closed
+func (e *Encryption) encrypt(r io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{seal.Encrypt(e.Key, e.Params, r), r}
+}
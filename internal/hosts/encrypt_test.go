@@ -0,0 +1,49 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/easymirror/easymirror-backend/internal/seal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -timeout 30s -run ^TestEncrypt$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestEncrypt(t *testing.T) {
+	files, open := stagedFiles(map[string]string{"a.txt": strings.Repeat("some notes", 10000)})
+	key, err := seal.NewKey()
+	require.NoError(t, err)
+
+	f, err := Encrypt(files[0], key)
+	require.NoError(t, err)
+	f.Upload = &Upload{}
+	assert.NotContains(t, f.Name, "a.txt")
+	assert.Equal(t, "a.txt", f.Encryption.PlainName)
+	assert.Equal(t, int64(100000), f.Encryption.PlainSize)
+	assert.Equal(t, f.Encryption.Params.SealedSize(100000), f.Size)
+
+	// Split the encrypted file, like a host with a small size limit would
+	f.Split, f.PartSize = SplitBytes, 40000
+	source, err := f.Open(context.Background(), open)
+	require.NoError(t, err)
+	defer source.Close()
+	var sealed []byte
+	err = Send(context.Background(), f, source, func(ctx context.Context, name string, body io.Reader) (string, error) {
+		b, err := io.ReadAll(body)
+		sealed = append(sealed, b...)
+		return "https://host.test/" + name, err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://host.test/" + f.Name + ".001", "https://host.test/" + f.Name + ".002", "https://host.test/" + f.Name + ".003"}, f.Upload.Locations())
+
+	plain, err := io.ReadAll(seal.Decrypt(key, f.Encryption.Params, bytes.NewReader(sealed)))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("some notes", 10000), string(plain))
+
+	// Each host keeps its own locations
+	assert.Empty(t, f.own().Upload.Locations())
+}
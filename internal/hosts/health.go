@@ -0,0 +1,37 @@
+package hosts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Health is what probing a link on a host found
+type Health string
+
+const (
+	HealthAlive   Health = "alive"
+	HealthDead    Health = "dead"    // Deleted, taken down or expired
+	HealthUnknown Health = "unknown" // The host could not tell, e.g. because it is down
+)
+
+// ProbeURL requests a URL and tells from the status of the response whether what is there still works
+func ProbeURL(ctx context.Context, client *http.Client, url string) (Health, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return HealthUnknown, fmt.Errorf("new request error: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return HealthUnknown, fmt.Errorf("request error: %w", err)
+	}
+	resp.Body.Close() // Only the status matters
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return HealthAlive, nil
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone, resp.StatusCode == http.StatusUnavailableForLegalReasons:
+		return HealthDead, nil
+	}
+	return HealthUnknown, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
@@ -0,0 +1,138 @@
+// Package hosts holds what the host packages have in common
+package hosts
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnauthorized  = errors.New("host rejected the credentials")
+	ErrQuotaExceeded = errors.New("host account is out of quota")
+)
+
+// Credentials are used to log in to a host.
+// Hosts that work with API keys only use APIKey, others use Username and Password.
+type Credentials struct {
+	APIKey   string `json:"api_key,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// File is a staged file that can be mirrored to a host
+type File struct {
+	ID   string // ID of the file's record, empty for files staged before they had one
+	Name string // Name the file should have on the host
+	URI  string // Presigned URI to download the file from
+	Size int64  // Size of the file in bytes, 0 if unknown
+
+	SHA256 string  // Hex SHA-256 of the content, empty if it was not hashed yet, see File.ContentHash
+	Remote *Remote // Set for files whose content can be reused on the host they are planned for, see File.Reusable
+
+	Split    SplitMode // How the file is split on the host it is planned for, SplitNone to upload it whole
+	PartSize int64     // Max size of each part of a split file
+
+	Archive    *Archive    // Set for archives of other files, which have no URI, see File.Open
+	Encryption *Encryption // Set for files that are encrypted on their way to the host, see Encrypt
+	Upload     *Upload     // Set to learn where the file was uploaded to, see Send
+}
+
+// Upload keeps where a file was uploaded to on a host, and the hash of its content once it was read
+type Upload struct {
+	mu        sync.Mutex
+	locations []string // URLs of the uploads, in order
+	sha256    string   // Hex SHA-256 of the content that was read while it was uploaded
+}
+
+// Locations returns the URLs the file was uploaded to, in order, once it was uploaded
+func (u *Upload) Locations() []string {
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.locations
+}
+
+// SHA256 returns the hex SHA-256 of the file's content as it was read while it was uploaded,
+// empty if it was not uploaded or is not a plain file
+func (u *Upload) SHA256() string {
+	if u == nil {
+		return ""
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.sha256
+}
+
+// finish keeps the URLs the file was uploaded to and the hash of its content, empty if it was not hashed
+func (u *Upload) finish(locations []string, sha256 string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.locations, u.sha256 = locations, sha256
+}
+
+// DisplayName returns the name of the file to show users, which is not the name on the host for encrypted files
+func (f File) DisplayName() string {
+	if f.Encryption != nil {
+		return f.Encryption.PlainName
+	}
+	return f.Name
+}
+
+// UploadError is returned by the host packages when some of the files could not be uploaded to a host.
+// The others were uploaded, so the link to them is returned along with it.
+type UploadError struct {
+	Failed map[string]error // Why each file that failed did, by the name it was shown with
+}
+
+// PartialUpload returns an UploadError for the files that failed, nil if none did
+func PartialUpload(failed map[string]error) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return &UploadError{Failed: failed}
+}
+
+func (e *UploadError) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%v files were not uploaded: %v", len(names), strings.Join(names, ", "))
+}
+
+// Unwrap returns why the files failed, so errors.Is tells e.g. a full account apart
+func (e *UploadError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// CheckStatus returns an error for responses that mean the account can't be used,
+// so callers can tell bad credentials and full accounts apart from other failures.
+func CheckStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: status %v", ErrUnauthorized, resp.StatusCode)
+	case http.StatusRequestEntityTooLarge, http.StatusInsufficientStorage, http.StatusPaymentRequired:
+		return fmt.Errorf("%w: status %v", ErrQuotaExceeded, resp.StatusCode)
+	}
+	return nil
+}
+
+// TotalSize returns the combined size of files
+func TotalSize(files []File) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
@@ -0,0 +1,194 @@
+package hoststest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Hostnames of Bunkr's API and of the node the fake hands out for uploads
+const (
+	BunkrAPIHost  = "app.bunkrr.su"
+	BunkrNodeHost = "node1.bunkr.test"
+)
+
+// Endpoints of the Bunkr fake, to make them fail with Bunkr.Fail
+const (
+	BunkrAlbums = "albums" // Creating and listing albums
+	BunkrNode   = "node"   // Getting the node to upload to
+	BunkrUpload = "upload" // Uploading to the node
+)
+
+// BunkrAlbum is an album created on the fake
+type BunkrAlbum struct {
+	ID         int
+	Name       string
+	Identifier string
+	Files      map[string][]byte // Uploaded files by name
+}
+
+// Bunkr fakes Bunkr's dashboard API and an upload node
+type Bunkr struct {
+	Token string // Token the fake accepts, any token if empty
+
+	mu     sync.Mutex
+	albums []*BunkrAlbum
+	fails  map[string]int
+}
+
+// NewBunkr registers a Bunkr fake on the server
+func NewBunkr(s *Server) *Bunkr {
+	b := &Bunkr{fails: map[string]int{}}
+	api := http.NewServeMux()
+	api.HandleFunc("/api/albums", b.createAlbum)
+	api.HandleFunc("/api/albums/0", b.listAlbums)
+	api.HandleFunc("/api/node", b.node)
+	node := http.NewServeMux()
+	node.HandleFunc("/api/upload", b.upload)
+	s.Handle(BunkrAPIHost, api)
+	s.Handle(BunkrNodeHost, node)
+	return b
+}
+
+// Fail makes an endpoint answer with a status from now on.
+// http.StatusOK answers with `"success": false`, like Bunkr does for most errors.
+func (b *Bunkr) Fail(endpoint string, status int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fails[endpoint] = status
+}
+
+// Albums returns the albums created on the fake
+func (b *Bunkr) Albums() []BunkrAlbum {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	albums := make([]BunkrAlbum, len(b.albums))
+	for i, a := range b.albums {
+		albums[i] = *a
+	}
+	return albums
+}
+
+// check answers the request if it is not authorized or its endpoint should fail
+func (b *Bunkr) check(w http.ResponseWriter, r *http.Request, endpoint string) bool {
+	b.mu.Lock()
+	status, fail := b.fails[endpoint]
+	b.mu.Unlock()
+	switch {
+	case b.Token != "" && r.Header.Get("token") != b.Token:
+		writeJSON(w, http.StatusForbidden, map[string]any{"success": false, "description": "Invalid token provided."})
+	case fail && status == http.StatusOK:
+		writeJSON(w, http.StatusOK, map[string]any{"success": false, "description": "Something went wrong."})
+	case fail:
+		writeJSON(w, status, map[string]any{"success": false, "description": http.StatusText(status)})
+	default:
+		return true
+	}
+	return false
+}
+
+func (b *Bunkr) createAlbum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !b.check(w, r, BunkrAlbums) {
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "description": "No album name specified."})
+		return
+	}
+
+	b.mu.Lock()
+	id := 300000 + len(b.albums)
+	b.albums = append(b.albums, &BunkrAlbum{
+		ID:         id,
+		Name:       body.Name,
+		Identifier: fmt.Sprintf("fake%v", id),
+		Files:      map[string][]byte{},
+	})
+	b.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "id": id})
+}
+
+func (b *Bunkr) listAlbums(w http.ResponseWriter, r *http.Request) {
+	if !b.check(w, r, BunkrAlbums) {
+		return
+	}
+	b.mu.Lock()
+	albums := make([]map[string]any, len(b.albums))
+	for i, a := range b.albums {
+		albums[i] = map[string]any{
+			"id":         a.ID,
+			"name":       a.Name,
+			"identifier": a.Identifier,
+			"enabled":    1,
+			"download":   true,
+			"public":     true,
+			"uploads":    len(a.Files),
+		}
+	}
+	b.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success":    true,
+		"albums":     albums,
+		"count":      len(albums),
+		"homeDomain": "https://bunkr.sk",
+	})
+}
+
+func (b *Bunkr) node(w http.ResponseWriter, r *http.Request) {
+	if !b.check(w, r, BunkrNode) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "url": "https://" + BunkrNodeHost + "/api/upload"})
+}
+
+func (b *Bunkr) upload(w http.ResponseWriter, r *http.Request) {
+	if !b.check(w, r, BunkrUpload) {
+		return
+	}
+	file, header, err := r.FormFile("files[]")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "description": "No files."})
+		return
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"success": false, "description": "Could not read the file."})
+		return
+	}
+
+	b.mu.Lock()
+	var album *BunkrAlbum
+	for _, a := range b.albums {
+		if fmt.Sprint(a.ID) == r.Header.Get("albumid") {
+			album = a
+		}
+	}
+	if album != nil {
+		album.Files[header.Filename] = content
+	}
+	b.mu.Unlock()
+	if album == nil {
+		writeJSON(w, http.StatusOK, map[string]any{"success": false, "description": "Album doesn't exist."})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"files":   []map[string]any{{"name": header.Filename, "url": "https://" + BunkrNodeHost + "/" + header.Filename}},
+	})
+}
+
+// writeJSON answers with a JSON body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,109 @@
+package hoststest
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// CyberfileHost is the hostname of Cyberfile's API
+const CyberfileHost = "api.cyberfile.me"
+
+// Endpoints of the Cyberfile fake, to make them fail with Cyberfile.Fail
+const (
+	CyberfileAuthorize    = "authorize"     // Getting an access token
+	CyberfileCreateFolder = "folder/create" // Creating a folder
+)
+
+// Cyberfile fakes Cyberfile's v2 API
+type Cyberfile struct {
+	Username, Password string // Login the fake accepts, any login if empty
+
+	mu      sync.Mutex
+	tokens  map[string]bool
+	folders []string
+	fails   map[string]int
+}
+
+// NewCyberfile registers a Cyberfile fake on the server
+func NewCyberfile(s *Server) *Cyberfile {
+	c := &Cyberfile{tokens: map[string]bool{}, fails: map[string]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/authorize", c.authorize)
+	mux.HandleFunc("/api/v2/folder/create", c.createFolder)
+	s.Handle(CyberfileHost, mux)
+	return c
+}
+
+// Fail makes an endpoint answer with a status from now on.
+// http.StatusOK answers with an error `_status`, like Cyberfile does.
+func (c *Cyberfile) Fail(endpoint string, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fails[endpoint] = status
+}
+
+// Folders returns the names of the folders created on the fake
+func (c *Cyberfile) Folders() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.folders...)
+}
+
+// fail answers the request if its endpoint should fail
+func (c *Cyberfile) fail(w http.ResponseWriter, endpoint string) bool {
+	c.mu.Lock()
+	status, fail := c.fails[endpoint]
+	c.mu.Unlock()
+	if fail {
+		writeJSON(w, status, map[string]any{"_status": "error", "response": http.StatusText(status)})
+	}
+	return fail
+}
+
+func (c *Cyberfile) authorize(w http.ResponseWriter, r *http.Request) {
+	if c.fail(w, CyberfileAuthorize) {
+		return
+	}
+	q := r.URL.Query()
+	if c.Username != "" && (q.Get("username") != c.Username || q.Get("password") != c.Password) {
+		writeJSON(w, http.StatusOK, map[string]any{"_status": "error", "response": "Could not authenticate user."})
+		return
+	}
+
+	c.mu.Lock()
+	token := fmt.Sprintf("token%04d", len(c.tokens)+1)
+	c.tokens[token] = true
+	c.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":    map[string]any{"access_token": token, "account_id": "1234"},
+		"_status": "success",
+	})
+}
+
+func (c *Cyberfile) createFolder(w http.ResponseWriter, r *http.Request) {
+	if c.fail(w, CyberfileCreateFolder) {
+		return
+	}
+	q := r.URL.Query()
+	c.mu.Lock()
+	valid := c.tokens[q.Get("access_token")]
+	if valid {
+		c.folders = append(c.folders, q.Get("folder_name"))
+	}
+	id := len(c.folders)
+	c.mu.Unlock()
+	if !valid {
+		writeJSON(w, http.StatusOK, map[string]any{"_status": "error", "response": "Could not validate access_token and account_id, please reauthenticate or try again."})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data": map[string]any{
+			"id":         fmt.Sprint(id),
+			"folderName": q.Get("folder_name"),
+			"isPublic":   q.Get("is_public"),
+			"url_folder": fmt.Sprintf("https://cyberfile.me/folder/fake%v", id),
+		},
+		"_status": "success",
+	})
+}
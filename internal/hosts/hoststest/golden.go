@@ -0,0 +1,104 @@
+package hoststest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+)
+
+// RecordEnv is the env variable that switches Server.Golden from replaying fixtures to recording them
+const RecordEnv = "HOSTS_RECORD"
+
+// Golden answers the requests to any hostname without a fake with the golden fixtures of a host in dir/<host>,
+// and installs the server like Install does.
+//
+// With HOSTS_RECORD=1 the requests go to the real host instead and its responses are saved into dir/<host>
+// as new fixtures, without secrets. The test then needs real credentials. Files added with AddSource are
+// still served by the server. Golden returns whether it is recording.
+func (s *Server) Golden(tb testing.TB, host, dir string) bool {
+	if os.Getenv(RecordEnv) != "" {
+		base := httpx.NewTransport()
+		base.DialContext = s.dialer("")
+		install(tb, httpx.NewFactory(httpx.Options{Base: base, Recorder: httpx.NewRecorder(dir)}))
+		return true
+	}
+
+	recs, err := LoadRecordings(filepath.Join(dir, host))
+	if err != nil {
+		tb.Fatalf("hoststest: %v", err)
+	}
+	s.Handle("*", Replay(tb, recs))
+	s.Install(tb)
+	return false
+}
+
+// LoadRecordings returns the recordings saved in dir, in the order they were made
+func LoadRecordings(dir string) ([]httpx.Recording, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no recordings in %v", dir)
+	}
+	sort.Strings(paths) // Names start with the time of the request
+
+	recs := make([]httpx.Recording, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var rec httpx.Recording
+		if err = json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// Replay answers each request with the first unused recording of the same method and path.
+// Requests without a recording fail the test.
+func Replay(tb testing.TB, recs []httpx.Recording) http.Handler {
+	var mu sync.Mutex
+	used := make([]bool, len(recs))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, rec := range recs {
+			if used[i] || rec.Method != r.Method || recordedPath(rec) != r.URL.Path {
+				continue
+			}
+			used[i] = true
+			for k, v := range rec.ResponseHeaders {
+				if !strings.EqualFold(k, "Content-Length") && !strings.EqualFold(k, "Content-Encoding") {
+					w.Header()[k] = v
+				}
+			}
+			w.WriteHeader(rec.Status)
+			w.Write([]byte(rec.Body))
+			return
+		}
+		tb.Errorf("hoststest: no recording left for %v %v", r.Method, r.URL.Path)
+		http.Error(w, "no recording", http.StatusNotImplemented)
+	})
+}
+
+// recordedPath returns the path of a recorded URL
+func recordedPath(rec httpx.Recording) string {
+	u, err := url.Parse(rec.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
@@ -0,0 +1,203 @@
+package hoststest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PixelDrainHost is the hostname of PixelDrain's API
+const PixelDrainHost = "pixeldrain.com"
+
+// Endpoints of the PixelDrain fake, to make them fail with PixelDrain.Fail
+const (
+	PixelDrainFile = "file" // Uploading a file
+	PixelDrainList = "list" // Creating a list of files
+)
+
+// PixelDrainFolder is a list of files created on the fake
+type PixelDrainFolder struct {
+	ID    string
+	Title string
+	Files []string // IDs of the files
+}
+
+// PixelDrain fakes PixelDrain's file and list API
+type PixelDrain struct {
+	APIKey string // API key the fake accepts, any key if empty
+
+	mu    sync.Mutex
+	files map[string][]byte // Uploaded files by ID
+	names map[string]string // Names of the uploaded files by ID
+	lists []PixelDrainFolder
+	fails map[string]int
+	count int // Uploads so far, to number the IDs
+}
+
+// NewPixelDrain registers a PixelDrain fake on the server
+func NewPixelDrain(s *Server) *PixelDrain {
+	p := &PixelDrain{
+		files: map[string][]byte{},
+		names: map[string]string{},
+		fails: map[string]int{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/file", p.upload)
+	mux.HandleFunc("/api/file/", p.download)
+	mux.HandleFunc("/api/list", p.createList)
+	mux.HandleFunc("/api/list/", p.getList)
+	s.Handle(PixelDrainHost, mux)
+	return p
+}
+
+// Fail makes an endpoint answer with a status from now on
+func (p *PixelDrain) Fail(endpoint string, status int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fails[endpoint] = status
+}
+
+// File returns the name and content of an uploaded file
+func (p *PixelDrain) File(id string) (name string, content []byte, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	content, ok = p.files[id]
+	return p.names[id], content, ok
+}
+
+// Folders returns the lists of files created on the fake
+func (p *PixelDrain) Folders() []PixelDrainFolder {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PixelDrainFolder(nil), p.lists...)
+}
+
+// check answers the request if it is not authorized or its endpoint should fail
+func (p *PixelDrain) check(w http.ResponseWriter, r *http.Request, endpoint string) bool {
+	p.mu.Lock()
+	status, fail := p.fails[endpoint]
+	p.mu.Unlock()
+	switch {
+	case p.APIKey != "" && r.Header.Get("Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(":"+p.APIKey)):
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"success": false, "value": "unauthorized", "message": "You are not logged in."})
+	case fail:
+		writeJSON(w, status, map[string]any{"success": false, "value": strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")), "message": http.StatusText(status)})
+	default:
+		return true
+	}
+	return false
+}
+
+func (p *PixelDrain) upload(w http.ResponseWriter, r *http.Request) {
+	if !p.check(w, r, PixelDrainFile) {
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"success": false, "value": "no_file", "message": "The file does not exist or is empty."})
+		return
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"success": false, "value": "no_file", "message": "Could not read the file."})
+		return
+	}
+
+	p.mu.Lock()
+	p.count++
+	id := fmt.Sprintf("fake%04d", p.count)
+	p.files[id] = content
+	p.names[id] = header.Filename
+	p.mu.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]any{"success": true, "id": id})
+}
+
+// Delete removes an uploaded file, like PixelDrain does with files nobody downloads
+func (p *PixelDrain) Delete(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.files, id)
+	delete(p.names, id)
+}
+
+// Files returns how many files were uploaded to the fake and are still there
+func (p *PixelDrain) Files() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.files)
+}
+
+// Corrupt changes a byte of an uploaded file, like a broken upload would
+func (p *PixelDrain) Corrupt(id string, offset int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	content := bytes.Clone(p.files[id])
+	content[offset] ^= 0xff
+	p.files[id] = content
+}
+
+// download answers requests for an uploaded file or for its info
+func (p *PixelDrain) download(w http.ResponseWriter, r *http.Request) {
+	id, info := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/file/"), "/info")
+	p.mu.Lock()
+	content, found := p.files[id]
+	name := p.names[id]
+	p.mu.Unlock()
+	switch {
+	case !found:
+		writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "value": "not_found", "message": "The entity you requested could not be found."})
+	case info:
+		writeJSON(w, http.StatusOK, map[string]any{"id": id, "name": name, "size": len(content)})
+	default:
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+	}
+}
+
+func (p *PixelDrain) getList(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/list/")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, list := range p.lists {
+		if list.ID == id {
+			writeJSON(w, http.StatusOK, map[string]any{"success": true, "id": list.ID, "title": list.Title, "file_count": len(list.Files)})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]any{"success": false, "value": "not_found", "message": "The entity you requested could not be found."})
+}
+
+func (p *PixelDrain) createList(w http.ResponseWriter, r *http.Request) {
+	if !p.check(w, r, PixelDrainList) {
+		return
+	}
+	var body struct {
+		Title string `json:"title"`
+		Files []struct {
+			ID string `json:"id"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"success": false, "value": "list_file_not_found", "message": "Invalid list."})
+		return
+	}
+
+	p.mu.Lock()
+	list := PixelDrainFolder{ID: fmt.Sprintf("list%04d", len(p.lists)+1), Title: body.Title}
+	for _, f := range body.Files {
+		if _, ok := p.files[f.ID]; !ok {
+			p.mu.Unlock()
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"success": false, "value": "list_file_not_found", "message": "File " + f.ID + " was not found."})
+			return
+		}
+		list.Files = append(list.Files, f.ID)
+	}
+	p.lists = append(p.lists, list)
+	p.mu.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]any{"success": true, "id": list.ID})
+}
@@ -0,0 +1,137 @@
+// Package hoststest runs fake hosts in process, so the host adapters can be tested without a network.
+//
+// A Server answers every request the adapters send: it routes them by hostname to the fakes registered on it
+// (see NewBunkr, NewPixelDrain and NewCyberfile) or to recordings of the real hosts (see Server.Golden).
+// Install points the adapters' HTTP clients at the server for the duration of a test.
+package hoststest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+	"github.com/easymirror/easymirror-backend/internal/hosts/limit"
+)
+
+// SourceHost serves the staged files added with AddSource, over plain HTTP
+const SourceHost = "source.test"
+
+// Server is a TLS server that stands in for every host
+type Server struct {
+	tls    *httptest.Server
+	source *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]http.Handler // By hostname
+	files    map[string][]byte       // Staged files by name
+}
+
+// NewServer starts a server that is closed at the end of the test
+func NewServer(tb testing.TB) *Server {
+	s := &Server{
+		handlers: map[string]http.Handler{},
+		files:    map[string][]byte{},
+	}
+	s.tls = httptest.NewTLSServer(http.HandlerFunc(s.route))
+	s.source = httptest.NewServer(http.HandlerFunc(s.serveSource))
+	tb.Cleanup(s.tls.Close)
+	tb.Cleanup(s.source.Close)
+	return s
+}
+
+// Handle answers the requests to a hostname with h. The "*" hostname answers the requests to any other hostname.
+func (s *Server) Handle(hostname string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[hostname] = h
+}
+
+// route passes a request to the handler of its hostname
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	hostname, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		hostname = r.Host
+	}
+	s.mu.Lock()
+	h, ok := s.handlers[hostname]
+	if !ok {
+		h, ok = s.handlers["*"]
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "hoststest: no fake for "+hostname, http.StatusBadGateway)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// AddSource stages a file and returns it the way the upload handler passes it to the adapters
+func (s *Server) AddSource(name string, content []byte) hosts.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = content
+	return hosts.File{Name: name, URI: "http://" + SourceHost + "/" + name, Size: int64(len(content))}
+}
+
+// serveSource serves a staged file, like a presigned S3 URL
+func (s *Server) serveSource(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, ok := s.files[strings.TrimPrefix(r.URL.Path, "/")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "NoSuchKey", http.StatusNotFound)
+		return
+	}
+	w.Write(content)
+}
+
+// Transport returns a transport that sends every request to the server, whatever its address
+func (s *Server) Transport() *http.Transport {
+	tr := s.tls.Client().Transport.(*http.Transport).Clone()
+	tr.TLSClientConfig.InsecureSkipVerify = true // The certificate is only valid for the server's IP
+	tr.DialContext = s.dialer(s.tls.Listener.Addr().String())
+	return tr
+}
+
+// Client returns a client that sends every request to the server
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: s.Transport()}
+}
+
+// dialer returns a dial function that connects to fallback, or to the source server for SourceHost
+func (s *Server) dialer(fallback string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, _ := net.SplitHostPort(addr); host == SourceHost {
+			return d.DialContext(ctx, network, s.source.Listener.Addr().String())
+		}
+		if fallback == "" {
+			return d.DialContext(ctx, network, addr)
+		}
+		return d.DialContext(ctx, network, fallback)
+	}
+}
+
+// Install makes the adapters send their requests to the server until the end of the test.
+// Rate limits are lifted so tests do not wait.
+func (s *Server) Install(tb testing.TB) {
+	install(tb, httpx.NewFactory(httpx.Options{Base: s.Transport()}))
+}
+
+// install replaces the default factory and the host limits until the end of the test
+func install(tb testing.TB, f *httpx.Factory) {
+	previous := httpx.Default()
+	httpx.SetDefault(f)
+	fast := limit.Config{MaxConcurrent: 100, RequestsPerSecond: 1000, Burst: 1000}
+	limit.Configure(map[string]limit.Config{"bunkr": fast, "pixeldrain": fast, "cyberfile": fast})
+	tb.Cleanup(func() {
+		httpx.SetDefault(previous)
+		limit.Configure(nil)
+	})
+}
@@ -0,0 +1,57 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxBodySize is the largest response body the adapters read from a host
+const MaxBodySize = 1 << 20 // 1 MiB
+
+var ErrBodyTooLarge = errors.New("response body is too large")
+
+// ReadBody reads and closes the body of a response, up to MaxBodySize
+func ReadBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body error: %w", err)
+	}
+	if len(body) > MaxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return body, nil
+}
+
+// DecodeJSON reads the body of a response into v, see ReadBody
+func DecodeJSON(resp *http.Response, v any) error {
+	body, err := ReadBody(resp)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("unmarshal error: %w", err)
+	}
+	return nil
+}
+
+// OpenSource starts downloading a staged file from its presigned URI
+func OpenSource(ctx context.Context, uri string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request error: %w", err)
+	}
+	resp, err := SourceClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("bad status from source: %v", resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,43 @@
+package httpx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FromEnv returns a factory with the proxies in HOST_PROXIES, a JSON object of proxy URLs by host.
+// The "*" key lists the proxies of hosts without their own, ie: {"*": ["socks5://10.0.0.1:1080"], "bunkr": []}
+// Hosts with an empty list connect directly. PROXY_CHECK_URL overrides the URL of the health check.
+// When HTTPX_RECORD_DIR is set, the responses of the hosts are recorded into it.
+func FromEnv() (*Factory, error) {
+	opts := Options{}
+	if dir := os.Getenv("HTTPX_RECORD_DIR"); dir != "" {
+		opts.Recorder = NewRecorder(dir)
+	}
+
+	raw := os.Getenv("HOST_PROXIES")
+	if raw == "" {
+		return NewFactory(opts), nil
+	}
+	var urls map[string][]string
+	if err := json.Unmarshal([]byte(raw), &urls); err != nil {
+		return nil, fmt.Errorf("unmarshal HOST_PROXIES error: %w", err)
+	}
+
+	proxies := make(map[string]*ProxyPool, len(urls))
+	for host, list := range urls {
+		if len(list) == 0 {
+			proxies[host] = nil // Explicitly direct, even if "*" has proxies
+			continue
+		}
+		p, err := NewProxyPool(list)
+		if err != nil {
+			return nil, fmt.Errorf("proxies of %v: %w", host, err)
+		}
+		p.CheckURL = os.Getenv("PROXY_CHECK_URL")
+		proxies[host] = p
+	}
+	opts.Proxies = proxies
+	return NewFactory(opts), nil
+}
@@ -0,0 +1,35 @@
+package httpx
+
+import "net/http"
+
+// Profile is a set of headers that makes requests look like they come from a given client
+type Profile http.Header
+
+// Browser makes requests look like they come from Brave on macOS, for hosts that only talk to browsers
+var Browser = Profile{
+	"sec-ch-ua":          {`"Chromium";v="122", "Not(A:Brand";v="24", "Brave";v="122"`},
+	"sec-ch-ua-mobile":   {"?0"},
+	"sec-ch-ua-platform": {`"macOS"`},
+	"user-agent":         {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.36"},
+	"sec-gpc":            {"1"},
+	"accept-language":    {"en-US,en;q=0.6"},
+}
+
+// With returns the headers of the profile with extra headers added on top.
+// Extra headers replace the ones of the profile with the same name.
+// Accept-Encoding is left to the transport, so compressed responses are decoded.
+func (p Profile) With(extra http.Header) http.Header {
+	h := make(http.Header, len(p)+len(extra))
+	for k, v := range p {
+		h[k] = append([]string(nil), v...)
+	}
+	for k, v := range extra {
+		for existing := range h {
+			if http.CanonicalHeaderKey(existing) == http.CanonicalHeaderKey(k) {
+				delete(h, existing)
+			}
+		}
+		h[k] = v
+	}
+	return h
+}
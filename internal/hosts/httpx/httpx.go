@@ -0,0 +1,235 @@
+// Package httpx builds the HTTP clients the host adapters send their requests with.
+//
+// Every client shares one hardened transport with timeouts, is rate limited per host and, when proxies are
+// configured for a host, sends its requests through one of them so uploads do not all come from the server's IP.
+// Each request gets an ID that shows up in its errors, in the events passed to hooks and in recordings.
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/limit"
+)
+
+// Timeouts of the shared transport. Uploads can take hours, so there is no limit on the whole request;
+// callers bound it with their context.
+const (
+	dialTimeout           = 10 * time.Second
+	tlsHandshakeTimeout   = 10 * time.Second
+	responseHeaderTimeout = 2 * time.Minute // Counted from when the request body was sent
+	idleConnTimeout       = 90 * time.Second
+)
+
+// sourceHost is the name the clients that download staged files are instrumented under
+const sourceHost = "source"
+
+// Options configure a Factory
+type Options struct {
+	Base     *http.Transport       // Transport requests are sent with, NewTransport() if nil
+	Proxies  map[string]*ProxyPool // Proxies by host, "*" is used for hosts without their own
+	Hooks    []Hook                // Called after every request, ie: to collect metrics
+	Recorder *Recorder             // Records the responses of the hosts, nil to not record
+}
+
+// Factory builds and caches one client per host
+type Factory struct {
+	base     *http.Transport
+	proxies  map[string]*ProxyPool
+	hooks    []Hook
+	recorder *Recorder
+	metrics  *Metrics
+
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}
+
+// NewFactory returns a factory that builds clients with the given options
+func NewFactory(opts Options) *Factory {
+	base := opts.Base
+	if base == nil {
+		base = NewTransport()
+	}
+	metrics := NewMetrics()
+	return &Factory{
+		base:     base,
+		proxies:  opts.Proxies,
+		hooks:    append([]Hook{metrics.Observe}, opts.Hooks...),
+		recorder: opts.Recorder,
+		metrics:  metrics,
+		clients:  map[string]*http.Client{},
+	}
+}
+
+// NewTransport returns a transport with timeouts on every step before the response starts
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// Client returns the client for a host
+func (f *Factory) Client(host string) *http.Client {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[host]; ok {
+		return c
+	}
+
+	var rt http.RoundTripper = f.base
+	if p := f.proxyPool(host); p != nil {
+		rt = &proxyTransport{pool: p, base: f.base, byProxy: map[string]*http.Transport{}}
+	}
+	rt = &instrumented{host: host, hooks: f.hooks, recorder: f.recorder, next: rt}
+	c := &http.Client{Transport: limit.Transport(host, rt)}
+	f.clients[host] = c
+	return c
+}
+
+// SourceClient returns the client to download staged files with.
+// It goes straight to the bucket, without the limits and proxies of the hosts.
+func (f *Factory) SourceClient() *http.Client {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[sourceHost]; ok {
+		return c
+	}
+	c := &http.Client{Transport: &instrumented{host: sourceHost, hooks: f.hooks, next: f.base}}
+	f.clients[sourceHost] = c
+	return c
+}
+
+// Metrics returns the request metrics of every host
+func (f *Factory) Metrics() map[string]HostMetrics {
+	return f.metrics.Snapshot()
+}
+
+// proxyPool returns the proxies of a host, nil if it has none
+func (f *Factory) proxyPool(host string) *ProxyPool {
+	if p, ok := f.proxies[host]; ok {
+		return p
+	}
+	return f.proxies["*"]
+}
+
+// CheckProxies runs a health check on every proxy
+func (f *Factory) CheckProxies(ctx context.Context) error {
+	checked := map[*ProxyPool]bool{}
+	for _, p := range f.proxies {
+		if p == nil || checked[p] {
+			continue
+		}
+		checked[p] = true
+		p.Check(ctx, f.base)
+	}
+	return nil
+}
+
+// ProxyStatus returns the state of the proxies of every host
+func (f *Factory) ProxyStatus() map[string][]ProxyStatus {
+	status := make(map[string][]ProxyStatus, len(f.proxies))
+	for host, p := range f.proxies {
+		if p != nil {
+			status[host] = p.Status()
+		}
+	}
+	return status
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultFactory = NewFactory(Options{})
+)
+
+// SetDefault replaces the factory the adapters get their clients from.
+// It should be called at startup, or by tests that send requests to fake hosts.
+func SetDefault(f *Factory) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultFactory = f
+}
+
+// Default returns the factory the adapters get their clients from
+func Default() *Factory {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultFactory
+}
+
+// Client returns the client of a host from the default factory
+func Client(host string) *http.Client {
+	return Default().Client(host)
+}
+
+// SourceClient returns the client to download staged files with from the default factory
+func SourceClient() *http.Client {
+	return Default().SourceClient()
+}
+
+type sessionKey struct{}
+
+// WithSession ties the requests made with ctx to a session, ie: a mirror job.
+// Requests of the same session go through the same proxy for as long as it stays healthy.
+func WithSession(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, id)
+}
+
+// session returns the session of a context, empty if there is none
+func session(ctx context.Context) string {
+	id, _ := ctx.Value(sessionKey{}).(string)
+	return id
+}
+
+// proxyTransport sends each request through a proxy of the pool
+type proxyTransport struct {
+	pool *ProxyPool
+	base *http.Transport
+
+	mu      sync.Mutex
+	byProxy map[string]*http.Transport // Keeps connections to a proxy open between requests
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := t.pool.pick(session(req.Context()))
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.transport(u).RoundTrip(req)
+	if req.Context().Err() == nil {
+		t.pool.report(u, err)
+	}
+	return resp, err
+}
+
+// transport returns the transport that sends requests through a proxy
+func (t *proxyTransport) transport(u *url.URL) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := u.String()
+	tr, ok := t.byProxy[key]
+	if !ok {
+		tr = t.base.Clone()
+		tr.Proxy = http.ProxyURL(u)
+		t.byProxy[key] = tr
+	}
+	return tr
+}
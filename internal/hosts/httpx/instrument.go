@@ -0,0 +1,149 @@
+package httpx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event describes a request that was sent to a host
+type Event struct {
+	RequestID string
+	Host      string // Name of the host, ie: bunkr
+	Method    string
+	URL       string // Without the secrets in the query
+	Status    int    // 0 if no response was received
+	Duration  time.Duration
+	Err       error
+}
+
+// Hook is called after every request
+type Hook func(Event)
+
+// instrumented gives every request an ID and reports it to the hooks and the recorder
+type instrumented struct {
+	host     string
+	hooks    []Hook
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *instrumented) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := newRequestID()
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	e := Event{
+		RequestID: id,
+		Host:      t.host,
+		Method:    req.Method,
+		URL:       redactURL(req.URL),
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+	if resp != nil {
+		e.Status = resp.StatusCode
+	}
+	for _, hook := range t.hooks {
+		hook(e)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("request %v: %w", id, err)
+	}
+	if t.recorder != nil {
+		t.recorder.record(t.host, id, req, resp)
+	}
+	return resp, nil
+}
+
+// newRequestID returns a random ID for a request
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// secretParams are query parameters that are never logged or recorded
+var secretParams = []string{"password", "access_token", "token", "key", "api_key"}
+
+// redactURL returns a URL with the values of secret query parameters removed
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	redacted := *u
+	q := redacted.Query()
+	for key := range q {
+		for _, secret := range secretParams {
+			if strings.EqualFold(key, secret) {
+				q.Set(key, "REDACTED")
+			}
+		}
+	}
+	redacted.RawQuery = q.Encode()
+	return redacted.Redacted()
+}
+
+// HostMetrics are counters of the requests sent to a host
+type HostMetrics struct {
+	Requests      int64            `json:"requests"`
+	Errors        int64            `json:"errors"`     // Requests that got no response
+	Statuses      map[string]int64 `json:"statuses"`   // Responses by status class, ie: 2xx
+	TotalDuration time.Duration    `json:"-"`          // Time spent waiting for responses
+	AvgMillis     int64            `json:"avg_millis"` // Average time until the response started
+	LastError     string           `json:"last_error,omitempty"`
+}
+
+// Metrics counts requests per host. It is always registered as a hook by NewFactory.
+type Metrics struct {
+	mu    sync.Mutex
+	hosts map[string]*HostMetrics
+}
+
+// NewMetrics returns empty metrics
+func NewMetrics() *Metrics {
+	return &Metrics{hosts: map[string]*HostMetrics{}}
+}
+
+// Observe counts a request, it is a Hook
+func (m *Metrics) Observe(e Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.hosts[e.Host]
+	if !ok {
+		h = &HostMetrics{Statuses: map[string]int64{}}
+		m.hosts[e.Host] = h
+	}
+	h.Requests++
+	h.TotalDuration += e.Duration
+	if e.Err != nil {
+		h.Errors++
+		h.LastError = fmt.Sprintf("request %v: %v", e.RequestID, e.Err)
+		return
+	}
+	h.Statuses[fmt.Sprintf("%dxx", e.Status/100)]++
+}
+
+// Snapshot returns a copy of the metrics of every host
+func (m *Metrics) Snapshot() map[string]HostMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]HostMetrics, len(m.hosts))
+	for host, metrics := range m.hosts {
+		h := *metrics
+		h.Statuses = make(map[string]int64, len(metrics.Statuses))
+		for k, v := range metrics.Statuses {
+			h.Statuses[k] = v
+		}
+		if h.Requests > 0 {
+			h.AvgMillis = (h.TotalDuration / time.Duration(h.Requests)).Milliseconds()
+		}
+		snapshot[host] = h
+	}
+	return snapshot
+}
@@ -0,0 +1,117 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecordedBody is the largest part of a response body that is recorded
+const maxRecordedBody = MaxBodySize
+
+// secretHeaders are request headers that are never recorded
+var secretHeaders = []string{"Authorization", "Token", "Cookie", "Proxy-Authorization"}
+
+// Recording is a request to a host and its response
+type Recording struct {
+	RequestID       string      `json:"request_id"`
+	Host            string      `json:"host"`
+	Time            time.Time   `json:"time"`
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	Body            string      `json:"body"`
+}
+
+// Recorder saves the responses of the hosts as JSON files, one directory per host.
+// Secrets in headers and queries are left out, and request bodies are never recorded.
+type Recorder struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewRecorder returns a recorder that saves into dir
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{dir: dir}
+}
+
+// record saves a response once its body was read and closed by the caller
+func (r *Recorder) record(host, id string, req *http.Request, resp *http.Response) {
+	rec := Recording{
+		RequestID:       id,
+		Host:            host,
+		Time:            time.Now().UTC(),
+		Method:          req.Method,
+		URL:             redactURL(req.URL),
+		RequestHeaders:  redactHeaders(req.Header),
+		Status:          resp.StatusCode,
+		ResponseHeaders: resp.Header.Clone(),
+	}
+	resp.Body = &recordingBody{ReadCloser: resp.Body, done: func(body []byte) {
+		rec.Body = string(body)
+		if err := r.save(rec); err != nil {
+			log.Println("[httpx] Error saving recording:", err)
+		}
+	}}
+}
+
+// save writes a recording to its file
+func (r *Recorder) save(rec Recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+	dir := filepath.Join(r.dir, rec.Host)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%v_%v.json", rec.Time.Format("20060102T150405.000000000"), rec.RequestID)
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
+
+// redactHeaders returns a copy of headers without the secrets
+func redactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	for k := range redacted {
+		for _, secret := range secretHeaders {
+			if strings.EqualFold(k, secret) {
+				redacted[k] = []string{"REDACTED"}
+			}
+		}
+	}
+	return redacted
+}
+
+// recordingBody keeps what is read from a body and hands it over on Close
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	once sync.Once
+	done func([]byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := maxRecordedBody - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+	return err
+}
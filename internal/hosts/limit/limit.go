@@ -0,0 +1,259 @@
+// Package limit keeps mirror jobs from overloading the hosts.
+//
+// Each host gets a Limiter with a cap on concurrent uploads, a rate limit on requests and a circuit breaker.
+// When too many uploads to a host fail in a row the circuit opens and new jobs wait until the cooldown is
+// over, then a single job tries the host again before the others are let through.
+// Limiters are shared by the whole process; they do not coordinate between several instances.
+package limit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+)
+
+// pollInterval is how often a job waiting on a half-open circuit checks if the trial job is done
+const pollInterval = time.Second
+
+// Config are the limits of a host. Zero values fall back to DefaultConfig.
+type Config struct {
+	MaxConcurrent     int     `json:"max_concurrent"`      // Max uploads to the host at once
+	RequestsPerSecond float64 `json:"requests_per_second"` // Max requests per second to the host's API
+	Burst             int     `json:"burst"`               // Requests that can go out at once before being rate limited
+	FailureThreshold  int     `json:"failure_threshold"`   // Failed uploads in a row before the circuit opens
+	CooldownSeconds   int     `json:"cooldown_seconds"`    // How long the circuit stays open
+}
+
+// DefaultConfig are the limits of hosts without their own config
+var DefaultConfig = Config{
+	MaxConcurrent:     4,
+	RequestsPerSecond: 2,
+	Burst:             4,
+	FailureThreshold:  5,
+	CooldownSeconds:   60,
+}
+
+// withDefaults fills the zero values of a config from DefaultConfig
+func (c Config) withDefaults() Config {
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = DefaultConfig.MaxConcurrent
+	}
+	if c.RequestsPerSecond <= 0 {
+		c.RequestsPerSecond = DefaultConfig.RequestsPerSecond
+	}
+	if c.Burst <= 0 {
+		c.Burst = DefaultConfig.Burst
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = DefaultConfig.FailureThreshold
+	}
+	if c.CooldownSeconds <= 0 {
+		c.CooldownSeconds = DefaultConfig.CooldownSeconds
+	}
+	return c
+}
+
+// Circuit states
+const (
+	Closed   = "closed"    // Jobs go through
+	Open     = "open"      // Jobs wait for the cooldown to end
+	HalfOpen = "half_open" // A single job tries the host again
+)
+
+// State describes a limiter for the admin API
+type State struct {
+	Host      string     `json:"host"`
+	Circuit   string     `json:"circuit"`
+	Active    int        `json:"active"`   // Uploads running right now
+	Waiting   int        `json:"waiting"`  // Jobs waiting for a slot or for the circuit to close
+	Failures  int        `json:"failures"` // Failed uploads in a row
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// Limiter limits the uploads and requests to a single host
+type Limiter struct {
+	host string
+	cfg  Config
+	sem  chan struct{}
+	now  func() time.Time
+
+	mu        sync.Mutex
+	tokens    float64
+	refilled  time.Time
+	failures  int
+	openUntil time.Time // Zero while the circuit is closed
+	trial     bool      // Whether a job is trying the host while the circuit is half-open
+	waiting   int
+}
+
+// New returns a limiter for a host
+func New(host string, cfg Config) *Limiter {
+	cfg = cfg.withDefaults()
+	return &Limiter{
+		host:   host,
+		cfg:    cfg,
+		sem:    make(chan struct{}, cfg.MaxConcurrent),
+		now:    time.Now,
+		tokens: float64(cfg.Burst),
+	}
+}
+
+// Acquire waits until an upload to the host is allowed and returns a function to call with its result.
+// Jobs wait while the circuit is open instead of failing, until the context is done.
+func (l *Limiter) Acquire(ctx context.Context) (release func(err error), err error) {
+	l.mu.Lock()
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var trial bool
+	for logged := false; ; logged = true {
+		l.mu.Lock()
+		wait, isTrial := l.admit()
+		l.mu.Unlock()
+		if wait == 0 {
+			trial = isTrial
+			break
+		}
+		if !logged {
+			log.Printf("[limit] Circuit of %v is open, waiting %v", l.host, wait.Round(time.Second))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			<-l.sem
+			return nil, ctx.Err()
+		}
+	}
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			l.mu.Lock()
+			l.record(err, trial)
+			l.mu.Unlock()
+			<-l.sem
+		})
+	}, nil
+}
+
+// admit returns how long a job has to wait for the circuit, or 0 if it can go now.
+// A job let through a half-open circuit is the trial job.
+func (l *Limiter) admit() (wait time.Duration, trial bool) {
+	if l.openUntil.IsZero() {
+		return 0, false
+	}
+	if now := l.now(); now.Before(l.openUntil) {
+		return l.openUntil.Sub(now), false
+	}
+	if l.trial {
+		return pollInterval, false
+	}
+	l.trial = true
+	return 0, true
+}
+
+// record updates the circuit with the result of an upload
+func (l *Limiter) record(err error, trial bool) {
+	if trial {
+		l.trial = false
+	}
+	switch {
+	case err == nil:
+		l.failures = 0
+		l.openUntil = time.Time{}
+	case !isHostFailure(err):
+		// Not the host's fault, leave the circuit as is
+	default:
+		l.failures++
+		if trial || l.failures >= l.cfg.FailureThreshold {
+			l.openUntil = l.now().Add(time.Duration(l.cfg.CooldownSeconds) * time.Second)
+			log.Printf("[limit] Opening circuit of %v after %v failures: %v", l.host, l.failures, err)
+		}
+	}
+}
+
+// isHostFailure returns whether an error means the host is having trouble.
+// Cancelled jobs and problems with a single account do not count.
+func isHostFailure(err error) bool {
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, hosts.ErrUnauthorized) &&
+		!errors.Is(err, hosts.ErrQuotaExceeded)
+}
+
+// Wait blocks until a request to the host is allowed by the rate limit
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		wait := l.reserve()
+		l.mu.Unlock()
+		if wait == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token from the bucket, or returns how long until the next one is available
+func (l *Limiter) reserve() time.Duration {
+	now := l.now()
+	if !l.refilled.IsZero() {
+		l.tokens += now.Sub(l.refilled).Seconds() * l.cfg.RequestsPerSecond
+		if max := float64(l.cfg.Burst); l.tokens > max {
+			l.tokens = max
+		}
+	}
+	l.refilled = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.cfg.RequestsPerSecond * float64(time.Second))
+}
+
+// State returns the current state of the limiter
+func (l *Limiter) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := State{
+		Host:     l.host,
+		Circuit:  Closed,
+		Active:   len(l.sem),
+		Waiting:  l.waiting,
+		Failures: l.failures,
+	}
+	if !l.openUntil.IsZero() {
+		s.Circuit = Open
+		if !l.now().Before(l.openUntil) {
+			s.Circuit = HalfOpen
+		}
+		openUntil := l.openUntil
+		s.OpenUntil = &openUntil
+	}
+	return s
+}
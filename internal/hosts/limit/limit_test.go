@@ -0,0 +1,109 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -timeout 30s -run ^TestCircuit$ github.com/easymirror/easymirror-backend/internal/hosts/limit
+func TestCircuit(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New("bunkr", Config{FailureThreshold: 2, CooldownSeconds: 30})
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+	upload := func(err error) {
+		release, aerr := l.Acquire(ctx)
+		require.NoError(t, aerr)
+		release(err)
+	}
+
+	// Errors that are not the host's fault are ignored
+	upload(fmt.Errorf("upload: %w", hosts.ErrUnauthorized))
+	upload(context.Canceled)
+	assert.Equal(t, Closed, l.State().Circuit)
+
+	// A success resets the count
+	upload(errors.New("bad gateway"))
+	upload(nil)
+	upload(errors.New("bad gateway"))
+	assert.Equal(t, Closed, l.State().Circuit)
+	upload(errors.New("bad gateway"))
+	assert.Equal(t, Open, l.State().Circuit)
+
+	// Jobs wait while the circuit is open
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err := l.Acquire(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, l.State().Active)
+
+	// After the cooldown a single trial job goes through, a failed trial opens the circuit again
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, HalfOpen, l.State().Circuit)
+	release, err := l.Acquire(ctx)
+	require.NoError(t, err)
+	short, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "only the trial job may go")
+	release(errors.New("bad gateway"))
+	assert.Equal(t, Open, l.State().Circuit)
+
+	// A successful trial closes the circuit
+	now = now.Add(30 * time.Second)
+	upload(nil)
+	assert.Equal(t, Closed, l.State().Circuit)
+	assert.Equal(t, 0, l.State().Failures)
+}
+
+// go test -v -timeout 30s -run ^TestConcurrency$ github.com/easymirror/easymirror-backend/internal/hosts/limit
+func TestConcurrency(t *testing.T) {
+	l := New("pixeldrain", Config{MaxConcurrent: 2})
+	ctx := context.Background()
+
+	r1, err := l.Acquire(ctx)
+	require.NoError(t, err)
+	r2, err := l.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, l.State().Active)
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	r1(nil)
+	r1(nil) // Releasing twice frees a single slot
+	r3, err := l.Acquire(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, l.State().Active)
+	r2(nil)
+	r3(nil)
+	assert.Equal(t, 0, l.State().Active)
+}
+
+// go test -v -timeout 30s -run ^TestReserve$ github.com/easymirror/easymirror-backend/internal/hosts/limit
+func TestReserve(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New("bunkr", Config{RequestsPerSecond: 2, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	assert.Zero(t, l.reserve())
+	assert.Zero(t, l.reserve())
+	assert.Equal(t, 500*time.Millisecond, l.reserve(), "bucket is empty")
+
+	now = now.Add(250 * time.Millisecond)
+	assert.Equal(t, 250*time.Millisecond, l.reserve())
+
+	now = now.Add(time.Hour)
+	assert.Zero(t, l.reserve())
+	assert.Zero(t, l.reserve())
+	assert.NotZero(t, l.reserve(), "refills up to the burst only")
+}
@@ -0,0 +1,89 @@
+package limit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+var (
+	mu       sync.Mutex
+	configs  = map[string]Config{}
+	limiters = map[string]*Limiter{}
+)
+
+// Configure sets the limits of hosts. Hosts that are not in the map use DefaultConfig.
+// It should be called once at startup, before any upload.
+func Configure(cfgs map[string]Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	configs = cfgs
+	limiters = map[string]*Limiter{}
+}
+
+// FromEnv configures the limits from the JSON object in HOST_LIMITS, keyed by host.
+// ie: {"bunkr": {"max_concurrent": 2, "requests_per_second": 1}}
+func FromEnv() error {
+	raw := os.Getenv("HOST_LIMITS")
+	if raw == "" {
+		return nil
+	}
+	var cfgs map[string]Config
+	if err := json.Unmarshal([]byte(raw), &cfgs); err != nil {
+		return fmt.Errorf("unmarshal HOST_LIMITS error: %w", err)
+	}
+	Configure(cfgs)
+	return nil
+}
+
+// For returns the limiter of a host
+func For(host string) *Limiter {
+	mu.Lock()
+	defer mu.Unlock()
+	l, ok := limiters[host]
+	if !ok {
+		l = New(host, configs[host])
+		limiters[host] = l
+	}
+	return l
+}
+
+// Status returns the state of every host that was used, sorted by host
+func Status() []State {
+	mu.Lock()
+	all := make([]*Limiter, 0, len(limiters))
+	for _, l := range limiters {
+		all = append(all, l)
+	}
+	mu.Unlock()
+
+	states := make([]State, 0, len(all))
+	for _, l := range all {
+		states = append(states, l.State())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Host < states[j].Host })
+	return states
+}
+
+// Transport returns a RoundTripper that rate limits the requests to a host before sending them with base
+func Transport(host string, base http.RoundTripper) http.RoundTripper {
+	return transport{host: host, base: base}
+}
+
+type transport struct {
+	host string
+	base http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := For(t.host).Wait(req.Context()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
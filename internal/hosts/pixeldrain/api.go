@@ -2,7 +2,9 @@ package pixeldrain
 
 import (
 	"encoding/base64"
-	"os"
+	"net/http"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
 )
 
 const (
@@ -11,18 +13,18 @@ const (
 	folderBaseURL = "https://pixeldrain.com/l"   // Base URL of folders
 )
 
-// apiKey Returns the API key from the environment variable
-func apiKey() string {
-	return os.Getenv("PIXELDRAIN_API_KEY")
+// client returns the client to send requests to pixeldrain with
+func client() *http.Client {
+	return httpx.Client("pixeldrain")
 }
 
 // apiKeyEncoded Returns a Base64 encoded API key
-func apiKeyEncoded() string {
-	t := ":" + apiKey()
+func apiKeyEncoded(apiKey string) string {
+	t := ":" + apiKey
 	return base64.StdEncoding.EncodeToString([]byte(t))
 }
 
 // authHeader returns the value for the `Authorization` header
-func authHeader() string {
-	return "Basic " + apiKeyEncoded()
+func authHeader(apiKey string) string {
+	return "Basic " + apiKeyEncoded(apiKey)
 }
@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
 )
 
 type FolderPayload struct {
@@ -21,7 +23,7 @@ type File struct {
 
 // newFolder creates a list of files that can be viewed together on the file viewer page.
 // It returns the URI to the PixelDrain folder
-func newFolder(ctx context.Context, mirrorID string, ids []string) (string, error) {
+func newFolder(ctx context.Context, apiKey, mirrorID string, ids []string) (string, error) {
 	// Create Payload
 	files := make([]File, len(ids))
 	for i, id := range ids {
@@ -41,11 +43,15 @@ func newFolder(ctx context.Context, mirrorID string, ids []string) (string, erro
 		baseURL+"/list",
 		bytes.NewBuffer(payload),
 	)
-	req.Header.Set("Authorization", authHeader())
-	resp, err := http.DefaultClient.Do(req)
+	req.Header.Set("Authorization", authHeader(apiKey))
+	resp, err := client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request error: %w", err)
 	}
+	if err = hosts.CheckStatus(resp); err != nil {
+		resp.Body.Close()
+		return "", err
+	}
 
 	// Parse Response
 	return parseFolderResponse(resp)
@@ -53,10 +59,6 @@ func newFolder(ctx context.Context, mirrorID string, ids []string) (string, erro
 
 // parseFolderResponse parse's the response from the newFolder method
 func parseFolderResponse(resp *http.Response) (string, error) {
-	// Read the response
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-
 	// Parse the response
 	response := &struct {
 		Success bool   `json:"success"`
@@ -64,9 +66,8 @@ func parseFolderResponse(resp *http.Response) (string, error) {
 		ErrorID string `json:"value"`
 		Message string `json:"message"`
 	}{}
-	var err error
-	if err = json.Unmarshal(body, response); err != nil {
-		return "", fmt.Errorf("unmarshal error: %w", err)
+	if err := httpx.DecodeJSON(resp, response); err != nil {
+		return "", err
 	}
 	if !response.Success {
 		return "", fmt.Errorf("upload error: %v - %v", response.ErrorID, response.Message)
@@ -0,0 +1,30 @@
+package pixeldrain
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+)
+
+// FileURL returns the public URL of a file on PixelDrain
+func FileURL(id string) string {
+	return fileBaseURL + "/" + id
+}
+
+// Probe checks whether a folder or file link on PixelDrain still works, asking the API rather than loading the page
+func Probe(ctx context.Context, link string) (hosts.Health, error) {
+	var api string
+	if id, ok := strings.CutPrefix(link, folderBaseURL+"/"); ok {
+		api = baseURL + "/list/" + url.PathEscape(id)
+	} else if id, ok := strings.CutPrefix(link, fileBaseURL+"/"); ok {
+		api = baseURL + "/file/" + url.PathEscape(id) + "/info"
+	} else if id, ok := strings.CutPrefix(link, baseURL+"/file/"); ok {
+		api = baseURL + "/file/" + url.PathEscape(id) + "/info"
+	} else {
+		return hosts.HealthUnknown, fmt.Errorf("not a pixeldrain link: %q", link)
+	}
+	return hosts.ProbeURL(ctx, client(), api)
+}
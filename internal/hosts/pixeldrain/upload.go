@@ -3,7 +3,6 @@ package pixeldrain
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,56 +10,71 @@ import (
 	"mime/multipart"
 	"net/http"
 
-	"github.com/easymirror/easymirror-backend/internal/common"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
 )
 
 // Upload is a wrapper function to upload to PixelDrain's API.
-// If successful, it returns a link to the folder with the uploaded files
-func Upload(ctx context.Context, mirrorID string, presignURIs []string) (string, error) {
-	if len(presignURIs) < 1 {
+// If successful, it returns a link to the folder with the uploaded files,
+// with a hosts.UploadError if some of the files could not be uploaded
+func Upload(ctx context.Context, creds hosts.Credentials, mirrorID string, files []hosts.File) (string, error) {
+	if len(files) < 1 {
 		return "", errors.New("no presigned URLs")
 	}
 
 	// Upload the files to PixelDrain's API
 	ids := []string{}
-	for _, uri := range presignURIs {
-		fileID, err := upload(ctx, uri)
+	failed := map[string]error{}
+	var uploadErr error
+	for _, f := range files {
+		fileIDs, err := upload(ctx, creds.APIKey, f)
 		if err != nil {
 			log.Println("Error uploading file:", err)
+			failed[f.DisplayName()], uploadErr = err, err
 			continue
 		}
-		ids = append(ids, fileID)
+		ids = append(ids, fileIDs...)
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("no files uploaded: %w", uploadErr)
 	}
 
 	// Create a new folder
-	folderID, err := newFolder(ctx, mirrorID, ids)
+	folderID, err := newFolder(ctx, creds.APIKey, mirrorID, ids)
 	if err != nil {
 		return "", fmt.Errorf("newFolder error: %w", err)
 	}
-	return folderBaseURL + "/" + folderID, nil
+	return folderBaseURL + "/" + folderID, hosts.PartialUpload(failed)
 }
 
 // UploadTX is a wrapper function to upload to PixelDrain's API.
 // It takes in a SQL tx, but does NOT commit it.
-// If successful, it returns a link to the folder with the uploaded files
-func UploadTX(ctx context.Context, tx *sql.Tx, mirrorID string, presignURIs []string) (string, error) {
-	if len(presignURIs) < 1 {
+// If successful, it returns a link to the folder with the uploaded files,
+// with a hosts.UploadError if some of the files could not be uploaded
+func UploadTX(ctx context.Context, tx *sql.Tx, creds hosts.Credentials, mirrorID string, files []hosts.File) (string, error) {
+	if len(files) < 1 {
 		return "", errors.New("no presigned URLs")
 	}
 
 	// Upload the files to PixelDrain's API
 	ids := []string{}
-	for _, uri := range presignURIs {
-		fileID, err := upload(ctx, uri)
+	failed := map[string]error{}
+	var uploadErr error
+	for _, f := range files {
+		fileIDs, err := upload(ctx, creds.APIKey, f)
 		if err != nil {
 			log.Println("Error uploading file:", err)
+			failed[f.DisplayName()], uploadErr = err, err
 			continue
 		}
-		ids = append(ids, fileID)
+		ids = append(ids, fileIDs...)
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("no files uploaded: %w", uploadErr)
 	}
 
 	// Create a new folder
-	folderID, err := newFolder(ctx, mirrorID, ids)
+	folderID, err := newFolder(ctx, creds.APIKey, mirrorID, ids)
 	if err != nil {
 		return "", fmt.Errorf("newFolder error: %w", err)
 	}
@@ -80,20 +94,52 @@ func UploadTX(ctx context.Context, tx *sql.Tx, mirrorID string, presignURIs []st
 	if _, err = tx.Exec(statement, mirrorID, folderLink); err != nil {
 		return "", fmt.Errorf("exec tx error: %w", err)
 	}
-	return folderLink, nil
+	return folderLink, hosts.PartialUpload(failed)
 }
 
-// upload upload's a given file to PixelDrain's API.
-// If the body is successfully uploaded, the ID of the upload is returned.
-func upload(ctx context.Context, presignURI string) (string, error) {
+// upload upload's a given file to PixelDrain's API, part by part if it is split to fit on PixelDrain.
+// Files whose content is still on PixelDrain from an earlier mirror are not uploaded again.
+// If the file is successfully uploaded, the IDs of the uploads are returned.
+func upload(ctx context.Context, apiKey string, f hosts.File) ([]string, error) {
+	if f.Reusable() && f.Remote.Reuse != "" {
+		health, err := Probe(ctx, FileURL(f.Remote.Reuse))
+		if err != nil {
+			log.Println("Error checking file to reuse:", err)
+		}
+		if health == hosts.HealthAlive {
+			f.Remote.SetID(f.Remote.Reuse, FileURL(f.Remote.Reuse))
+			return []string{f.Remote.Reuse}, nil
+		}
+		// The earlier upload is gone, upload the file again
+	}
+
 	// Get the file from the presigned URL.
-	req, _ := http.NewRequestWithContext(ctx, "GET", presignURI, nil)
-	resp1, err := http.DefaultClient.Do(req)
+	source, err := f.Open(ctx, httpx.OpenSource)
 	if err != nil {
-		return "", fmt.Errorf("error getting body from presigned URL: %w", err)
+		return nil, fmt.Errorf("error getting body from presigned URL: %w", err)
 	}
-	defer resp1.Body.Close()
+	defer source.Close()
 
+	var ids []string
+	err = hosts.Send(ctx, f, source, func(ctx context.Context, name string, body io.Reader) (string, error) {
+		id, err := send(ctx, apiKey, name, body)
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+		return baseURL + "/file/" + id, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f.Recordable() {
+		f.Remote.SetID(ids[0], FileURL(ids[0]))
+	}
+	return ids, nil
+}
+
+// send streams a body into a new file on PixelDrain and returns its ID
+func send(ctx context.Context, apiKey, name string, source io.Reader) (string, error) {
 	// We use an io.Pipe and a goroutine for writing from the file/response body
 	// and reading to the request concurrently.
 	// By doing this, we don't have to load the entire file into memory/a buffer.
@@ -101,26 +147,18 @@ func upload(ctx context.Context, presignURI string) (string, error) {
 	r, w := io.Pipe()
 	m := multipart.NewWriter(w)
 	go func() {
-		defer w.Close()
-		defer m.Close()
-
-		// Get the filename form the URL
-		name, err := common.FilenameFromURI(presignURI)
-		if err != nil {
-			return
-		}
-
 		part, err := m.CreateFormFile("file", name)
-		if err != nil {
-			return
+		if err == nil {
+			_, err = io.Copy(part, source)
 		}
-		if _, err = io.Copy(part, resp1.Body); err != nil {
-			return
+		if err == nil {
+			err = m.Close()
 		}
+		w.CloseWithError(err) // Fails the upload request if the file could not be read
 	}()
 
 	// Upload the file to PixelDrain
-	req, _ = http.NewRequestWithContext(
+	req, _ := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
 		baseURL+"/file",
@@ -128,24 +166,22 @@ func upload(ctx context.Context, presignURI string) (string, error) {
 	)
 	req.Header = http.Header{
 		"Content-Type":  {m.FormDataContentType()},
-		"Authorization": {authHeader()},
+		"Authorization": {authHeader(apiKey)},
 	}
 
-	client := &http.Client{}
-	resp2, err := client.Do(req)
+	resp2, err := client().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error uploading to pixeldrain: %w", err)
 	}
-	defer resp2.Body.Close()
+	if err = hosts.CheckStatus(resp2); err != nil {
+		resp2.Body.Close()
+		return "", err
+	}
 	return parseUpload(resp2)
 }
 
 // parseUpload parses the response from the upload function
 func parseUpload(r *http.Response) (string, error) {
-	// Read the response
-	defer r.Body.Close()
-	body, _ := io.ReadAll(r.Body)
-
 	// Parse the response
 	response := &struct {
 		Success bool   `json:"success"`
@@ -153,9 +189,8 @@ func parseUpload(r *http.Response) (string, error) {
 		ErrorID string `json:"value"`
 		Message string `json:"message"`
 	}{}
-	var err error
-	if err = json.Unmarshal(body, response); err != nil {
-		return "", fmt.Errorf("unmarshal error: %w", err)
+	if err := httpx.DecodeJSON(r, response); err != nil {
+		return "", err
 	}
 	if !response.Success {
 		return "", fmt.Errorf("upload error: %v - %v", response.ErrorID, response.Message)
@@ -0,0 +1,57 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FromEnv builds the pools from the JSON file in HOST_ACCOUNTS_FILE, a list of accounts.
+// Without the file, the fallback accounts are used instead.
+func FromEnv(store Store, fallback []Account) (Pools, error) {
+	accounts := fallback
+	if path := os.Getenv("HOST_ACCOUNTS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read accounts file error: %w", err)
+		}
+		if accounts, err = ParseAccounts(data); err != nil {
+			return nil, err
+		}
+	}
+	return NewPools(accounts, store), nil
+}
+
+// ParseAccounts parses and validates a JSON list of accounts
+func ParseAccounts(data []byte) ([]Account, error) {
+	var accounts []Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("unmarshal accounts error: %w", err)
+	}
+	seen := map[string]bool{}
+	for i, a := range accounts {
+		switch {
+		case a.ID == "" || a.Host == "":
+			return nil, fmt.Errorf("account #%v needs an id and a host", i)
+		case seen[a.ID]:
+			return nil, fmt.Errorf("account id %q is used twice", a.ID)
+		case a.Weight < 0 || a.DailyLimit < 0 || a.StorageLimit < 0:
+			return nil, fmt.Errorf("account %q has a negative weight or limit", a.ID)
+		}
+		seen[a.ID] = true
+	}
+	return accounts, nil
+}
+
+// NewPools groups accounts into a pool per host
+func NewPools(accounts []Account, store Store) Pools {
+	byHost := map[string][]Account{}
+	for _, a := range accounts {
+		byHost[a.Host] = append(byHost[a.Host], a)
+	}
+	pools := make(Pools, len(byHost))
+	for host, list := range byHost {
+		pools[host] = New(host, list, store)
+	}
+	return pools
+}
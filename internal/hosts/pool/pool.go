@@ -0,0 +1,298 @@
+// Package pool spreads operator-owned uploads over several accounts per host.
+//
+// Accounts are picked with smooth weighted round-robin among the accounts that still have quota left.
+// Accounts that get rejected by the host or run out of quota are disabled until an admin enables them again.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+)
+
+const saveTimeout = 10 * time.Second
+
+var (
+	ErrExhausted = errors.New("no account with enough quota left")
+	ErrNotFound  = errors.New("account not found")
+)
+
+// Account is an operator-owned account on a host
+type Account struct {
+	ID           string            `json:"id"`
+	Host         string            `json:"host"`
+	Credentials  hosts.Credentials `json:"credentials"`
+	Weight       int               `json:"weight"`        // Share of uploads compared to the other accounts. Defaults to 1
+	DailyLimit   int64             `json:"daily_limit"`   // Max bytes uploaded per UTC day, 0 for no limit
+	StorageLimit int64             `json:"storage_limit"` // Max bytes stored in total, 0 for no limit
+}
+
+// Usage is the tracked state of an account
+type Usage struct {
+	Day            string     // UTC day DayBytes were counted on, as YYYY-MM-DD
+	DayBytes       int64      // Bytes uploaded on Day
+	StorageBytes   int64      // Bytes uploaded in total
+	DisabledAt     *time.Time // When the account was disabled, nil if it is enabled
+	DisabledReason string
+}
+
+// Status describes an account for the admin API, without its credentials
+type Status struct {
+	ID             string     `json:"id"`
+	Host           string     `json:"host"`
+	Weight         int        `json:"weight"`
+	UsedToday      int64      `json:"used_today"`
+	DailyLimit     int64      `json:"daily_limit"`
+	UsedStorage    int64      `json:"used_storage"`
+	StorageLimit   int64      `json:"storage_limit"`
+	Disabled       bool       `json:"disabled"`
+	DisabledAt     *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason string     `json:"disabled_reason,omitempty"`
+}
+
+// Store persists usage so limits survive restarts
+type Store interface {
+	Load(ctx context.Context, accountID string) (Usage, error)
+	Save(ctx context.Context, accountID string, u Usage) error
+}
+
+// Pool holds the accounts of one host
+type Pool struct {
+	mu       sync.Mutex
+	host     string
+	members  []*member
+	store    Store
+	now      func() time.Time
+	reserved map[string]int64 // Bytes of leases that are not done yet, per account
+}
+
+type member struct {
+	Account
+	usage   Usage
+	current int // Smooth weighted round-robin state
+}
+
+// Lease is an account that was picked for an upload.
+// Done must be called once the upload finished, to record usage and release the reservation.
+type Lease struct {
+	Account
+	pool     *Pool
+	reserved int64
+	once     sync.Once
+}
+
+// New creates a pool for a host. Usage is loaded from the store, which may be nil.
+func New(host string, accounts []Account, store Store) *Pool {
+	p := &Pool{host: host, store: store, now: time.Now, reserved: map[string]int64{}}
+	for _, a := range accounts {
+		if a.Weight <= 0 {
+			a.Weight = 1
+		}
+		m := &member{Account: a}
+		if store != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+			u, err := store.Load(ctx, a.ID)
+			cancel()
+			if err != nil {
+				log.Printf("[pool] Could not load usage of %v: %v", a.ID, err)
+			}
+			m.usage = u
+		}
+		p.members = append(p.members, m)
+	}
+	return p
+}
+
+// Host returns the host the pool is for
+func (p *Pool) Host() string { return p.host }
+
+// Next picks an account that can take size more bytes.
+// The bytes are reserved until the lease is done, so parallel uploads don't overshoot a limit.
+func (p *Pool) Next(size int64) (*Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	today := p.today()
+	var total int
+	var best *member
+	for _, m := range p.members {
+		if !p.fits(m, size, today) {
+			continue
+		}
+		m.current += m.Weight
+		total += m.Weight
+		if best == nil || m.current > best.current {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w on %v", ErrExhausted, p.host)
+	}
+	best.current -= total
+	p.reserved[best.ID] += size
+	return &Lease{Account: best.Account, pool: p, reserved: size}, nil
+}
+
+// Done records the bytes that were uploaded with the lease.
+// If err means the host rejected the account or it is out of quota, the account is disabled.
+func (l *Lease) Done(uploaded int64, err error) {
+	l.once.Do(func() { l.pool.done(l, uploaded, err) })
+}
+
+// done releases a lease and records its usage
+func (p *Pool) done(l *Lease, uploaded int64, err error) {
+	p.mu.Lock()
+	p.reserved[l.ID] -= l.reserved
+	m := p.member(l.ID)
+	if m == nil {
+		p.mu.Unlock()
+		return
+	}
+	p.rollDay(m, p.today())
+	m.usage.DayBytes += uploaded
+	m.usage.StorageBytes += uploaded
+	switch {
+	case errors.Is(err, hosts.ErrUnauthorized):
+		p.disable(m, "credentials rejected: "+err.Error())
+	case errors.Is(err, hosts.ErrQuotaExceeded):
+		p.disable(m, "quota exceeded: "+err.Error())
+	}
+	u := m.usage
+	p.mu.Unlock()
+
+	p.save(m.ID, u)
+}
+
+// Enable turns a disabled account back on, ie: after its credentials were fixed
+func (p *Pool) Enable(accountID string) error {
+	p.mu.Lock()
+	m := p.member(accountID)
+	if m == nil {
+		p.mu.Unlock()
+		return ErrNotFound
+	}
+	m.usage.DisabledAt = nil
+	m.usage.DisabledReason = ""
+	u := m.usage
+	p.mu.Unlock()
+
+	p.save(accountID, u)
+	return nil
+}
+
+// Status returns the state of every account in the pool
+func (p *Pool) Status() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	today := p.today()
+	statuses := make([]Status, 0, len(p.members))
+	for _, m := range p.members {
+		p.rollDay(m, today)
+		statuses = append(statuses, Status{
+			ID:             m.ID,
+			Host:           m.Host,
+			Weight:         m.Weight,
+			UsedToday:      m.usage.DayBytes,
+			DailyLimit:     m.DailyLimit,
+			UsedStorage:    m.usage.StorageBytes,
+			StorageLimit:   m.StorageLimit,
+			Disabled:       m.usage.DisabledAt != nil,
+			DisabledAt:     m.usage.DisabledAt,
+			DisabledReason: m.usage.DisabledReason,
+		})
+	}
+	return statuses
+}
+
+// fits returns true if an account is enabled and can take size more bytes
+func (p *Pool) fits(m *member, size int64, today string) bool {
+	if m.usage.DisabledAt != nil {
+		return false
+	}
+	p.rollDay(m, today)
+	pending := p.reserved[m.ID] + size
+	if m.DailyLimit > 0 && m.usage.DayBytes+pending > m.DailyLimit {
+		return false
+	}
+	if m.StorageLimit > 0 && m.usage.StorageBytes+pending > m.StorageLimit {
+		return false
+	}
+	return true
+}
+
+// rollDay resets the daily usage when a new day started
+func (p *Pool) rollDay(m *member, today string) {
+	if m.usage.Day != today {
+		m.usage.Day = today
+		m.usage.DayBytes = 0
+	}
+}
+
+// disable turns an account off until it is enabled again
+func (p *Pool) disable(m *member, reason string) {
+	if m.usage.DisabledAt != nil {
+		return
+	}
+	now := p.now().UTC()
+	m.usage.DisabledAt = &now
+	m.usage.DisabledReason = reason
+	log.Printf("[pool] Disabled %v account %v: %v", p.host, m.ID, reason)
+}
+
+// member returns the account with the given ID
+func (p *Pool) member(accountID string) *member {
+	for _, m := range p.members {
+		if m.ID == accountID {
+			return m
+		}
+	}
+	return nil
+}
+
+// save persists usage, if the pool has a store
+func (p *Pool) save(accountID string, u Usage) {
+	if p.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	if err := p.store.Save(ctx, accountID, u); err != nil {
+		log.Printf("[pool] Could not save usage of %v: %v", accountID, err)
+	}
+}
+
+// today returns the current UTC day
+func (p *Pool) today() string {
+	return p.now().UTC().Format(time.DateOnly)
+}
+
+// Pools holds the pool of every host that has operator accounts
+type Pools map[string]*Pool
+
+// Get returns the pool of a host, or nil if the operator has no accounts there
+func (ps Pools) Get(host string) *Pool {
+	if ps == nil {
+		return nil
+	}
+	return ps[host]
+}
+
+// Status returns the state of every account of every host, sorted by host
+func (ps Pools) Status() []Status {
+	names := make([]string, 0, len(ps))
+	for host := range ps {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+	statuses := []Status{}
+	for _, host := range names {
+		statuses = append(statuses, ps[host].Status()...)
+	}
+	return statuses
+}
@@ -0,0 +1,164 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps usage in memory
+type memoryStore map[string]Usage
+
+func (s memoryStore) Load(ctx context.Context, id string) (Usage, error) { return s[id], nil }
+func (s memoryStore) Save(ctx context.Context, id string, u Usage) error {
+	s[id] = u
+	return nil
+}
+
+// go test -v -timeout 30s -run ^TestWeightedRoundRobin$ github.com/easymirror/easymirror-backend/internal/hosts/pool
+func TestWeightedRoundRobin(t *testing.T) {
+	p := New("pixeldrain", []Account{
+		{ID: "a", Weight: 5},
+		{ID: "b", Weight: 1},
+		{ID: "c", Weight: 1},
+	}, nil)
+
+	// Smooth weighted round-robin spreads the heavy account out instead of picking it 5 times in a row
+	var picks string
+	for i := 0; i < 7; i++ {
+		l, err := p.Next(0)
+		require.NoError(t, err)
+		picks += l.ID
+		l.Done(0, nil)
+	}
+	assert.Equal(t, "aabacaa", picks)
+}
+
+// go test -v -timeout 30s -run ^TestLimits$ github.com/easymirror/easymirror-backend/internal/hosts/pool
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		Account Account
+		Usage   Usage
+		Size    int64
+		Err     error
+	}{
+		{Account: Account{ID: "a"}, Usage: Usage{}, Size: 1 << 40},
+		{Account: Account{ID: "a", DailyLimit: 100}, Usage: Usage{Day: "2024-03-01", DayBytes: 50}, Size: 50},
+		{Account: Account{ID: "a", DailyLimit: 100}, Usage: Usage{Day: "2024-03-01", DayBytes: 60}, Size: 50, Err: ErrExhausted},
+		{Account: Account{ID: "a", DailyLimit: 100}, Usage: Usage{Day: "2024-02-29", DayBytes: 100}, Size: 50}, // The daily limit resets
+		{Account: Account{ID: "a", StorageLimit: 100}, Usage: Usage{StorageBytes: 90}, Size: 20, Err: ErrExhausted},
+		{Account: Account{ID: "a"}, Usage: Usage{DisabledAt: &time.Time{}}, Size: 0, Err: ErrExhausted},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			p := New("bunkr", []Account{test.Account}, memoryStore{"a": test.Usage})
+			p.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+			_, err := p.Next(test.Size)
+			if test.Err != nil {
+				assert.ErrorIs(t, err, test.Err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestReservations$ github.com/easymirror/easymirror-backend/internal/hosts/pool
+func TestReservations(t *testing.T) {
+	store := memoryStore{}
+	p := New("bunkr", []Account{{ID: "a", DailyLimit: 100}, {ID: "b", DailyLimit: 100}}, store)
+
+	// Two parallel uploads of 60 bytes can't both go to the same account
+	l1, err := p.Next(60)
+	require.NoError(t, err)
+	l2, err := p.Next(60)
+	require.NoError(t, err)
+	assert.NotEqual(t, l1.ID, l2.ID)
+	_, err = p.Next(60)
+	assert.ErrorIs(t, err, ErrExhausted)
+
+	// Only what was really uploaded counts, the rest of the reservation is released
+	l1.Done(10, nil)
+	l1.Done(10, nil) // Done twice is a no-op
+	assert.Equal(t, int64(10), store[l1.ID].DayBytes)
+	assert.Equal(t, int64(10), store[l1.ID].StorageBytes)
+	l3, err := p.Next(60)
+	require.NoError(t, err)
+	assert.Equal(t, l1.ID, l3.ID)
+}
+
+// go test -v -timeout 30s -run ^TestDisable$ github.com/easymirror/easymirror-backend/internal/hosts/pool
+func TestDisable(t *testing.T) {
+	tests := []struct {
+		Err      error
+		Disabled bool
+	}{
+		{Err: nil, Disabled: false},
+		{Err: errors.New("connection reset"), Disabled: false},
+		{Err: fmt.Errorf("upload error: %w", hosts.ErrUnauthorized), Disabled: true},
+		{Err: fmt.Errorf("upload error: %w", hosts.ErrQuotaExceeded), Disabled: true},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			store := memoryStore{}
+			p := New("pixeldrain", []Account{{ID: "a"}}, store)
+			l, err := p.Next(0)
+			require.NoError(t, err)
+			l.Done(0, test.Err)
+
+			status := p.Status()
+			require.Len(t, status, 1)
+			assert.Equal(t, test.Disabled, status[0].Disabled)
+			assert.Equal(t, test.Disabled, store["a"].DisabledAt != nil)
+			if !test.Disabled {
+				return
+			}
+			_, err = p.Next(0)
+			assert.ErrorIs(t, err, ErrExhausted)
+
+			require.NoError(t, p.Enable("a"))
+			_, err = p.Next(0)
+			assert.NoError(t, err)
+		})
+	}
+
+	t.Run("Enable unknown account", func(t *testing.T) {
+		assert.ErrorIs(t, New("bunkr", nil, nil).Enable("nope"), ErrNotFound)
+	})
+}
+
+// go test -v -timeout 30s -run ^TestParseAccounts$ github.com/easymirror/easymirror-backend/internal/hosts/pool
+func TestParseAccounts(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected int
+		Err      bool
+	}{
+		{Input: `[{"id":"pd-1","host":"pixeldrain","credentials":{"api_key":"k"},"weight":2,"daily_limit":1000}]`, Expected: 1},
+		{Input: `[]`, Expected: 0},
+		{Input: `[{"id":"pd-1"}]`, Err: true},                                       // Missing host
+		{Input: `[{"id":"a","host":"bunkr"},{"id":"a","host":"bunkr"}]`, Err: true}, // Duplicate id
+		{Input: `[{"id":"a","host":"bunkr","daily_limit":-1}]`, Err: true},          // Negative limit
+		{Input: `nope`, Err: true},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			accounts, err := ParseAccounts([]byte(test.Input))
+			if test.Err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, accounts, test.Expected)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+// DBStore keeps the usage of accounts in the `host_account_usage` table
+type DBStore struct {
+	*db.Database
+}
+
+// Load returns the usage of an account. Accounts without any usage yet get an empty one.
+func (s DBStore) Load(ctx context.Context, accountID string) (Usage, error) {
+	var u Usage
+	if s.Database == nil {
+		return u, errors.New("database is nil")
+	}
+	var day, reason sql.NullString
+	var disabledAt sql.NullTime
+	err := s.PostgresConn.QueryRowContext(ctx, `
+		SELECT day, day_bytes, storage_bytes, disabled_at, disabled_reason
+		FROM host_account_usage WHERE account_id=($1);
+	`, accountID).Scan(&day, &u.DayBytes, &u.StorageBytes, &disabledAt, &reason)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return u, nil
+	case err != nil:
+		return u, fmt.Errorf("query error: %w", err)
+	}
+	u.Day, u.DisabledReason = day.String, reason.String
+	if disabledAt.Valid {
+		u.DisabledAt = &disabledAt.Time
+	}
+	return u, nil
+}
+
+// Save stores the usage of an account
+func (s DBStore) Save(ctx context.Context, accountID string, u Usage) error {
+	if s.Database == nil {
+		return errors.New("database is nil")
+	}
+	_, err := s.PostgresConn.ExecContext(ctx, `
+		INSERT INTO host_account_usage (account_id, day, day_bytes, storage_bytes, disabled_at, disabled_reason)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6))
+		ON CONFLICT (account_id)
+		DO UPDATE
+		SET day = EXCLUDED.day, day_bytes = EXCLUDED.day_bytes, storage_bytes = EXCLUDED.storage_bytes,
+		disabled_at = EXCLUDED.disabled_at, disabled_reason = EXCLUDED.disabled_reason;
+	`, accountID, u.Day, u.DayBytes, u.StorageBytes, u.DisabledAt, u.DisabledReason)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
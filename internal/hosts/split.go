@@ -0,0 +1,147 @@
+package hosts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SplitMode is how files that are too large for a host are split into parts
+type SplitMode string
+
+const (
+	SplitNone  SplitMode = ""      // Files that are too large are skipped
+	SplitBytes SplitMode = "bytes" // Numbered chunks of the file, joined back with cat or copy /b
+	SplitZip   SplitMode = "zip"   // A multi-volume zip archive, opened with 7-Zip
+)
+
+var (
+	ErrUnknownSplitMode = errors.New("unknown split mode")
+	ErrSourceTooShort   = errors.New("source ended before the end of the file")
+)
+
+// ParseSplitMode converts the name of a split mode, an empty name means SplitNone
+func ParseSplitMode(name string) (SplitMode, error) {
+	switch m := SplitMode(name); m {
+	case SplitNone, SplitBytes, SplitZip:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownSplitMode, name)
+}
+
+// Part is a piece of a split file, uploaded as a file of its own
+type Part struct {
+	Index int    `json:"index"` // Starts at 1
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+}
+
+// Parts returns the parts a file is uploaded as, in order. Files that are not split are a single part.
+func (f File) Parts() []Part {
+	switch f.Split {
+	case SplitBytes:
+		var parts []Part
+		for offset := int64(0); offset < f.Size; offset += f.PartSize {
+			parts = append(parts, Part{
+				Index: len(parts) + 1,
+				Name:  fmt.Sprintf("%v.%03d", f.Name, len(parts)+1),
+				Size:  min(f.PartSize, f.Size-offset),
+			})
+		}
+		return parts
+	case SplitZip:
+		return newZipLayout(f.Name, f.Size, f.PartSize).parts()
+	}
+	return []Part{{Index: 1, Name: f.Name, Size: f.Size}}
+}
+
+// splitFor returns the file split to fit a host, or false if its parts would still be turned down
+func (c Capabilities) splitFor(f File, mode SplitMode) (File, bool) {
+	// The exact size of an archive is only known once it is made, it can't be cut into parts up front
+	if mode == SplitNone || f.Archive != nil || c.MaxFileSize <= 0 || c.Check(File{Name: f.Name}) != "" {
+		return f, false
+	}
+	f.Split, f.PartSize = mode, c.MaxFileSize
+	for _, p := range f.Parts() {
+		if c.Check(File{Name: p.Name, Size: p.Size}) != "" {
+			return f, false
+		}
+	}
+	return f, true
+}
+
+// Send streams a file read from source to a host with send, which returns the URL of the upload.
+// Split files are sent part by part, each part is read from source while it is sent.
+// The content of plain files is hashed on the way, see Upload.SHA256.
+func Send(ctx context.Context, f File, source io.Reader, send func(ctx context.Context, name string, body io.Reader) (string, error)) error {
+	if f.Upload == nil {
+		_, err := sendParts(ctx, f, source, send)
+		return err
+	}
+
+	var h *hashingReader
+	if f.Archive == nil && f.Encryption == nil {
+		h = newHashingReader(source)
+		source = h
+	}
+	locations, err := sendParts(ctx, f, source, send)
+	if err == nil {
+		f.Upload.finish(locations, h.sum(f.Size))
+	}
+	return err
+}
+
+// sendParts sends the parts of a file and returns the URLs of their uploads
+func sendParts(ctx context.Context, f File, source io.Reader, send func(ctx context.Context, name string, body io.Reader) (string, error)) ([]string, error) {
+	if f.Split == SplitNone {
+		location, err := send(ctx, f.Name, source)
+		if err != nil {
+			return nil, err
+		}
+		return []string{location}, nil
+	}
+
+	parts := f.Parts()
+	stream := source
+	if f.Split == SplitZip {
+		stream = newZipLayout(f.Name, f.Size, f.PartSize).stream(source)
+	}
+	locations := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		body := &io.LimitedReader{R: stream, N: p.Size}
+		location, err := send(ctx, p.Name, body)
+		if err != nil {
+			return nil, fmt.Errorf("part %v of %v: %w", p.Index, len(parts), err)
+		}
+		if body.N > 0 {
+			return nil, fmt.Errorf("part %v of %v: %w", p.Index, len(parts), ErrSourceTooShort)
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+// Reassembly returns how to put a split file back together from its parts
+func Reassembly(mode SplitMode, name string, parts []string) string {
+	switch mode {
+	case SplitBytes:
+		return fmt.Sprintf(
+			"Download every part into the same folder, then join them with `cat %v > %q` on macOS and Linux, or `copy /b %v %q` on Windows.",
+			strings.Join(parts, " "), name, strings.Join(parts, "+"), name,
+		)
+	case SplitZip:
+		if len(parts) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(
+			"Download every part into the same folder, then open %v with 7-Zip, or run `7z x %q`.",
+			parts[len(parts)-1], parts[len(parts)-1],
+		)
+	}
+	return ""
+}
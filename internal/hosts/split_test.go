@@ -0,0 +1,164 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendAll sends a file read from content and returns the parts that were sent, by name
+func sendAll(t *testing.T, f File, content []byte) ([]string, map[string][]byte, error) {
+	t.Helper()
+	var names []string
+	sent := map[string][]byte{}
+	err := Send(context.Background(), f, bytes.NewReader(content), func(ctx context.Context, name string, body io.Reader) (string, error) {
+		b, err := io.ReadAll(body)
+		names = append(names, name)
+		sent[name] = b
+		return "https://host.test/" + name, err
+	})
+	return names, sent, err
+}
+
+// go test -v -timeout 30s -run ^TestParseSplitMode$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestParseSplitMode(t *testing.T) {
+	for _, name := range []string{"", "bytes", "zip"} {
+		m, err := ParseSplitMode(name)
+		require.NoError(t, err)
+		assert.Equal(t, SplitMode(name), m)
+	}
+	_, err := ParseSplitMode("rar")
+	assert.ErrorIs(t, err, ErrUnknownSplitMode)
+}
+
+// go test -v -timeout 30s -run ^TestSendBytes$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestSendBytes(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 25)
+	f := File{Name: "video.mp4", Size: int64(len(content)), Split: SplitBytes, PartSize: 100}
+	assert.Equal(t, []Part{
+		{Index: 1, Name: "video.mp4.001", Size: 100},
+		{Index: 2, Name: "video.mp4.002", Size: 100},
+		{Index: 3, Name: "video.mp4.003", Size: 50},
+	}, f.Parts())
+
+	names, sent, err := sendAll(t, f, content)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"video.mp4.001", "video.mp4.002", "video.mp4.003"}, names)
+	var joined []byte
+	for _, name := range names {
+		joined = append(joined, sent[name]...)
+	}
+	assert.Equal(t, content, joined)
+
+	// Sources that end early fail the part they end in
+	_, _, err = sendAll(t, f, content[:150])
+	assert.ErrorIs(t, err, ErrSourceTooShort)
+}
+
+// go test -v -timeout 30s -run ^TestSendZip$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestSendZip(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	tests := []struct {
+		PartSize int64
+		Expected []int64
+	}{
+		{PartSize: 5000, Expected: []int64{5000, 5000, 5000, 1268}}, // Trailer in the last volume
+		{PartSize: 8100, Expected: []int64{8100, 7963, 205}},        // Trailer in its own volume
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			f := File{Name: "video.mp4", Size: int64(len(content)), Split: SplitZip, PartSize: test.PartSize}
+			parts := f.Parts()
+			var sizes []int64
+			for _, p := range parts {
+				sizes = append(sizes, p.Size)
+			}
+			assert.Equal(t, test.Expected, sizes)
+			assert.Equal(t, "video.z01", parts[0].Name)
+			assert.Equal(t, "video.zip", parts[len(parts)-1].Name)
+
+			names, sent, err := sendAll(t, f, content)
+			require.NoError(t, err)
+			require.Len(t, names, len(parts))
+			for _, p := range parts {
+				assert.Len(t, sent[p.Name], int(p.Size), p.Name)
+			}
+
+			// The first volume starts with the split signature and the local header, the data follows
+			first := sent["video.z01"]
+			assert.Equal(t, uint32(zipSplitSig), binary.LittleEndian.Uint32(first))
+			assert.Equal(t, uint32(zipLocalSig), binary.LittleEndian.Uint32(first[4:]))
+			var joined []byte
+			for _, name := range names {
+				joined = append(joined, sent[name]...)
+			}
+			start := 4 + zipLocalLen + len(f.Name)
+			assert.Equal(t, content, joined[start:start+len(content)])
+
+			// The central directory is in the last volume, where the ZIP64 record says it is
+			last := sent["video.zip"]
+			end := last[len(last)-zipEndLen:]
+			assert.Equal(t, uint32(zip64EndSig), binary.LittleEndian.Uint32(end))
+			assert.Equal(t, uint32(len(parts)-1), binary.LittleEndian.Uint32(end[16:]))
+			central := last[binary.LittleEndian.Uint64(end[48:]):]
+			assert.Equal(t, uint32(zipCentralSig), binary.LittleEndian.Uint32(central))
+			assert.Equal(t, crc32.ChecksumIEEE(content), binary.LittleEndian.Uint32(central[16:]))
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestSendError$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestSendError(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 25)
+	f := File{Name: "video.mp4", Size: int64(len(content)), Split: SplitBytes, PartSize: 100}
+	errHost := errors.New("host down")
+	var calls int
+	err := Send(context.Background(), f, bytes.NewReader(content), func(ctx context.Context, name string, body io.Reader) (string, error) {
+		calls++
+		if calls == 2 {
+			return "", errHost
+		}
+		_, err := io.Copy(io.Discard, body)
+		return "https://host.test/" + name, err
+	})
+	assert.ErrorIs(t, err, errHost)
+	assert.Equal(t, 2, calls)
+}
+
+// go test -v -timeout 30s -run ^TestPlanSplit$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestPlanSplit(t *testing.T) {
+	large := File{Name: "large.mp4", Size: 3 << 30}
+	tool := File{Name: "tool.exe", Size: 3 << 30}
+
+	plan, skips := Plan([]string{"bunkr"}, []File{large, tool}, SplitBytes)
+	require.Len(t, plan["bunkr"], 1)
+	split := plan["bunkr"][0]
+	assert.Equal(t, SplitBytes, split.Split)
+	assert.Equal(t, int64(2<<30), split.PartSize)
+	assert.Len(t, split.Parts(), 2)
+
+	// Splitting does not get blocked extensions through
+	assert.Equal(t, []Skip{{File: "tool.exe", Host: "bunkr", Reason: SkipTooLarge}}, skips)
+}
+
+// go test -v -timeout 30s -run ^TestReassembly$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestReassembly(t *testing.T) {
+	assert.Equal(t,
+		"Download every part into the same folder, then join them with `cat a.mp4.001 a.mp4.002 > \"a.mp4\"` on macOS and Linux, or `copy /b a.mp4.001+a.mp4.002 \"a.mp4\"` on Windows.",
+		Reassembly(SplitBytes, "a.mp4", []string{"a.mp4.001", "a.mp4.002"}),
+	)
+	assert.Equal(t,
+		"Download every part into the same folder, then open a.zip with 7-Zip, or run `7z x \"a.zip\"`.",
+		Reassembly(SplitZip, "a.mp4", []string{"a.z01", "a.zip"}),
+	)
+	assert.Empty(t, Reassembly(SplitNone, "a.mp4", []string{"a.mp4"}))
+}
@@ -0,0 +1,258 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VerifyMode is how uploads are checked against their original once a host took them
+type VerifyMode string
+
+const (
+	VerifyNone   VerifyMode = ""       // Uploads are trusted once the host says they succeeded
+	VerifyFull   VerifyMode = "full"   // Uploads are downloaded again and hashed
+	VerifySample VerifyMode = "sample" // Only a few ranges of each upload are downloaded and compared
+)
+
+// Ranges downloaded from each upload with VerifySample
+const (
+	sampleCount = 3        // At the start, in the middle and at the end
+	sampleSize  = 64 << 10 // 64 KiB
+)
+
+var (
+	ErrUnknownVerifyMode = errors.New("unknown verify mode")
+	ErrCorrupt           = errors.New("file on the host differs from the original")
+	ErrNotUploaded       = errors.New("file was not uploaded")
+)
+
+// ParseVerifyMode converts the name of a verify mode, an empty name means VerifyNone
+func ParseVerifyMode(name string) (VerifyMode, error) {
+	switch m := VerifyMode(name); m {
+	case VerifyNone, VerifyFull, VerifySample:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownVerifyMode, name)
+}
+
+// Verify downloads a file again from where it was uploaded to, or samples of it, and compares it with what was sent.
+// The file must have been uploaded with an Upload to learn its locations, ErrNotUploaded is returned otherwise.
+// Errors wrapping ErrCorrupt mean the upload differs, other errors mean it could not be checked.
+func Verify(ctx context.Context, client *http.Client, mode VerifyMode, f File, open Opener) error {
+	locations := f.Upload.Locations()
+	parts := f.Parts()
+	if len(locations) == 0 {
+		return ErrNotUploaded
+	} else if len(locations) != len(parts) {
+		return fmt.Errorf("%v uploads for %v parts", len(locations), len(parts))
+	}
+	if f.Split == SplitNone {
+		parts[0].Size = f.sentSize()
+	}
+
+	switch mode {
+	case VerifyFull:
+		return verifyFull(ctx, client, f, locations, parts, open)
+	case VerifySample:
+		return verifySample(ctx, client, f, locations, parts, open)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownVerifyMode, mode)
+}
+
+// verifyFull downloads every part of a file and compares their sizes and the hash of the whole
+func verifyFull(ctx context.Context, client *http.Client, f File, locations []string, parts []Part, open Opener) error {
+	h := sha256.New()
+	for i, location := range locations {
+		body, _, err := fetch(ctx, client, location, 0, -1)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(h, body)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
+		}
+		if n != parts[i].Size {
+			return fmt.Errorf("%w: %q is %v bytes, want %v", ErrCorrupt, parts[i].Name, n, parts[i].Size)
+		}
+	}
+
+	want, ok := f.sentSum()
+	if !ok {
+		// Hash what was sent again from the source
+		sent, err := f.sent(ctx, open)
+		if err != nil {
+			return err
+		}
+		defer sent.Close()
+		sh := sha256.New()
+		if _, err = io.Copy(sh, sent); err != nil {
+			return fmt.Errorf("read source error: %w", err)
+		}
+		want = hex.EncodeToString(sh.Sum(nil))
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%w: sha256 is %v, want %v", ErrCorrupt, got, want)
+	}
+	return nil
+}
+
+// verifySample downloads a few ranges of every part of a file and compares them with the same ranges of what was sent
+func verifySample(ctx context.Context, client *http.Client, f File, locations []string, parts []Part, open Opener) error {
+	sent, err := f.sent(ctx, open)
+	if err != nil {
+		return err
+	}
+	defer sent.Close()
+
+	var read, start int64 // Bytes read from what was sent, and where the part starts in it
+	want := make([]byte, sampleSize)
+	for i, location := range locations {
+		part := parts[i]
+		for _, offset := range sampleOffsets(part.Size) {
+			n := min(sampleSize, part.Size-offset)
+			if _, err = io.CopyN(io.Discard, sent, start+offset-read); err != nil {
+				return fmt.Errorf("read source error: %w", err)
+			}
+			if _, err = io.ReadFull(sent, want[:n]); err != nil {
+				return fmt.Errorf("read source error: %w", err)
+			}
+			read = start + offset + n
+
+			length := n
+			if length == 0 {
+				length = -1 // Empty files have no range to ask for
+			}
+			body, size, err := fetch(ctx, client, location, offset, length)
+			if err != nil {
+				return err
+			}
+			got, err := io.ReadAll(io.LimitReader(body, n+1))
+			body.Close()
+			if err != nil {
+				return fmt.Errorf("read error: %w", err)
+			}
+			if size >= 0 && size != part.Size {
+				return fmt.Errorf("%w: %q is %v bytes, want %v", ErrCorrupt, part.Name, size, part.Size)
+			}
+			if !bytes.Equal(got, want[:n]) {
+				return fmt.Errorf("%w: %q differs at bytes %v-%v", ErrCorrupt, part.Name, offset, offset+n-1)
+			}
+		}
+		start += part.Size
+	}
+	return nil
+}
+
+// sampleOffsets returns where the samples of a part of size bytes start, in order and without overlapping
+func sampleOffsets(size int64) []int64 {
+	if size <= sampleCount*sampleSize {
+		return []int64{0} // The whole part
+	}
+	return []int64{0, (size - sampleSize) / 2, size - sampleSize}
+}
+
+// fetch downloads n bytes of a URL from offset, or everything from offset if n is negative.
+// It returns the body and the size of the whole file, -1 if the host does not tell.
+func fetch(ctx context.Context, client *http.Client, url string, offset, n int64) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("new request error: %w", err)
+	}
+	if n >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, offset+n-1))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request error: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, contentRangeSize(resp.Header.Get("Content-Range")), nil
+	case http.StatusOK:
+		// The host ignored the range, skip to it
+		if _, err = io.CopyN(io.Discard, resp.Body, offset); err != nil && err != io.EOF {
+			resp.Body.Close()
+			return nil, 0, fmt.Errorf("read error: %w", err)
+		}
+		if n < 0 {
+			return resp.Body, resp.ContentLength, nil
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, n), resp.Body}, resp.ContentLength, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("%w: status %v", ErrCorrupt, resp.StatusCode)
+	}
+	resp.Body.Close()
+	return nil, 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
+
+// contentRangeSize returns the size of the whole file from a Content-Range header, -1 if it is not known
+func contentRangeSize(header string) int64 {
+	_, size, ok := strings.Cut(header, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// sent reads what is sent for a file again from its source, all its parts one after another
+func (f File) sent(ctx context.Context, open Opener) (io.ReadCloser, error) {
+	source, err := f.Open(ctx, open)
+	if err != nil || f.Split != SplitZip {
+		return source, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{newZipLayout(f.Name, f.Size, f.PartSize).stream(source), source}, nil
+}
+
+// sentSize returns the size of what is sent for a file. The size of an archive is only known once it was sent.
+func (f File) sentSize() int64 {
+	if f.Archive == nil {
+		return f.Size
+	}
+	_, size, ok := f.Archive.Checksum()
+	if !ok {
+		return f.Size
+	}
+	if f.Encryption != nil {
+		return f.Encryption.Params.SealedSize(size)
+	}
+	return size
+}
+
+// sentSum returns the SHA-256 of what is sent for a file, if it is known without reading the file again
+func (f File) sentSum() (string, bool) {
+	if f.Encryption != nil || f.Split == SplitZip {
+		return "", false
+	}
+	if f.Archive != nil {
+		sum, _, ok := f.Archive.Checksum()
+		return sum, ok
+	}
+	sum := f.ContentHash()
+	return sum, sum != ""
+}
+
+// Retry returns a file to upload again, e.g. after it failed verification, with nothing learnt from the last upload
+func (f File) Retry() File {
+	return f.own()
+}
@@ -0,0 +1,142 @@
+package hosts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUploads stores what is sent to it and serves it back, with or without ranges
+type fakeUploads struct {
+	*httptest.Server
+	noRanges bool
+
+	mu      sync.Mutex
+	uploads map[string][]byte
+}
+
+func newFakeUploads(t *testing.T) *fakeUploads {
+	u := &fakeUploads{uploads: map[string][]byte{}}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		content, ok := u.uploads[strings.TrimPrefix(r.URL.Path, "/")]
+		u.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+		} else if u.noRanges {
+			w.Write(content)
+		} else {
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		}
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// send uploads a file to the fake the way an adapter would
+func (u *fakeUploads) send(t *testing.T, f File, open Opener) File {
+	t.Helper()
+	f.Upload = &Upload{}
+	source, err := f.Open(context.Background(), open)
+	require.NoError(t, err)
+	defer source.Close()
+	err = Send(context.Background(), f, source, func(ctx context.Context, name string, body io.Reader) (string, error) {
+		b, err := io.ReadAll(body)
+		u.mu.Lock()
+		u.uploads[name] = b
+		u.mu.Unlock()
+		return u.URL + "/" + name, err
+	})
+	require.NoError(t, err)
+	return f
+}
+
+// corrupt changes a byte of an upload
+func (u *fakeUploads) corrupt(name string, offset int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.uploads[name][offset] ^= 0xff
+}
+
+// go test -v -timeout 30s -run ^TestVerify$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestVerify(t *testing.T) {
+	content := strings.Repeat("0123456789", 30000) // 300 kB, sampled in three ranges
+	files, open := stagedFiles(map[string]string{"a.txt": content, "b.png": "png bytes"})
+	sum := sha256.Sum256([]byte(content))
+	plain := files[0]
+	plain.SHA256 = hex.EncodeToString(sum[:])
+	encrypted, err := Encrypt(files[0], make([]byte, 32))
+	require.NoError(t, err)
+
+	split := File{Name: "a.txt", URI: "a.txt", Size: plain.Size, Split: SplitBytes, PartSize: 100000}
+	tests := []struct {
+		File    File
+		Corrupt string // Upload to change a byte of
+		Offset  int
+		Err     error
+	}{
+		{File: plain},
+		{File: files[0]}, // Not hashed
+		{File: split},
+		{File: File{Name: "a.txt", URI: "a.txt", Size: plain.Size, Split: SplitZip, PartSize: 100000}},
+		{File: ArchiveFile("mirror", ArchiveZip, files)},
+		{File: encrypted},
+		{File: plain, Corrupt: "a.txt", Offset: 10, Err: ErrCorrupt},
+		{File: plain, Corrupt: "a.txt", Offset: len(content) - 1, Err: ErrCorrupt},
+		{File: split, Corrupt: "a.txt.002", Offset: 0, Err: ErrCorrupt},
+		{File: plain, Corrupt: "gone", Err: ErrCorrupt},
+	}
+
+	for _, mode := range []VerifyMode{VerifyFull, VerifySample} {
+		for _, noRanges := range []bool{false, true} {
+			for testNum, test := range tests {
+				t.Run(fmt.Sprintf("Test #%v (%v, no ranges: %v)", testNum, mode, noRanges), func(t *testing.T) {
+					uploads := newFakeUploads(t)
+					uploads.noRanges = noRanges
+					f := uploads.send(t, test.File.own(), open)
+					switch test.Corrupt {
+					case "":
+					case "gone":
+						uploads.uploads = map[string][]byte{}
+					default:
+						uploads.corrupt(test.Corrupt, test.Offset)
+					}
+
+					err := Verify(context.Background(), uploads.Client(), mode, f, open)
+					if test.Err != nil {
+						assert.ErrorIs(t, err, test.Err)
+					} else {
+						assert.NoError(t, err)
+					}
+				})
+			}
+		}
+	}
+}
+
+// go test -v -timeout 30s -run ^TestVerifyErrors$ github.com/easymirror/easymirror-backend/internal/hosts
+func TestVerifyErrors(t *testing.T) {
+	files, open := stagedFiles(map[string]string{"a.txt": "some notes"})
+	err := Verify(context.Background(), http.DefaultClient, VerifyFull, files[0], open)
+	assert.ErrorIs(t, err, ErrNotUploaded)
+
+	uploads := newFakeUploads(t)
+	f := uploads.send(t, files[0], open)
+	err = Verify(context.Background(), uploads.Client(), "quick", f, open)
+	assert.ErrorIs(t, err, ErrUnknownVerifyMode)
+
+	_, err = ParseVerifyMode("quick")
+	assert.ErrorIs(t, err, ErrUnknownVerifyMode)
+}
@@ -0,0 +1,203 @@
+package hosts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+)
+
+// Signatures and fields of the zip records, see https://pkware.cachefly.net/webdocs/casestudies/APPNOTE.TXT
+const (
+	zipSplitSig   = 0x08074b50 // Starts the first volume of a split archive
+	zipLocalSig   = 0x04034b50
+	zipDescSig    = 0x08074b50
+	zipCentralSig = 0x02014b50
+	zip64EndSig   = 0x06064b50
+	zip64LocSig   = 0x07064b50
+	zipEndSig     = 0x06054b50
+
+	zipVersion = 45     // ZIP64
+	zipFlags   = 0x0808 // The CRC follows the data, the name is UTF-8
+	zipDate    = 0x21   // 1980-01-01
+	zipMax32   = 0xffffffff
+
+	zipLocalLen   = 30 + 20      // Local file header with its ZIP64 extra field, without the name
+	zipDescLen    = 24           // ZIP64 data descriptor
+	zipCentralLen = 46 + 28      // Central directory header with its ZIP64 extra field, without the name
+	zipEndLen     = 56 + 20 + 22 // ZIP64 end of central directory record and locator, and end of central directory record
+)
+
+// zipLayout is where the records of a split archive holding a single stored file fall.
+// The file is stored as is, so every size is known before it is read and only its CRC is written after it.
+type zipLayout struct {
+	name       string
+	size       int64   // Size of the file
+	volumes    []int64 // Size of each volume
+	tailOffset int64   // Offset of the data descriptor in the last volume
+}
+
+// newZipLayout lays out the archive of a file in volumes of up to volumeSize bytes.
+// Headers are never split across volumes, so volumes too small to hold them are made bigger.
+func newZipLayout(name string, size, volumeSize int64) zipLayout {
+	n := int64(len(name))
+	head := 4 + zipLocalLen + n
+	tail := zipDescLen + zipCentralLen + n + zipEndLen
+	volumeSize = max(volumeSize, head, tail)
+
+	l := zipLayout{name: name, size: size}
+	body := head + size
+	for ; body > volumeSize; body -= volumeSize {
+		l.volumes = append(l.volumes, volumeSize)
+	}
+	if body+tail <= volumeSize {
+		l.volumes = append(l.volumes, body+tail)
+		l.tailOffset = body
+	} else {
+		l.volumes = append(l.volumes, body, tail)
+	}
+	return l
+}
+
+// parts returns the volumes as parts, named like zip names them: name.z01, name.z02, ... and name.zip last
+func (l zipLayout) parts() []Part {
+	base := strings.TrimSuffix(l.name, path.Ext(l.name))
+	if base == "" {
+		base = l.name
+	}
+	parts := make([]Part, len(l.volumes))
+	for i, size := range l.volumes {
+		parts[i] = Part{Index: i + 1, Name: fmt.Sprintf("%v.z%02d", base, i+1), Size: size}
+	}
+	parts[len(parts)-1].Name = base + ".zip"
+	return parts
+}
+
+// stream returns the whole archive, to be cut into volumes by the caller
+func (l zipLayout) stream(source io.Reader) io.Reader {
+	crc := crc32.NewIEEE()
+	data := &io.LimitedReader{R: io.TeeReader(source, crc), N: l.size}
+	return io.MultiReader(
+		bytes.NewReader(l.header()),
+		data,
+		&trailerReader{fn: func() ([]byte, error) {
+			if data.N > 0 {
+				return nil, ErrSourceTooShort
+			}
+			return l.trailer(crc.Sum32()), nil
+		}},
+	)
+}
+
+// header returns the start of the first volume, up to the data
+func (l zipLayout) header() []byte {
+	var b zipBuffer
+	b.u32(zipSplitSig)
+	b.u32(zipLocalSig)
+	b.u16(zipVersion)
+	b.u16(zipFlags)
+	b.u16(0) // Stored
+	b.u16(0) // Time
+	b.u16(zipDate)
+	b.u32(0) // The CRC is in the data descriptor
+	b.u32(zipMax32)
+	b.u32(zipMax32)
+	b.u16(uint16(len(l.name)))
+	b.u16(20)
+	b.WriteString(l.name)
+	b.u16(1) // ZIP64 extra field
+	b.u16(16)
+	b.u64(uint64(l.size))
+	b.u64(uint64(l.size))
+	return b.Bytes()
+}
+
+// trailer returns the end of the last volume, from the data descriptor on
+func (l zipLayout) trailer(crc uint32) []byte {
+	disk := uint32(len(l.volumes) - 1)
+	centralOffset := uint64(l.tailOffset + zipDescLen)
+	centralLen := uint64(zipCentralLen + len(l.name))
+
+	var b zipBuffer
+	b.u32(zipDescSig)
+	b.u32(crc)
+	b.u64(uint64(l.size))
+	b.u64(uint64(l.size))
+
+	b.u32(zipCentralSig)
+	b.u16(zipVersion)
+	b.u16(zipVersion)
+	b.u16(zipFlags)
+	b.u16(0) // Stored
+	b.u16(0) // Time
+	b.u16(zipDate)
+	b.u32(crc)
+	b.u32(zipMax32)
+	b.u32(zipMax32)
+	b.u16(uint16(len(l.name)))
+	b.u16(28)
+	b.u16(0) // Comment
+	b.u16(0) // The local header is on the first volume
+	b.u16(0) // Internal attributes
+	b.u32(0) // External attributes
+	b.u32(zipMax32)
+	b.WriteString(l.name)
+	b.u16(1) // ZIP64 extra field
+	b.u16(24)
+	b.u64(uint64(l.size))
+	b.u64(uint64(l.size))
+	b.u64(4) // The local header follows the split signature
+
+	b.u32(zip64EndSig)
+	b.u64(44)
+	b.u16(zipVersion)
+	b.u16(zipVersion)
+	b.u32(disk)
+	b.u32(disk)
+	b.u64(1)
+	b.u64(1)
+	b.u64(centralLen)
+	b.u64(centralOffset)
+
+	b.u32(zip64LocSig)
+	b.u32(disk)
+	b.u64(centralOffset + centralLen)
+	b.u32(disk + 1)
+
+	b.u32(zipEndSig)
+	b.u16(uint16(min(disk, 0xffff)))
+	b.u16(uint16(min(disk, 0xffff)))
+	b.u16(1)
+	b.u16(1)
+	b.u32(uint32(centralLen))
+	b.u32(zipMax32) // Readers take the offset from the ZIP64 record
+	b.u16(0)
+	return b.Bytes()
+}
+
+// zipBuffer writes little endian fields
+type zipBuffer struct{ bytes.Buffer }
+
+func (b *zipBuffer) u16(v uint16) { b.Write(binary.LittleEndian.AppendUint16(nil, v)) }
+func (b *zipBuffer) u32(v uint32) { b.Write(binary.LittleEndian.AppendUint32(nil, v)) }
+func (b *zipBuffer) u64(v uint64) { b.Write(binary.LittleEndian.AppendUint64(nil, v)) }
+
+// trailerReader reads bytes that can only be made once everything before them was read
+type trailerReader struct {
+	fn func() ([]byte, error)
+	r  *bytes.Reader
+}
+
+func (t *trailerReader) Read(p []byte) (int, error) {
+	if t.r == nil {
+		b, err := t.fn()
+		if err != nil {
+			return 0, err
+		}
+		t.r = bytes.NewReader(b)
+	}
+	return t.r.Read(p)
+}
@@ -0,0 +1,58 @@
+// Package jobs runs periodic background work such as cleaning up stale rows.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a unit of work that runs on an interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Timeout  time.Duration // Max duration of a single run. Defaults to the interval.
+	Run      func(ctx context.Context) error
+}
+
+// Start runs every job in its own goroutine until the context is cancelled.
+// Each job runs once right away and then every interval. Runs of the same job never overlap.
+func Start(ctx context.Context, jobs ...Job) {
+	for _, job := range jobs {
+		go loop(ctx, job)
+	}
+}
+
+// loop runs a single job until the context is cancelled
+func loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		runOnce(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs a job a single time, recovering from panics so one bad run does not stop the loop
+func runOnce(ctx context.Context, job Job) {
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = job.Interval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[jobs] %v panicked: %v", job.Name, r)
+		}
+	}()
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		log.Printf("[jobs] %v failed after %v: %v", job.Name, time.Since(start), err)
+	}
+}
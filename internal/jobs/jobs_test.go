@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestStart$ github.com/easymirror/easymirror-backend/internal/jobs
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ok, failing, panicking atomic.Int32
+	Start(ctx,
+		Job{Name: "ok", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+			ok.Add(1)
+			return nil
+		}},
+		Job{Name: "failing", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+			failing.Add(1)
+			return errors.New("boom")
+		}},
+		Job{Name: "panicking", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+			panicking.Add(1)
+			panic("boom")
+		}},
+	)
+
+	// Errors and panics must not stop a job from running again
+	assert.Eventually(t, func() bool {
+		return ok.Load() >= 2 && failing.Load() >= 2 && panicking.Load() >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,130 @@
+// Package linkhealth probes the links of mirrors on the hosts, as files get deleted, taken down or expire.
+//
+// Every probe is recorded, so the latest status of each link shows up on the mirror and its history can be looked back at.
+// When a link dies, the mirror is uploaded to the host again if a copy of its files was retained.
+package linkhealth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/api/v1/handlers/upload"
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/bunkr"
+	"github.com/easymirror/easymirror-backend/internal/hosts/httpx"
+	"github.com/easymirror/easymirror-backend/internal/hosts/pixeldrain"
+	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/google/uuid"
+)
+
+// Defaults of a Monitor
+const (
+	DefaultRecheck = 24 * time.Hour        // How long a link is trusted after it was probed
+	DefaultBatch   = 500                   // Links probed per run
+	DefaultHistory = 90 * (24 * time.Hour) // How long probes are kept
+)
+
+// Remirror uploads the files of a mirror to a host again
+type Remirror func(ctx context.Context, mirrorID, host string) error
+
+// Monitor probes the links that are due on every run, see Monitor.Run
+type Monitor struct {
+	Database *db.Database
+	Remirror Remirror      // Called for hosts with a dead link, nil to only record the probes
+	Recheck  time.Duration // DefaultRecheck if 0
+	Batch    int           // DefaultBatch if 0
+	History  time.Duration // DefaultHistory if 0
+}
+
+// Run probes the links that were not probed within the recheck period and records what was found.
+// Mirrors with a dead link on a host are uploaded to it again, at most once per run.
+func (m *Monitor) Run(ctx context.Context) error {
+	recheck, batch, history := m.Recheck, m.Batch, m.History
+	if recheck <= 0 {
+		recheck = DefaultRecheck
+	}
+	if batch <= 0 {
+		batch = DefaultBatch
+	}
+	if history <= 0 {
+		history = DefaultHistory
+	}
+
+	now := time.Now().UTC()
+	if n, err := mirrorlink.PruneLinkChecks(ctx, m.Database, now.Add(-history)); err != nil {
+		return fmt.Errorf("PruneLinkChecks error: %w", err)
+	} else if n > 0 {
+		log.Printf("Pruned %v link checks", n)
+	}
+	links, err := mirrorlink.GetDueLinks(ctx, m.Database, now.Add(-recheck), batch)
+	if err != nil {
+		return fmt.Errorf("GetDueLinks error: %w", err)
+	}
+
+	dead := map[target]bool{}
+	for _, l := range links {
+		c := check(ctx, l)
+		if err = mirrorlink.AddLinkCheck(ctx, m.Database, l.MirrorID, c); err != nil {
+			return fmt.Errorf("AddLinkCheck error: %w", err)
+		}
+		if c.Status == string(hosts.HealthDead) {
+			dead[target{l.MirrorID, l.Host}] = true
+		}
+	}
+	if len(links) > 0 {
+		log.Printf("Probed %v links, %v mirrors have dead links", len(links), len(dead))
+	}
+
+	if m.Remirror == nil {
+		return nil
+	}
+	for t := range dead {
+		err := m.Remirror(ctx, t.mirrorID.String(), t.host)
+		if errors.Is(err, upload.ErrNoSourceFiles) || errors.Is(err, upload.ErrEncryptedMirror) || errors.Is(err, upload.ErrUnknownHost) {
+			continue // Nothing to upload again, or nowhere to upload it, the dead link stays on the mirror
+		} else if err != nil {
+			log.Printf("Error remirroring %v to %v: %v", t.mirrorID, t.host, err)
+		}
+	}
+	return ctx.Err()
+}
+
+// target is a mirror on a host
+type target struct {
+	mirrorID uuid.UUID
+	host     string
+}
+
+// check probes a link
+func check(ctx context.Context, l mirrorlink.Link) mirrorlink.LinkCheck {
+	health, err := Probe(ctx, l.Host, l.URL)
+	c := mirrorlink.LinkCheck{
+		Host:      l.Host,
+		Level:     l.Level,
+		URL:       l.URL,
+		Status:    string(health),
+		CheckedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		c.Detail = err.Error()
+	}
+	return c
+}
+
+// Probe checks whether a link on a host still works, the way the host is best asked
+func Probe(ctx context.Context, host, link string) (hosts.Health, error) {
+	if link == "" {
+		return hosts.HealthUnknown, errors.New("empty link")
+	}
+	switch host {
+	case "bunkr":
+		return bunkr.Probe(ctx, link)
+	case "pixeldrain":
+		return pixeldrain.Probe(ctx, link)
+	}
+	return hosts.ProbeURL(ctx, httpx.Client(host), link)
+}
@@ -0,0 +1,68 @@
+package linkhealth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/easymirror/easymirror-backend/internal/hosts/hoststest"
+	"github.com/easymirror/easymirror-backend/internal/hosts/pixeldrain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -timeout 30s -run ^TestProbe$ github.com/easymirror/easymirror-backend/internal/linkhealth
+func TestProbe(t *testing.T) {
+	srv := hoststest.NewServer(t)
+	fake := hoststest.NewPixelDrain(srv)
+	srv.Handle("files.example.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/alive":
+			w.WriteHeader(http.StatusOK)
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	srv.Install(t)
+
+	files := []hosts.File{
+		srv.AddSource("photo.png", []byte("png bytes")),
+		srv.AddSource("notes.txt", []byte("some notes")),
+	}
+	folder, err := pixeldrain.Upload(context.Background(), hosts.Credentials{}, "mirror-id", files)
+	require.NoError(t, err)
+	ids := fake.Folders()[0].Files
+	fake.Delete(ids[1])
+
+	tests := []struct {
+		Host, Link string
+		Expected   hosts.Health
+		Err        bool
+	}{
+		{Host: "pixeldrain", Link: folder, Expected: hosts.HealthAlive},
+		{Host: "pixeldrain", Link: pixeldrain.FileURL(ids[0]), Expected: hosts.HealthAlive},
+		{Host: "pixeldrain", Link: pixeldrain.FileURL(ids[1]), Expected: hosts.HealthDead},
+		{Host: "pixeldrain", Link: folder + "404", Expected: hosts.HealthDead},
+		{Host: "pixeldrain", Link: "https://files.example.com/alive", Expected: hosts.HealthUnknown, Err: true}, // Not a pixeldrain link
+		{Host: "cyberfile", Link: "https://files.example.com/alive", Expected: hosts.HealthAlive},
+		{Host: "cyberfile", Link: "https://files.example.com/gone", Expected: hosts.HealthDead},
+		{Host: "cyberfile", Link: "https://files.example.com/down", Expected: hosts.HealthUnknown, Err: true},
+		{Host: "cyberfile", Expected: hosts.HealthUnknown, Err: true},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result, err := Probe(context.Background(), test.Host, test.Link)
+			if test.Err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
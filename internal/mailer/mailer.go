@@ -0,0 +1,75 @@
+// Package mailer sends transactional emails such as login links.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+)
+
+var ErrNotConfigured = errors.New("SMTP_HOST is not set, set MAILER_LOG=true to log emails instead in local development")
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// FromEnv returns an SMTP mailer if `SMTP_HOST` is set.
+// Otherwise it returns ErrNotConfigured, unless `MAILER_LOG` is true for local development.
+// Then it returns an Outbox that logs messages, working login links and all, so it must never be used in production.
+func FromEnv() (Mailer, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		if os.Getenv("MAILER_LOG") != "true" {
+			return nil, ErrNotConfigured
+		}
+		log.Println("MAILER_LOG is true, emails will be logged instead of sent.")
+		return &Outbox{Log: true}, nil
+	}
+	return NewSMTP(host, os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM")), nil
+}
+
+// Outbox is an in-memory Mailer. It is used as a local stand-in for SMTP and in tests.
+type Outbox struct {
+	Log bool // If true, messages are also written to the log
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send stores the message in the outbox
+func (o *Outbox) Send(ctx context.Context, m Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, m)
+	if o.Log {
+		log.Printf("[mailer] to=%v subject=%q\n%v\n", m.To, m.Subject, m.Body)
+	}
+	return nil
+}
+
+// Messages returns a copy of all messages sent so far
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Message{}, o.messages...)
+}
+
+// Last returns the most recently sent message, if any
+func (o *Outbox) Last() (Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.messages) == 0 {
+		return Message{}, false
+	}
+	return o.messages[len(o.messages)-1], true
+}
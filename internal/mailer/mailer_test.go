@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestOutbox$ github.com/easymirror/easymirror-backend/internal/mailer
+func TestOutbox(t *testing.T) {
+	o := &Outbox{}
+	_, ok := o.Last()
+	assert.False(t, ok)
+
+	o.Send(context.Background(), Message{To: "a@example.com", Subject: "One"})
+	o.Send(context.Background(), Message{To: "b@example.com", Subject: "Two"})
+
+	assert.Len(t, o.Messages(), 2)
+	last, ok := o.Last()
+	assert.True(t, ok)
+	assert.Equal(t, "b@example.com", last.To)
+}
+
+// go test -v -timeout 30s -run ^TestBuildMessage$ github.com/easymirror/easymirror-backend/internal/mailer
+func TestBuildMessage(t *testing.T) {
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	msg, err := buildMessage("no-reply@easymirror.io", Message{To: "a@example.com", Subject: "Hello", Body: "line 1\nline 2"}, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(msg)
+	assert.True(t, strings.HasPrefix(s, "From: no-reply@easymirror.io\r\nTo: a@example.com\r\nSubject: Hello\r\n"))
+	assert.Contains(t, s, "Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(s, "\r\n\r\nline 1\r\nline 2"))
+
+	// Header injection
+	_, err = buildMessage("no-reply@easymirror.io", Message{To: "a@example.com\r\nBcc: b@example.com"}, date)
+	assert.Error(t, err)
+}
+
+// go test -v -timeout 30s -run ^TestFromEnv$ github.com/easymirror/easymirror-backend/internal/mailer
+func TestFromEnv(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("MAILER_LOG", "")
+	_, err := FromEnv()
+	assert.ErrorIs(t, err, ErrNotConfigured)
+
+	// Only an explicit flag logs emails
+	t.Setenv("MAILER_LOG", "true")
+	m, err := FromEnv()
+	assert.NoError(t, err)
+	assert.IsType(t, &Outbox{}, m)
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	m, err = FromEnv()
+	assert.NoError(t, err)
+	assert.IsType(t, &SMTP{}, m)
+}
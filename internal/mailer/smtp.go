@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSMTPPort = "587"
+)
+
+// SMTP sends emails through an SMTP server
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP returns a mailer that sends emails through an SMTP server.
+// Credentials are optional; if given, PLAIN auth is used.
+func NewSMTP(host, port, username, password, from string) *SMTP {
+	if port == "" {
+		port = defaultSMTPPort
+	}
+	s := &SMTP{addr: net.JoinHostPort(host, port), from: from}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+// Send sends a message
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	msg, err := buildMessage(s.from, m, time.Now())
+	if err != nil {
+		return fmt.Errorf("buildMessage error: %w", err)
+	}
+
+	// net/smtp does not take a context, so run it in a goroutine and respect cancellation
+	errc := make(chan error, 1)
+	go func() {
+		errc <- smtp.SendMail(s.addr, s.auth, s.from, []string{m.To}, msg)
+	}()
+	select {
+	case err = <-errc:
+		if err != nil {
+			return fmt.Errorf("SendMail error: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// buildMessage builds an RFC 5322 message with a plain-text body
+func buildMessage(from string, m Message, date time.Time) ([]byte, error) {
+	for _, v := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("header contains a new line: %q", v)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %v\r\n", from)
+	fmt.Fprintf(&b, "To: %v\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %v\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return b.Bytes(), nil
+}
@@ -0,0 +1,67 @@
+package mirrorlink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+// Archive is an archive of all the files of a mirror link, uploaded to a host
+type Archive struct {
+	Host      string    `json:"host"`
+	Format    string    `json:"format"` // See hosts.ArchiveFormat
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size"`
+	SHA256    string    `json:"sha256"` // Hex checksum of the archive, to check the download
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddArchiveTx records the archive uploaded to a host with a given TX, but does NOT commit it.
+// An archive recorded for the host before is replaced.
+func AddArchiveTx(tx *sql.Tx, mirrorID string, a Archive) error {
+	_, err := tx.Exec(`
+		INSERT INTO archives (mirror_id, host, format, name, size_bytes, sha256, created_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($7))
+		ON CONFLICT (mirror_id, host)
+		DO UPDATE
+		SET format = EXCLUDED.format, name = EXCLUDED.name, size_bytes = EXCLUDED.size_bytes, sha256 = EXCLUDED.sha256, created_at = EXCLUDED.created_at;
+	`, mirrorID, a.Host, a.Format, a.Name, a.SizeBytes, a.SHA256, a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetArchives returns the archives of a mirror link, by host
+func GetArchives(ctx context.Context, db *db.Database, mirrorID string) ([]Archive, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT host, format, name, size_bytes, sha256, created_at
+		FROM archives
+		WHERE mirror_id=($1)
+		ORDER BY host;
+	`, mirrorID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	archives := []Archive{}
+	for rows.Next() {
+		var a Archive
+		if err := rows.Scan(&a.Host, &a.Format, &a.Name, &a.SizeBytes, &a.SHA256, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		archives = append(archives, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return archives, nil
+}
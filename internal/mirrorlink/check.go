@@ -0,0 +1,87 @@
+package mirrorlink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+// Statuses of a file check, and of a mirror link as a whole
+const (
+	CheckVerified = "verified" // The file on the host is the same as the original
+	CheckCorrupt  = "corrupt"  // The file on the host differs from the original, or is gone
+)
+
+// FileCheck is the result of comparing a file uploaded to a host with its original
+type FileCheck struct {
+	Host      string    `json:"host"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Detail    string    `json:"detail,omitempty"` // What differs, for corrupt files
+	Attempts  int       `json:"attempts"`         // Times the file was uploaded to the host
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// AddCheckTx records the check of a file on a host with a given TX, but does NOT commit it.
+// A check recorded for the file on the host before is replaced.
+func AddCheckTx(tx *sql.Tx, mirrorID string, c FileCheck) error {
+	_, err := tx.Exec(`
+		INSERT INTO file_checks (mirror_id, host, name, status, detail, attempts, checked_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($7))
+		ON CONFLICT (mirror_id, host, name)
+		DO UPDATE
+		SET status = EXCLUDED.status, detail = EXCLUDED.detail, attempts = EXCLUDED.attempts, checked_at = EXCLUDED.checked_at;
+	`, mirrorID, c.Host, c.Name, c.Status, c.Detail, c.Attempts, c.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetChecks returns the checks of the files of a mirror link
+func GetChecks(ctx context.Context, db *db.Database, mirrorID string) ([]FileCheck, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT host, name, status, detail, attempts, checked_at
+		FROM file_checks
+		WHERE mirror_id=($1)
+		ORDER BY host, name;
+	`, mirrorID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	checks := []FileCheck{}
+	for rows.Next() {
+		var c FileCheck
+		if err := rows.Scan(&c.Host, &c.Name, &c.Status, &c.Detail, &c.Attempts, &c.CheckedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return checks, nil
+}
+
+// checkStatus returns the status of a mirror link from the checks of its files:
+// corrupt if any file is, verified if every checked file is, or empty if no file was checked
+func checkStatus(checks []FileCheck) string {
+	if len(checks) == 0 {
+		return ""
+	}
+	for _, c := range checks {
+		if c.Status == CheckCorrupt {
+			return CheckCorrupt
+		}
+	}
+	return CheckVerified
+}
@@ -0,0 +1,72 @@
+package mirrorlink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+)
+
+// SetFileHash records the SHA-256 of a file's content once it was hashed
+func SetFileHash(ctx context.Context, db *db.Database, fileID uuid.UUID, sha256 string) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	if _, err := db.PostgresConn.ExecContext(ctx, `UPDATE files SET sha256=($1) WHERE id=($2);`, sha256, fileID); err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// SetFileHashTx records the SHA-256 of a file's content with a given TX, but does NOT commit it
+func SetFileHashTx(tx *sql.Tx, fileID uuid.UUID, sha256 string) error {
+	if _, err := tx.Exec(`UPDATE files SET sha256=($1) WHERE id=($2);`, sha256, fileID); err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// AddHostFileTx records the ID and public URL a file has on a host with a given TX, but does NOT commit it.
+// An ID recorded for the file on the host before, e.g. by an earlier mirror, is replaced.
+func AddHostFileTx(tx *sql.Tx, fileID uuid.UUID, host, remoteID, url string) error {
+	_, err := tx.Exec(`
+		INSERT INTO host_files (file_id, host, remote_id, url, created_at)
+		VALUES (($1), ($2), ($3), ($4), ($5))
+		ON CONFLICT (file_id, host)
+		DO UPDATE
+		SET remote_id = EXCLUDED.remote_id, url = EXCLUDED.url, created_at = EXCLUDED.created_at;
+	`, fileID, host, remoteID, url, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// FindHostFile returns the ID of the latest upload of some content to a host, or ErrFileNotFound.
+// Only files in mirrors of the user that created the given mirror are looked at, and only mirrors that still exist.
+func FindHostFile(ctx context.Context, db *db.Database, mirrorID, host, sha256 string) (string, error) {
+	if db == nil {
+		return "", errors.New("database is nil")
+	}
+	var remoteID string
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT host_files.remote_id
+		FROM host_files
+		JOIN files ON files.id = host_files.file_id
+		JOIN mirroring_links ON mirroring_links.id = files.mirror_link_id
+		WHERE files.sha256=($1) AND host_files.host=($2)
+		AND mirroring_links.created_by_id = (SELECT created_by_id FROM mirroring_links WHERE id=($3))
+		ORDER BY host_files.created_at DESC
+		LIMIT 1;
+	`, sha256, host, mirrorID).Scan(&remoteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrFileNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("query error: %w", err)
+	}
+	return remoteID, nil
+}
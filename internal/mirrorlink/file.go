@@ -2,9 +2,11 @@ package mirrorlink
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/easymirror/easymirror-backend/internal/db"
@@ -16,10 +18,12 @@ type File struct {
 	Name       string    `json:"name"`        // Name of the file
 	SizeBytes  int64     `json:"size"`        // Size of the file in bytes
 	UploadDate time.Time `json:"upload_date"` // Date the file was uploaded
+	SHA256     string    `json:"sha256"`      // Hex SHA-256 of the content, empty until the file was mirrored
 }
 
-// GetFilesFromMirror returns a list of files from a given mirror link
-func GetFilesFromMirror(ctx context.Context, db *db.Database, mirrorId, userID string) ([]File, error) {
+// GetFilesFromMirror returns a list of files from a given mirror link.
+// Callers must check that the user is allowed to view the mirror link.
+func GetFilesFromMirror(ctx context.Context, db *db.Database, mirrorId string) ([]File, error) {
 	if db == nil {
 		return nil, errors.New("database is nil")
 	}
@@ -31,15 +35,12 @@ func GetFilesFromMirror(ctx context.Context, db *db.Database, mirrorId, userID s
 	}
 
 	// Get files
-	// We use a join here to ensure that we only select files that belongs to the user
 	query := `
-		SELECT files.id, files.name, files.size_bytes, files.upload_date
-		FROM mirroring_links INNER JOIN files
-		ON mirroring_links.id = files.mirror_link_id
-		WHERE mirroring_links.created_by_id=($1)
-		AND mirroring_links.id=($2);
+		SELECT files.id, files.name, files.size_bytes, files.upload_date, COALESCE(files.sha256, '')
+		FROM files
+		WHERE files.mirror_link_id=($1);
 	`
-	rows, err := tx.Query(query, userID, mirrorId)
+	rows, err := tx.Query(query, mirrorId)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %w", err)
 	}
@@ -51,7 +52,7 @@ func GetFilesFromMirror(ctx context.Context, db *db.Database, mirrorId, userID s
 
 		// Scan the results into the appropriate variables.
 		f := File{}
-		if err := rows.Scan(&f.ID, &f.Name, &f.SizeBytes, &f.UploadDate); err != nil {
+		if err := rows.Scan(&f.ID, &f.Name, &f.SizeBytes, &f.UploadDate, &f.SHA256); err != nil {
 			log.Println("Error scanning row:", err)
 			continue
 		}
@@ -61,3 +62,79 @@ func GetFilesFromMirror(ctx context.Context, db *db.Database, mirrorId, userID s
 	}
 	return files, nil
 }
+
+// AddFile records a new file in a mirror link.
+// The name is cleaned and made unique within the mirror link, the stored name is returned in the file.
+func AddFile(ctx context.Context, db *db.Database, mirrorID, name string, sizeBytes int64) (*File, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+	f, err := AddFileTx(tx, mirrorID, name, sizeBytes)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return f, nil
+}
+
+// AddFileTx records a new file in a mirror link with a given TX, but does NOT commit it.
+func AddFileTx(tx *sql.Tx, mirrorID, name string, sizeBytes int64) (*File, error) {
+	// Lock the mirror link so files added at the same time can't get the same name
+	var id uuid.UUID
+	err := tx.QueryRow(`SELECT id FROM mirroring_links WHERE id=($1) FOR UPDATE;`, mirrorID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("lock error: %w", err)
+	}
+
+	rows, err := tx.Query(`SELECT name FROM files WHERE mirror_link_id=($1);`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	taken := map[string]bool{}
+	for rows.Next() {
+		var n string
+		if err = rows.Scan(&n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		taken[strings.ToLower(n)] = true
+	}
+	rows.Close()
+
+	f := &File{
+		ID:         uuid.New(),
+		Name:       UniqueName(CleanName(name), taken),
+		SizeBytes:  sizeBytes,
+		UploadDate: time.Now().UTC(),
+	}
+	_, err = tx.Exec(`
+		INSERT INTO files (id, name, size_bytes, upload_date, mirror_link_id)
+		VALUES
+		(($1), ($2), ($3), ($4), ($5));
+	`, f.ID, f.Name, f.SizeBytes, f.UploadDate, id)
+	if err != nil {
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	return f, nil
+}
+
+// SetFileSize updates the size of a file once it is known, e.g. after a presigned upload finished
+func SetFileSize(ctx context.Context, db *db.Database, fileID uuid.UUID, sizeBytes int64) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	if _, err := db.PostgresConn.ExecContext(ctx, `UPDATE files SET size_bytes=($1) WHERE id=($2);`, sizeBytes, fileID); err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
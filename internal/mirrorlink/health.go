@@ -0,0 +1,164 @@
+package mirrorlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+)
+
+// Levels of a link
+const (
+	LinkFolder = "folder" // Folder with all the files of a mirror link on a host
+	LinkFile   = "file"   // Single file, or part of a file, on a host
+)
+
+// Link is a link to a mirror link's files on a host
+type Link struct {
+	MirrorID uuid.UUID
+	Host     string
+	Level    string
+	URL      string
+}
+
+// LinkCheck is the result of probing a link, see hosts.Health
+type LinkCheck struct {
+	Host      string    `json:"host"`
+	Level     string    `json:"level"`
+	URL       string    `json:"url"`
+	Status    string    `json:"status"`
+	Detail    string    `json:"detail,omitempty"` // Why the host could not tell, for unknown links
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// linksQuery selects the links every mirror link currently has: its folders in `host_links`,
+// its files in `host_files` and the uploads of its encrypted files
+const linksQuery = `
+	SELECT mirror_id, 'bunkr' AS host, 'folder' AS level, bunkr AS url FROM host_links WHERE bunkr IS NOT NULL
+	UNION ALL SELECT mirror_id, 'gofile', 'folder', gofile FROM host_links WHERE gofile IS NOT NULL
+	UNION ALL SELECT mirror_id, 'pixeldrain', 'folder', pixeldrain FROM host_links WHERE pixeldrain IS NOT NULL
+	UNION ALL SELECT mirror_id, 'cyberfile', 'folder', cyberfile FROM host_links WHERE cyberfile IS NOT NULL
+	UNION ALL SELECT mirror_id, 'saint_to', 'folder', saint_to FROM host_links WHERE saint_to IS NOT NULL
+	UNION ALL SELECT mirror_id, 'cyberdrop', 'folder', cyberdrop FROM host_links WHERE cyberdrop IS NOT NULL
+	UNION ALL
+	SELECT files.mirror_link_id, host_files.host, 'file', host_files.url
+	FROM host_files
+	JOIN files ON files.id = host_files.file_id
+	WHERE files.mirror_link_id IS NOT NULL AND host_files.url <> ''
+	UNION ALL SELECT mirror_id, host, 'file', json_array_elements_text(locations::json) FROM sealed_files
+`
+
+// GetDueLinks returns up to limit links that were not checked since a given time, the ones checked the longest ago first
+func GetDueLinks(ctx context.Context, db *db.Database, since time.Time, limit int) ([]Link, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		WITH links AS (`+linksQuery+`),
+		last_checks AS (SELECT url, MAX(checked_at) AS checked_at FROM link_checks GROUP BY url)
+		SELECT links.mirror_id, links.host, links.level, links.url
+		FROM links
+		LEFT JOIN last_checks ON last_checks.url = links.url
+		WHERE links.url <> '' AND (last_checks.checked_at IS NULL OR last_checks.checked_at < ($1))
+		ORDER BY last_checks.checked_at NULLS FIRST
+		LIMIT ($2);
+	`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	links := []Link{}
+	for rows.Next() {
+		var l Link
+		if err := rows.Scan(&l.MirrorID, &l.Host, &l.Level, &l.URL); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		links = append(links, l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return links, nil
+}
+
+// AddLinkCheck records the result of probing a link
+func AddLinkCheck(ctx context.Context, db *db.Database, mirrorID uuid.UUID, c LinkCheck) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	_, err := db.PostgresConn.ExecContext(ctx, `
+		INSERT INTO link_checks (id, mirror_id, host, level, url, status, detail, checked_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($7), ($8));
+	`, uuid.New(), mirrorID, c.Host, c.Level, c.URL, c.Status, c.Detail, c.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetLinkHealth returns the latest check of each link a mirror link currently has.
+// Links that were not checked yet are left out.
+func GetLinkHealth(ctx context.Context, db *db.Database, mirrorID string) ([]LinkCheck, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	return queryLinkChecks(ctx, db, `
+		WITH links AS (`+linksQuery+`)
+		SELECT DISTINCT ON (link_checks.url) link_checks.host, link_checks.level, link_checks.url, link_checks.status, link_checks.detail, link_checks.checked_at
+		FROM link_checks
+		JOIN links ON links.mirror_id = link_checks.mirror_id AND links.url = link_checks.url
+		WHERE link_checks.mirror_id=($1)
+		ORDER BY link_checks.url, link_checks.checked_at DESC;
+	`, mirrorID)
+}
+
+// GetLinkHistory returns every check of the links of a mirror link, including links it no longer has, newest first
+func GetLinkHistory(ctx context.Context, db *db.Database, mirrorID string) ([]LinkCheck, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	return queryLinkChecks(ctx, db, `
+		SELECT host, level, url, status, detail, checked_at
+		FROM link_checks
+		WHERE mirror_id=($1)
+		ORDER BY checked_at DESC;
+	`, mirrorID)
+}
+
+// PruneLinkChecks deletes the checks that are older than a given time and returns how many were deleted
+func PruneLinkChecks(ctx context.Context, db *db.Database, before time.Time) (int64, error) {
+	if db == nil {
+		return 0, errors.New("database is nil")
+	}
+	res, err := db.PostgresConn.ExecContext(ctx, `DELETE FROM link_checks WHERE checked_at < ($1);`, before)
+	if err != nil {
+		return 0, fmt.Errorf("exec error: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// queryLinkChecks runs a query that selects link checks
+func queryLinkChecks(ctx context.Context, db *db.Database, query string, args ...any) ([]LinkCheck, error) {
+	rows, err := db.PostgresConn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	checks := []LinkCheck{}
+	for rows.Next() {
+		var c LinkCheck
+		if err := rows.Scan(&c.Host, &c.Level, &c.URL, &c.Status, &c.Detail, &c.CheckedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return checks, nil
+}
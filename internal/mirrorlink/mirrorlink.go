@@ -17,18 +17,36 @@ const (
 	query_limit = 25
 )
 
+var ErrNotFound = errors.New("mirror not found")
+
 // MirrorLink represents a mirror link, but with many details omitted.
 type MirrorLink struct {
-	ID         uuid.UUID `json:"id"`
-	Nickname   string    `json:"name"`
-	UploadDate time.Time `json:"upload_date"`
-	DurationMS int64     `json:"duration"`
+	ID          uuid.UUID  `json:"id"`
+	Nickname    string     `json:"name"`
+	UploadDate  time.Time  `json:"upload_date"`
+	DurationMS  int64      `json:"duration"`
+	WorkspaceID *uuid.UUID `json:"workspace_id"`
+}
+
+// Ownership describes who a mirror link belongs to
+type Ownership struct {
+	CreatedByID uuid.UUID  // User that created the mirror link
+	WorkspaceID *uuid.UUID // Workspace the mirror link belongs to, if any
 }
 
 type ShareLink struct {
-	MirrorLink           // Embed everything from the `MirrorLink`
-	Links      HostLinks `json:"links"`
-	Status     string    `json:"status"`
+	MirrorLink             // Embed everything from the `MirrorLink`
+	Links      HostLinks   `json:"links"`
+	Status     string      `json:"status"`      // "verified" or "corrupt" once the files were checked, see FileCheck
+	Checks     []FileCheck `json:"checks"`      // Results of comparing the uploaded files with their originals
+	Health     []LinkCheck `json:"health"`      // Latest probe of each link, dead links still show up in Links
+	SplitFiles []SplitFile `json:"split_files"` // Files that are in parts on some hosts
+	Archives   []Archive   `json:"archives"`    // Archives of all the files, on hosts that have one
+	Retention  *Retention  `json:"retention"`   // What becomes of the staged files, nil if the mirror was never uploaded to a host
+
+	// Encrypted mirrors hold the files encrypted, the key is in the fragment of the share URL
+	Encrypted   bool         `json:"encrypted"`
+	SealedFiles []SealedFile `json:"sealed_files"`
 }
 
 type HostLinks struct {
@@ -51,8 +69,9 @@ func GetUserLinks(ctx context.Context, db *db.Database, userID string, pageNum i
 	if err != nil {
 		return nil, fmt.Errorf("BeginTx error: %w", err)
 	}
+	defer tx.Rollback()
 	rows, err := tx.Query(`
-		SELECT "id","nickname", "upload_date", "duration_ms" from mirroring_links
+		SELECT "id","nickname", "upload_date", "duration_ms", "workspace_id" from mirroring_links
 		WHERE created_by_id=($1)
 		LIMIT ($2)
 		OFFSET ($3)
@@ -60,8 +79,29 @@ func GetUserLinks(ctx context.Context, db *db.Database, userID string, pageNum i
 	if err != nil {
 		return nil, fmt.Errorf("query error: %w", err)
 	}
+	return scanLinks(rows), nil
+}
 
-	// Parse links
+// GetWorkspaceLinks returns a list of items that belong to a workspace, newest first
+func GetWorkspaceLinks(ctx context.Context, db *db.Database, workspaceID uuid.UUID, pageNum int) ([]MirrorLink, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT "id","nickname", "upload_date", "duration_ms", "workspace_id" from mirroring_links
+		WHERE workspace_id=($1)
+		ORDER BY upload_date DESC
+		LIMIT ($2)
+		OFFSET ($3)
+	`, workspaceID, query_limit, common.GetPageOffset(query_limit, pageNum))
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	return scanLinks(rows), nil
+}
+
+// scanLinks parses rows of mirror links and closes them
+func scanLinks(rows *sql.Rows) []MirrorLink {
 	links := []MirrorLink{}
 	defer rows.Close()
 	for rows.Next() {
@@ -72,7 +112,8 @@ func GetUserLinks(ctx context.Context, db *db.Database, userID string, pageNum i
 		var tempName sql.NullString
 		var tempDate sql.NullTime
 		var tempDuration sql.NullInt64
-		if err := rows.Scan(&link.ID, &tempName, &tempDate, &tempDuration); err != nil {
+		var tempWorkspace uuid.NullUUID
+		if err := rows.Scan(&link.ID, &tempName, &tempDate, &tempDuration, &tempWorkspace); err != nil {
 			log.Println("Error scanning row:", err)
 			continue
 		}
@@ -81,15 +122,59 @@ func GetUserLinks(ctx context.Context, db *db.Database, userID string, pageNum i
 		link.UploadDate = tempDate.Time
 		link.DurationMS = tempDuration.Int64
 		link.Nickname = tempName.String
+		if tempWorkspace.Valid {
+			link.WorkspaceID = &tempWorkspace.UUID
+		}
 		links = append(links, link)
 	}
+	return links
+}
 
-	// Return
-	return links, nil
+// GetOwnership returns who a given mirror link belongs to
+func GetOwnership(ctx context.Context, db *db.Database, mirrorID string) (*Ownership, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	id, err := uuid.Parse(mirrorID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	o := &Ownership{}
+	var workspace uuid.NullUUID
+	err = db.PostgresConn.QueryRowContext(ctx, `
+		SELECT created_by_id, workspace_id FROM mirroring_links WHERE id=($1);
+	`, id).Scan(&o.CreatedByID, &workspace)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	if workspace.Valid {
+		o.WorkspaceID = &workspace.UUID
+	}
+	return o, nil
+}
+
+// SetWorkspace moves a mirror link into a workspace, or back to its creator if workspaceID is nil
+func SetWorkspace(ctx context.Context, db *db.Database, mirrorID string, workspaceID *uuid.UUID) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	res, err := db.PostgresConn.ExecContext(ctx, `UPDATE mirroring_links SET workspace_id=($1) WHERE id=($2);`, workspaceID, mirrorID)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-// UpdateName update's the name of a given mirror link
-func UpdateName(ctx context.Context, db *db.Database, mirrorID, userID, newName string) error {
+// UpdateName update's the name of a given mirror link.
+// Callers must check that the user is allowed to edit the mirror link.
+func UpdateName(ctx context.Context, db *db.Database, mirrorID, newName string) error {
 	if db == nil {
 		return errors.New("database is nil")
 	}
@@ -102,9 +187,8 @@ func UpdateName(ctx context.Context, db *db.Database, mirrorID, userID, newName
 	_, err = tx.Exec(`
 		UPDATE mirroring_links
 		SET nickname = ($1)
-		WHERE id = ($2)
-		AND created_by_id = ($3);	
-	`, newName, mirrorID, userID)
+		WHERE id = ($2);
+	`, newName, mirrorID)
 
 	if err != nil {
 		tx.Rollback()
@@ -117,8 +201,9 @@ func UpdateName(ctx context.Context, db *db.Database, mirrorID, userID, newName
 	return nil
 }
 
-// Delete delete's a given mirror link
-func Delete(ctx context.Context, db *db.Database, userID, mirrorID string) error {
+// Delete delete's a given mirror link along with its host links.
+// Callers must check that the user is allowed to delete the mirror link.
+func Delete(ctx context.Context, db *db.Database, mirrorID string) error {
 	if db == nil {
 		return errors.New("database is nil")
 	}
@@ -130,17 +215,19 @@ func Delete(ctx context.Context, db *db.Database, userID, mirrorID string) error
 	}
 
 	// Delete Link
-	statement := `
-		DELETE FROM mirroring_links
-		WHERE id = ($1)
-		AND
-		created_by_id = ($2);
-	`
-	_, err = tx.Exec(statement, mirrorID, userID)
+	if _, err = tx.Exec(`DELETE FROM host_links WHERE mirror_id=($1);`, mirrorID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error executing tx: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM mirroring_links WHERE id=($1);`, mirrorID)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("error executing tx: %w", err)
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return ErrNotFound
+	}
 	if err = tx.Commit(); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("error comitting tx: %w", err)
@@ -199,6 +286,28 @@ func GetMirror(ctx context.Context, db *db.Database, mirrorID string) (*ShareLin
 	sl.Nickname = tmpName.String
 	sl.UploadDate = tmpDate.Time
 
+	// Show how to put back together the files that were split
+	if sl.SplitFiles, err = GetSplitFiles(ctx, db, mirrorID); err != nil {
+		return nil, fmt.Errorf("GetSplitFiles error: %w", err)
+	}
+	if sl.Archives, err = GetArchives(ctx, db, mirrorID); err != nil {
+		return nil, fmt.Errorf("GetArchives error: %w", err)
+	}
+	if sl.SealedFiles, err = GetSealedFiles(ctx, db, mirrorID); err != nil {
+		return nil, fmt.Errorf("GetSealedFiles error: %w", err)
+	}
+	sl.Encrypted = len(sl.SealedFiles) > 0
+	if sl.Checks, err = GetChecks(ctx, db, mirrorID); err != nil {
+		return nil, fmt.Errorf("GetChecks error: %w", err)
+	}
+	sl.Status = checkStatus(sl.Checks)
+	if sl.Health, err = GetLinkHealth(ctx, db, mirrorID); err != nil {
+		return nil, fmt.Errorf("GetLinkHealth error: %w", err)
+	}
+	if sl.Retention, err = GetRetention(ctx, db, mirrorID); err != nil && !errors.Is(err, ErrNoRetention) {
+		return nil, fmt.Errorf("GetRetention error: %w", err)
+	}
+
 	// Return
 	return sl, nil
 }
@@ -0,0 +1,83 @@
+package mirrorlink
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxNameBytes = 255    // Most file systems and hosts don't allow longer names
+	fallbackName = "file" // Used when nothing is left of a name after cleaning it
+)
+
+// CleanName turns a user supplied file name into a safe display name.
+// Directories are dropped, the name is normalized to NFC and
+// control characters or characters that are not allowed on common file systems are replaced.
+func CleanName(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = norm.NFC.String(name)
+
+	// Only keep the last path element, for both kinds of separators
+	name = strings.ReplaceAll(name, `\`, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" || strings.Trim(name, ".") == "" {
+		return fallbackName
+	}
+	return truncateName(name, maxNameBytes)
+}
+
+// UniqueName returns a name that is not in taken by adding a counter before the extension, e.g. "photo (1).png".
+// Names are compared case insensitively since many hosts and file systems do.
+func UniqueName(name string, taken map[string]bool) string {
+	if !taken[strings.ToLower(name)] {
+		return name
+	}
+	ext := path.Ext(name)
+	if ext == name {
+		ext = "" // Dot files like ".env" have no extension
+	}
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		suffix := fmt.Sprintf(" (%d)%s", i, ext)
+		candidate := truncateName(base, maxNameBytes-len(suffix)) + suffix
+		if !taken[strings.ToLower(candidate)] {
+			return candidate
+		}
+	}
+}
+
+// truncateName shortens a name to at most max bytes without splitting characters.
+// The extension is kept if it is short enough.
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	ext := path.Ext(name)
+	if ext == name || len(ext) > max/2 {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	limit := max - len(ext)
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+	return base[:limit] + ext
+}
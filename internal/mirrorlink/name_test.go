@@ -0,0 +1,68 @@
+package mirrorlink
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestCleanName$ github.com/easymirror/easymirror-backend/internal/mirrorlink
+func TestCleanName(t *testing.T) {
+	tests := []struct {
+		Input, Expected string
+	}{
+		{Input: "photo.png", Expected: "photo.png"},
+		{Input: "../../etc/passwd", Expected: "passwd"},
+		{Input: "/var/data/photo.png", Expected: "photo.png"},
+		{Input: `C:\Users\me\photo.png`, Expected: "photo.png"},
+		{Input: "..", Expected: fallbackName},
+		{Input: "   ", Expected: fallbackName},
+		{Input: "folder/", Expected: fallbackName},
+		{Input: "pho\x00to\n.png", Expected: "pho_to_.png"},           // Control characters
+		{Input: `a<b>c:d"e|f?g*.txt`, Expected: "a_b_c_d_e_f_g_.txt"}, // Reserved characters
+		{Input: "report. . ", Expected: "report"},                     // Trailing dots and spaces
+		{Input: "my\u00a0photo.png", Expected: "my photo.png"},        // Unicode spaces
+		{Input: "cafe\u0301.txt", Expected: "caf\u00e9.txt"},          // NFD to NFC
+		{Input: "bad\xffname.txt", Expected: "bad_name.txt"},          // Invalid UTF-8
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := CleanName(test.Input)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+
+	// Long names keep their extension
+	name := CleanName(strings.Repeat("é", 300) + ".png")
+	assert.LessOrEqual(t, len(name), maxNameBytes)
+	assert.True(t, strings.HasSuffix(name, "é.png"))
+}
+
+// go test -v -timeout 30s -run ^TestUniqueName$ github.com/easymirror/easymirror-backend/internal/mirrorlink
+func TestUniqueName(t *testing.T) {
+	taken := map[string]bool{
+		"photo.png":     true,
+		"photo (1).png": true,
+		"readme":        true,
+		".env":          true,
+	}
+	tests := []struct {
+		Input, Expected string
+	}{
+		{Input: "other.png", Expected: "other.png"},
+		{Input: "photo.png", Expected: "photo (2).png"},
+		{Input: "PHOTO.png", Expected: "PHOTO (2).png"},
+		{Input: "readme", Expected: "readme (1)"},
+		{Input: ".env", Expected: ".env (1)"},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := UniqueName(test.Input, taken)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package mirrorlink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/google/uuid"
+)
+
+// FilePart is a part of a file that was split to fit on a host
+type FilePart struct {
+	Index     int    `json:"index"` // Starts at 1
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size"`
+}
+
+// SplitFile is a file that was uploaded to a host in parts
+type SplitFile struct {
+	FileID       uuid.UUID  `json:"file_id"`
+	Name         string     `json:"name"`         // Name of the whole file
+	Host         string     `json:"host"`         // Host the parts are on
+	Mode         string     `json:"mode"`         // How the file was split, see hosts.SplitMode
+	Parts        []FilePart `json:"parts"`        // In order
+	Instructions string     `json:"instructions"` // How to put the file back together
+}
+
+// AddPartsTx records the parts a file was split into on a host with a given TX, but does NOT commit it.
+// Parts recorded for the file on the host before, e.g. by an earlier mirror, are replaced.
+func AddPartsTx(tx *sql.Tx, fileID uuid.UUID, host string, mode hosts.SplitMode, parts []hosts.Part) error {
+	if _, err := tx.Exec(`DELETE FROM file_parts WHERE file_id=($1) AND host=($2);`, fileID, host); err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	for _, p := range parts {
+		_, err := tx.Exec(`
+			INSERT INTO file_parts (file_id, host, part, mode, name, size_bytes)
+			VALUES
+			(($1), ($2), ($3), ($4), ($5), ($6));
+		`, fileID, host, p.Index, string(mode), p.Name, p.Size)
+		if err != nil {
+			return fmt.Errorf("exec error: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetSplitFiles returns the files of a mirror link that were split to fit on a host, with how to put them back together
+func GetSplitFiles(ctx context.Context, db *db.Database, mirrorID string) ([]SplitFile, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT files.id, files.name, file_parts.host, file_parts.mode, file_parts.part, file_parts.name, file_parts.size_bytes
+		FROM file_parts
+		JOIN files ON files.id = file_parts.file_id
+		WHERE files.mirror_link_id=($1)
+		ORDER BY files.name, files.id, file_parts.host, file_parts.part;
+	`, mirrorID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	files := []SplitFile{}
+	for rows.Next() {
+		var (
+			f SplitFile
+			p FilePart
+		)
+		if err := rows.Scan(&f.FileID, &f.Name, &f.Host, &f.Mode, &p.Index, &p.Name, &p.SizeBytes); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if n := len(files); n > 0 && files[n-1].FileID == f.FileID && files[n-1].Host == f.Host {
+			files[n-1].Parts = append(files[n-1].Parts, p)
+			continue
+		}
+		f.Parts = []FilePart{p}
+		files = append(files, f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	for i, f := range files {
+		names := make([]string, len(f.Parts))
+		for j, p := range f.Parts {
+			names[j] = p.Name
+		}
+		files[i].Instructions = hosts.Reassembly(hosts.SplitMode(f.Mode), f.Name, names)
+	}
+	return files, nil
+}
@@ -0,0 +1,204 @@
+package mirrorlink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+// RetentionPolicy is how long the staged files of a mirror link are kept in the bucket once they were uploaded to the hosts.
+// As long as they are kept, the mirror link can be uploaded to the hosts again, e.g. to retry a host or add another one.
+type RetentionPolicy string
+
+const (
+	RetainNone         RetentionPolicy = "immediate"     // Deleted as soon as the upload finished, whether it worked or not
+	RetainDays         RetentionPolicy = "days"          // Kept for a number of days after the upload finished
+	RetainUntilSuccess RetentionPolicy = "until_success" // Kept until every host took the files, at most the max retention
+)
+
+// DefaultRetention is the retention of mirror links that were never given one
+var DefaultRetention = Retention{Policy: RetainUntilSuccess}
+
+// Statuses of the staged files of a mirror link
+const (
+	StagingUploading = "uploading" // Being uploaded to the hosts, they are kept until that finished
+	StagingRetained  = "retained"  // Kept until ExpiresAt
+	StagingDeleted   = "deleted"   // Deleted from the bucket
+)
+
+var (
+	ErrInvalidRetention = errors.New("invalid retention")
+	ErrNoRetention      = errors.New("no retention recorded")
+)
+
+// Retention is the retention of the staged files of a mirror link, and what became of them
+type Retention struct {
+	Policy    RetentionPolicy `json:"policy"`
+	Days      int             `json:"days,omitempty"` // For RetainDays
+	Status    string          `json:"status"`
+	ExpiresAt *time.Time      `json:"expires_at"` // When the sweeper deletes the files, nil while they are uploaded
+	DeletedAt *time.Time      `json:"deleted_at"`
+}
+
+// ParseRetention converts the name of a retention policy and its days, which can't be more than maxRetention.
+// An empty name returns an empty Retention, which keeps the retention a mirror link already has.
+func ParseRetention(policy string, days int, maxRetention time.Duration) (Retention, error) {
+	r := Retention{Policy: RetentionPolicy(policy), Days: days}
+	switch r.Policy {
+	case "", RetainNone, RetainUntilSuccess:
+		if days != 0 {
+			return Retention{}, fmt.Errorf("%w: days are only kept with %q", ErrInvalidRetention, RetainDays)
+		}
+		return r, nil
+	case RetainDays:
+		if days < 1 || time.Duration(days)*24*time.Hour > maxRetention {
+			return Retention{}, fmt.Errorf("%w: %v days, want 1 to %v", ErrInvalidRetention, days, int(maxRetention/(24*time.Hour)))
+		}
+		return r, nil
+	}
+	return Retention{}, fmt.Errorf("%w: %q", ErrInvalidRetention, policy)
+}
+
+// Expiry returns when the staged files expire once an upload finished at a given time.
+// succeeded tells whether every host took the files, and maxRetention caps how long they are kept.
+func (r Retention) Expiry(finished time.Time, succeeded bool, maxRetention time.Duration) time.Time {
+	switch r.Policy {
+	case RetainNone:
+		return finished
+	case RetainDays:
+		return finished.Add(min(time.Duration(r.Days)*24*time.Hour, maxRetention))
+	}
+	if succeeded {
+		return finished
+	}
+	return finished.Add(maxRetention)
+}
+
+// StartStaging records that the staged files of a mirror link are being uploaded with a given retention.
+// They are kept until every upload that started finished, see FinishStaging.
+func StartStaging(ctx context.Context, db *db.Database, mirrorID string, r Retention) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	_, err := db.PostgresConn.ExecContext(ctx, `
+		INSERT INTO staging_retention (mirror_id, policy, days, uploads, expires_at, deleted_at, updated_at)
+		VALUES (($1), ($2), ($3), 1, NULL, NULL, ($4))
+		ON CONFLICT (mirror_id)
+		DO UPDATE
+		SET policy = EXCLUDED.policy, days = EXCLUDED.days, uploads = staging_retention.uploads + 1, expires_at = NULL, deleted_at = NULL, updated_at = EXCLUDED.updated_at;
+	`, mirrorID, r.Policy, r.Days, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// FinishStaging records that an upload of the staged files of a mirror link finished.
+// It returns the retention of the files and how many uploads of them are still running.
+func FinishStaging(ctx context.Context, db *db.Database, mirrorID string) (Retention, int, error) {
+	if db == nil {
+		return Retention{}, 0, errors.New("database is nil")
+	}
+	var r Retention
+	var uploads int
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		UPDATE staging_retention
+		SET uploads = GREATEST(uploads - 1, 0), updated_at = ($2)
+		WHERE mirror_id=($1)
+		RETURNING policy, days, uploads;
+	`, mirrorID, time.Now().UTC()).Scan(&r.Policy, &r.Days, &uploads)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Retention{}, 0, ErrNoRetention
+	} else if err != nil {
+		return Retention{}, 0, fmt.Errorf("scan error: %w", err)
+	}
+	return r, uploads, nil
+}
+
+// SetStagingExpiry sets when the sweeper deletes the staged files of a mirror link
+func SetStagingExpiry(ctx context.Context, db *db.Database, mirrorID string, expiresAt time.Time) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	_, err := db.PostgresConn.ExecContext(ctx, `UPDATE staging_retention SET expires_at=($2) WHERE mirror_id=($1);`, mirrorID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// MarkStagingDeleted records that the staged files of a mirror link were deleted from the bucket
+func MarkStagingDeleted(ctx context.Context, db *db.Database, mirrorID string, deletedAt time.Time) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	_, err := db.PostgresConn.ExecContext(ctx, `UPDATE staging_retention SET uploads=0, deleted_at=($2) WHERE mirror_id=($1);`, mirrorID, deletedAt)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetRetention returns the retention of the staged files of a mirror link, or ErrNoRetention if it was never mirrored
+func GetRetention(ctx context.Context, db *db.Database, mirrorID string) (*Retention, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	r := &Retention{}
+	var expiresAt, deletedAt sql.NullTime
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT policy, days, expires_at, deleted_at
+		FROM staging_retention
+		WHERE mirror_id=($1);
+	`, mirrorID).Scan(&r.Policy, &r.Days, &expiresAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRetention
+	} else if err != nil {
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+
+	r.Status = StagingUploading
+	if expiresAt.Valid {
+		r.Status, r.ExpiresAt = StagingRetained, &expiresAt.Time
+	}
+	if deletedAt.Valid {
+		r.Status, r.DeletedAt = StagingDeleted, &deletedAt.Time
+	}
+	return r, nil
+}
+
+// GetExpiredStaging returns the IDs of up to limit mirror links whose staged files expired before a given time and were not deleted yet.
+// Files that are still marked as uploading since before stale, e.g. because the server stopped during the upload, expired too.
+func GetExpiredStaging(ctx context.Context, db *db.Database, before, stale time.Time, limit int) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT mirror_id
+		FROM staging_retention
+		WHERE deleted_at IS NULL AND (expires_at <= ($1) OR (expires_at IS NULL AND updated_at <= ($2)))
+		ORDER BY expires_at
+		LIMIT ($3);
+	`, before, stale, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return ids, nil
+}
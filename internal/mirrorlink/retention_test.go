@@ -0,0 +1,69 @@
+package mirrorlink
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestParseRetention$ github.com/easymirror/easymirror-backend/internal/mirrorlink
+func TestParseRetention(t *testing.T) {
+	maxRetention := 30 * (24 * time.Hour)
+	tests := []struct {
+		Policy   string
+		Days     int
+		Expected Retention
+		Err      error
+	}{
+		{Expected: Retention{}}, // Keeps the mirror's
+		{Policy: "immediate", Expected: Retention{Policy: RetainNone}},
+		{Policy: "until_success", Expected: Retention{Policy: RetainUntilSuccess}},
+		{Policy: "days", Days: 7, Expected: Retention{Policy: RetainDays, Days: 7}},
+		{Policy: "days", Days: 30, Expected: Retention{Policy: RetainDays, Days: 30}},
+		{Policy: "days", Days: 31, Err: ErrInvalidRetention},
+		{Policy: "days", Err: ErrInvalidRetention},
+		{Policy: "immediate", Days: 3, Err: ErrInvalidRetention},
+		{Policy: "forever", Err: ErrInvalidRetention},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result, err := ParseRetention(test.Policy, test.Days, maxRetention)
+			if test.Err != nil {
+				assert.ErrorIs(t, err, test.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestRetentionExpiry$ github.com/easymirror/easymirror-backend/internal/mirrorlink
+func TestRetentionExpiry(t *testing.T) {
+	day := 24 * time.Hour
+	maxRetention := 30 * day
+	finished := time.Date(2024, 3, 27, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Retention Retention
+		Succeeded bool
+		Expected  time.Time
+	}{
+		{Retention: Retention{Policy: RetainNone}, Succeeded: true, Expected: finished},
+		{Retention: Retention{Policy: RetainNone}, Expected: finished},
+		{Retention: Retention{Policy: RetainDays, Days: 7}, Succeeded: true, Expected: finished.Add(7 * day)},
+		{Retention: Retention{Policy: RetainDays, Days: 7}, Expected: finished.Add(7 * day)},
+		{Retention: Retention{Policy: RetainDays, Days: 90}, Expected: finished.Add(maxRetention)},
+		{Retention: Retention{Policy: RetainUntilSuccess}, Succeeded: true, Expected: finished},
+		{Retention: Retention{Policy: RetainUntilSuccess}, Expected: finished.Add(maxRetention)},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := test.Retention.Expiry(finished, test.Succeeded, maxRetention)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
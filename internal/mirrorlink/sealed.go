@@ -0,0 +1,125 @@
+package mirrorlink
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+var ErrFileNotFound = errors.New("file not found")
+
+// SealedFile is a file that was encrypted before it was uploaded to a host.
+// It holds everything needed to decrypt the file but the key, which is never stored.
+type SealedFile struct {
+	Host        string    `json:"host"`
+	Name        string    `json:"name"`       // Random name of the encrypted file on the host
+	PlainName   string    `json:"plain_name"` // Name of the file once it is decrypted
+	PlainSize   int64     `json:"plain_size"`
+	SizeBytes   int64     `json:"size"`         // Size of the encrypted file
+	ChunkSize   int       `json:"chunk_size"`   // Bytes of plaintext per chunk, see package seal
+	NoncePrefix []byte    `json:"nonce_prefix"` // Random per file
+	Locations   []string  `json:"locations"`    // URLs of the encrypted file on the host, in order if it is in parts
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AddSealedFileTx records an encrypted file uploaded to a host with a given TX, but does NOT commit it.
+func AddSealedFileTx(tx *sql.Tx, mirrorID string, f SealedFile) error {
+	locations, err := json.Marshal(f.Locations)
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO sealed_files (mirror_id, host, name, plain_name, plain_size, size_bytes, chunk_size, nonce_prefix, locations, created_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($7), ($8), ($9), ($10))
+		ON CONFLICT (mirror_id, host, name)
+		DO UPDATE
+		SET locations = EXCLUDED.locations, created_at = EXCLUDED.created_at;
+	`, mirrorID, f.Host, f.Name, f.PlainName, f.PlainSize, f.SizeBytes, f.ChunkSize, f.NoncePrefix, string(locations), f.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetSealedFiles returns the encrypted files of a mirror link
+func GetSealedFiles(ctx context.Context, db *db.Database, mirrorID string) ([]SealedFile, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT host, name, plain_name, plain_size, size_bytes, chunk_size, nonce_prefix, locations, created_at
+		FROM sealed_files
+		WHERE mirror_id=($1)
+		ORDER BY plain_name, host;
+	`, mirrorID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	files := []SealedFile{}
+	for rows.Next() {
+		f, err := scanSealedFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return files, nil
+}
+
+// GetSealedFile returns an encrypted file of a mirror link by its name on a host, or ErrFileNotFound
+func GetSealedFile(ctx context.Context, db *db.Database, mirrorID, host, name string) (*SealedFile, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	row := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT host, name, plain_name, plain_size, size_bytes, chunk_size, nonce_prefix, locations, created_at
+		FROM sealed_files
+		WHERE mirror_id=($1) AND host=($2) AND name=($3);
+	`, mirrorID, host, name)
+	f, err := scanSealedFile(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFileNotFound
+	}
+	return f, err
+}
+
+// IsEncrypted returns whether any file of a mirror link was encrypted
+func IsEncrypted(ctx context.Context, db *db.Database, mirrorID string) (bool, error) {
+	if db == nil {
+		return false, errors.New("database is nil")
+	}
+	var encrypted bool
+	err := db.PostgresConn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sealed_files WHERE mirror_id=($1));`, mirrorID).Scan(&encrypted)
+	if err != nil {
+		return false, fmt.Errorf("query error: %w", err)
+	}
+	return encrypted, nil
+}
+
+// scanSealedFile parses a row of the `sealed_files` table
+func scanSealedFile(row interface{ Scan(...any) error }) (*SealedFile, error) {
+	var (
+		f         SealedFile
+		locations string
+	)
+	err := row.Scan(&f.Host, &f.Name, &f.PlainName, &f.PlainSize, &f.SizeBytes, &f.ChunkSize, &f.NoncePrefix, &locations, &f.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+	if err = json.Unmarshal([]byte(locations), &f.Locations); err != nil {
+		return nil, fmt.Errorf("unmarshal error: %w", err)
+	}
+	return &f, nil
+}
@@ -0,0 +1,66 @@
+// Package ratelimit provides a simple in-memory, keyed rate limiter.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to a fixed number of events per key within a sliding window
+type Limiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+// New returns a limiter that allows `limit` events per key every `window`
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+		events: map[string][]time.Time{},
+	}
+}
+
+// Allow records an event for a key and returns false if the key is over its limit.
+// Events that are denied are not recorded.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	recent := l.prune(key, now)
+	if len(recent) >= l.limit {
+		return false
+	}
+	l.events[key] = append(recent, now)
+
+	// Opportunistically clean up other keys so the map does not grow forever
+	if len(l.events) > 1024 {
+		for k := range l.events {
+			l.prune(k, now)
+		}
+	}
+	return true
+}
+
+// prune drops events that fell out of the window and returns what is left
+func (l *Limiter) prune(key string, now time.Time) []time.Time {
+	events := l.events[key]
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(events) && !events[i].After(cutoff) {
+		i++
+	}
+	events = events[i:]
+	if len(events) == 0 {
+		delete(l.events, key)
+		return nil
+	}
+	l.events[key] = events
+	return events
+}
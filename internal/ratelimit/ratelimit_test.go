@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestAllow$ github.com/easymirror/easymirror-backend/internal/ratelimit
+func TestAllow(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	// The first 3 events are allowed
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow("a@example.com"))
+	}
+	assert.False(t, l.Allow("a@example.com"))
+
+	// Other keys are unaffected
+	assert.True(t, l.Allow("b@example.com"))
+
+	// Once the window passes, events are allowed again
+	now = now.Add(59 * time.Second)
+	assert.False(t, l.Allow("a@example.com"))
+	now = now.Add(2 * time.Second)
+	assert.True(t, l.Allow("a@example.com"))
+}
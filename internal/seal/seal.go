@@ -0,0 +1,197 @@
+// Package seal encrypts files as a stream of AES-256-GCM chunks, so they can be encrypted and decrypted without holding them in memory.
+//
+// Each chunk of up to ChunkSize bytes is sealed on its own. Its nonce is the file's random prefix, the index of the chunk
+// and whether it is the last chunk, so chunks can't be reordered, dropped or cut off without decryption failing.
+// The key is never stored: it is handed to the user in the fragment of the share URL and sent back to download.
+package seal
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	KeySize          = 32       // AES-256
+	PrefixSize       = 7        // Random part of the nonces
+	DefaultChunkSize = 64 << 10 // 64 KiB of plaintext per chunk
+	Overhead         = 16       // GCM tag of each chunk
+)
+
+var (
+	ErrInvalidKey = errors.New("invalid key")
+	ErrDecrypt    = errors.New("wrong key or corrupted file")
+	ErrTruncated  = errors.New("encrypted file is cut off")
+)
+
+// Params are what is needed besides the key to decrypt a file. They are not secret.
+type Params struct {
+	ChunkSize   int    `json:"chunk_size"`   // Bytes of plaintext per chunk
+	NoncePrefix []byte `json:"nonce_prefix"` // Random per file
+}
+
+// NewKey returns a random key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("rand error: %w", err)
+	}
+	return key, nil
+}
+
+// EncodeKey encodes a key to put in a URL
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// ParseKey decodes a key encoded with EncodeKey
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// NewParams returns params with a random nonce prefix, to encrypt a new file with
+func NewParams() (Params, error) {
+	p := Params{ChunkSize: DefaultChunkSize, NoncePrefix: make([]byte, PrefixSize)}
+	if _, err := rand.Read(p.NoncePrefix); err != nil {
+		return p, fmt.Errorf("rand error: %w", err)
+	}
+	return p, nil
+}
+
+// SealedSize returns the size of a file of size bytes once it is encrypted
+func (p Params) SealedSize(size int64) int64 {
+	chunks := (size + int64(p.ChunkSize) - 1) / int64(p.ChunkSize)
+	return size + max(chunks, 1)*Overhead // Empty files are a single empty chunk
+}
+
+// PlainSize returns the size of a file once an encrypted file of size bytes is decrypted
+func (p Params) PlainSize(size int64) int64 {
+	chunks := (size + int64(p.ChunkSize+Overhead) - 1) / int64(p.ChunkSize+Overhead)
+	return size - max(chunks, 1)*Overhead
+}
+
+// aead returns the cipher for a key, after checking the params
+func (p Params) aead(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	if p.ChunkSize <= 0 || len(p.NoncePrefix) != PrefixSize {
+		return nil, errors.New("invalid params")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes error: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce returns the nonce of a chunk
+func (p Params) nonce(index uint32, last bool) []byte {
+	nonce := make([]byte, 0, PrefixSize+5)
+	nonce = append(nonce, p.NoncePrefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// Encrypt returns a reader that encrypts what it reads from r
+func Encrypt(key []byte, p Params, r io.Reader) io.Reader {
+	return newStream(key, p, r, p.ChunkSize, func(aead cipher.AEAD, dst, chunk, nonce []byte) ([]byte, error) {
+		return aead.Seal(dst, nonce, chunk, nil), nil
+	})
+}
+
+// Decrypt returns a reader that decrypts what it reads from r.
+// Reads fail with ErrDecrypt if the key is wrong or the file was changed, and with ErrTruncated if the file was cut off.
+func Decrypt(key []byte, p Params, r io.Reader) io.Reader {
+	return newStream(key, p, r, p.ChunkSize+Overhead, func(aead cipher.AEAD, dst, chunk, nonce []byte) ([]byte, error) {
+		out, err := aead.Open(dst, nonce, chunk, nil)
+		if err == nil {
+			return out, nil
+		}
+
+		// A last chunk that opens as a middle chunk means the chunks after it are missing
+		if last := len(nonce) - 1; nonce[last] == 1 {
+			nonce[last] = 0
+			if _, err = aead.Open(dst, nonce, chunk, nil); err == nil {
+				return nil, ErrTruncated
+			}
+		}
+		return nil, ErrDecrypt
+	})
+}
+
+// stream reads chunks of its source and passes each through a function
+type stream struct {
+	p       Params
+	aead    cipher.AEAD
+	src     *bufio.Reader
+	fn      func(aead cipher.AEAD, dst, chunk, nonce []byte) ([]byte, error)
+	index   uint32
+	in, out []byte
+	pending []byte // Output of the last chunk that was not read yet
+	done    bool
+	err     error
+}
+
+// newStream returns a stream that reads chunks of size bytes from r
+func newStream(key []byte, p Params, r io.Reader, size int, fn func(aead cipher.AEAD, dst, chunk, nonce []byte) ([]byte, error)) io.Reader {
+	s := &stream{p: p, fn: fn, src: bufio.NewReaderSize(r, size+1), in: make([]byte, size)}
+	s.aead, s.err = p.aead(key)
+	return s
+}
+
+func (s *stream) Read(b []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.err = s.next()
+	}
+	n := copy(b, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// next passes the next chunk through the function
+func (s *stream) next() error {
+	n, err := io.ReadFull(s.src, s.in)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	// The chunk is the last one if nothing follows it
+	_, peekErr := s.src.Peek(1)
+	last := peekErr == io.EOF
+	if peekErr != nil && !last {
+		return peekErr
+	}
+	if s.index == math.MaxUint32 && !last {
+		return errors.New("file too large to encrypt")
+	}
+
+	s.out, err = s.fn(s.aead, s.out[:0], s.in[:n], s.p.nonce(s.index, last))
+	if err != nil {
+		return err
+	}
+	s.pending = s.out
+	s.index++
+	s.done = last
+	return nil
+}
@@ -0,0 +1,84 @@
+package seal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -timeout 30s -run ^TestRoundTrip$ github.com/easymirror/easymirror-backend/internal/seal
+func TestRoundTrip(t *testing.T) {
+	key, err := NewKey()
+	require.NoError(t, err)
+	p, err := NewParams()
+	require.NoError(t, err)
+	p.ChunkSize = 16
+
+	tests := []struct {
+		Size int
+	}{
+		{Size: 0},
+		{Size: 5},  // Short chunk
+		{Size: 16}, // One full chunk
+		{Size: 48}, // Full chunks
+		{Size: 50}, // Short last chunk
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			plain := bytes.Repeat([]byte("x"), test.Size)
+			sealed, err := io.ReadAll(Encrypt(key, p, bytes.NewReader(plain)))
+			require.NoError(t, err)
+			assert.Equal(t, p.SealedSize(int64(test.Size)), int64(len(sealed)))
+			assert.Equal(t, int64(test.Size), p.PlainSize(int64(len(sealed))))
+
+			result, err := io.ReadAll(Decrypt(key, p, bytes.NewReader(sealed)))
+			require.NoError(t, err)
+			assert.Equal(t, plain, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestDecryptErrors$ github.com/easymirror/easymirror-backend/internal/seal
+func TestDecryptErrors(t *testing.T) {
+	key, _ := NewKey()
+	p, _ := NewParams()
+	p.ChunkSize = 16
+	sealed, err := io.ReadAll(Encrypt(key, p, bytes.NewReader(bytes.Repeat([]byte("x"), 50))))
+	require.NoError(t, err)
+
+	otherKey, _ := NewKey()
+	_, err = io.ReadAll(Decrypt(otherKey, p, bytes.NewReader(sealed)))
+	assert.ErrorIs(t, err, ErrDecrypt, "wrong key")
+
+	changed := bytes.Clone(sealed)
+	changed[20] ^= 1
+	_, err = io.ReadAll(Decrypt(key, p, bytes.NewReader(changed)))
+	assert.ErrorIs(t, err, ErrDecrypt, "changed byte")
+
+	_, err = io.ReadAll(Decrypt(key, p, bytes.NewReader(sealed[:2*(16+Overhead)])))
+	assert.ErrorIs(t, err, ErrTruncated, "cut off at a chunk")
+
+	_, err = io.ReadAll(Decrypt(key, p, bytes.NewReader(sealed[:40])))
+	assert.ErrorIs(t, err, ErrDecrypt, "cut off in a chunk")
+
+	_, err = io.ReadAll(Decrypt(key[:16], p, bytes.NewReader(sealed)))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+// go test -v -timeout 30s -run ^TestParseKey$ github.com/easymirror/easymirror-backend/internal/seal
+func TestParseKey(t *testing.T) {
+	key, _ := NewKey()
+	got, err := ParseKey(EncodeKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	for _, s := range []string{"", "not base64!", EncodeKey(key[:16])} {
+		_, err = ParseKey(s)
+		assert.ErrorIs(t, err, ErrInvalidKey, s)
+	}
+}
@@ -0,0 +1,46 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestDevice$ github.com/easymirror/easymirror-backend/internal/session
+func TestDevice(t *testing.T) {
+	tests := []struct {
+		UserAgent string
+		Expected  string
+	}{
+		{
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.36",
+			Expected:  "Chrome on Windows",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.36 Edg/122.0.2365.66",
+			Expected:  "Edge on Windows",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.3 Mobile/15E148 Safari/604.1",
+			Expected:  "Safari on iPhone",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:123.0) Gecko/20100101 Firefox/123.0",
+			Expected:  "Firefox on Linux",
+		},
+		{
+			UserAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Mobile Safari/537.36",
+			Expected:  "Chrome on Android",
+		},
+		{UserAgent: "curl/8.4.0", Expected: "curl"},
+		{UserAgent: "", Expected: "Unknown device"},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := Device(test.UserAgent)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
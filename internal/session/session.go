@@ -0,0 +1,181 @@
+// Package session keeps track of where a user is logged in.
+// Every refresh token belongs to a session, so revoking a session logs that device out.
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxIdle         = 90 * (24 * time.Hour) // Sessions that were not refreshed for this long expire, same as a refresh token
+	maxUserAgentLen = 512                   // User agents are client controlled, so don't store huge ones
+)
+
+var (
+	ErrNotFound = errors.New("session not found")
+	ErrRevoked  = errors.New("session revoked")
+)
+
+// Session is a single device a user is logged in on
+type Session struct {
+	ID            uuid.UUID `json:"id"`
+	Device        string    `json:"device"`
+	UserAgent     string    `json:"user_agent"`
+	IP            string    `json:"ip"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastRefreshAt time.Time `json:"last_refresh_at"`
+	Current       bool      `json:"current"`
+}
+
+// Meta is the request data recorded for a session.
+// It is the same data the request logger captures.
+type Meta struct {
+	UserAgent string
+	IP        string
+}
+
+// MetaFromEcho returns the session meta data of a request
+func MetaFromEcho(c echo.Context) Meta {
+	ua := c.Request().UserAgent()
+	if len(ua) > maxUserAgentLen {
+		ua = ua[:maxUserAgentLen]
+	}
+	return Meta{UserAgent: ua, IP: c.RealIP()}
+}
+
+// Create starts a new session for a user
+func Create(ctx context.Context, db *db.Database, userID uuid.UUID, meta Meta) (*Session, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	now := time.Now().UTC()
+	s := &Session{
+		ID:            uuid.New(),
+		Device:        Device(meta.UserAgent),
+		UserAgent:     meta.UserAgent,
+		IP:            meta.IP,
+		CreatedAt:     now,
+		LastRefreshAt: now,
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO sessions (id, user_id, device, user_agent, ip, created_at, last_refresh_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($6));
+	`, s.ID, userID, s.Device, s.UserAgent, s.IP, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return s, nil
+}
+
+// Touch records a refresh of a session. It fails if the session was revoked or expired.
+func Touch(ctx context.Context, db *db.Database, userID uuid.UUID, sessionID string, meta Meta) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	res, err := db.PostgresConn.ExecContext(ctx, `
+		UPDATE sessions
+		SET last_refresh_at=($1), ip=($2), user_agent=($3), device=($4)
+		WHERE id=($5) AND user_id=($6) AND revoked_at IS NULL AND last_refresh_at > ($7);
+	`, now, meta.IP, meta.UserAgent, Device(meta.UserAgent), id, userID, now.Add(-maxIdle))
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRevoked
+	}
+	return nil
+}
+
+// List returns all active sessions of a user, most recently used first.
+// The session with the ID `current` is flagged as such.
+func List(ctx context.Context, db *db.Database, userID uuid.UUID, current string) ([]Session, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT id, device, user_agent, ip, created_at, last_refresh_at FROM sessions
+		WHERE user_id=($1) AND revoked_at IS NULL AND last_refresh_at > ($2)
+		ORDER BY last_refresh_at DESC;
+	`, userID, time.Now().UTC().Add(-maxIdle))
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		var device, ua, ip sql.NullString
+		if err = rows.Scan(&s.ID, &device, &ua, &ip, &s.CreatedAt, &s.LastRefreshAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		s.Device, s.UserAgent, s.IP = device.String, ua.String, ip.String
+		s.Current = s.ID.String() == current
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke revokes a single session of a user
+func Revoke(ctx context.Context, db *db.Database, userID uuid.UUID, sessionID string) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := db.PostgresConn.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at=($1)
+		WHERE id=($2) AND user_id=($3) AND revoked_at IS NULL;
+	`, time.Now().UTC(), id, userID)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeAll revokes all sessions of a user, except the one with the ID `keep`.
+// It returns the number of sessions that were revoked.
+func RevokeAll(ctx context.Context, db *db.Database, userID uuid.UUID, keep string) (int64, error) {
+	if db == nil {
+		return 0, errors.New("database is nil")
+	}
+	keepID, _ := uuid.Parse(keep) // uuid.Nil never matches a session
+	res, err := db.PostgresConn.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at=($1)
+		WHERE user_id=($2) AND id<>($3) AND revoked_at IS NULL;
+	`, time.Now().UTC(), userID, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("exec error: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
@@ -0,0 +1,99 @@
+package totp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 secret used for the test vectors in RFC 6238, Appendix B
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// go test -v -timeout 30s -run ^TestHOTP$ github.com/easymirror/easymirror-backend/internal/totp
+func TestHOTP(t *testing.T) {
+	tests := []struct {
+		Unix     int64
+		Expected string
+	}{
+		{Unix: 59, Expected: "94287082"},
+		{Unix: 1111111109, Expected: "07081804"},
+		{Unix: 1111111111, Expected: "14050471"},
+		{Unix: 1234567890, Expected: "89005924"},
+		{Unix: 2000000000, Expected: "69279037"},
+		{Unix: 20000000000, Expected: "65353130"},
+	}
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := hotp([]byte("12345678901234567890"), uint64(Step(time.Unix(test.Unix, 0))), 8)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestValidate$ github.com/easymirror/easymirror-backend/internal/totp
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	v := &Validator{Now: func() time.Time { return now }}
+
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	next, _ := Code(rfcSecret, Step(now)+1)
+	stale, _ := Code(rfcSecret, Step(now)-3)
+
+	tests := []struct {
+		Code     string
+		Valid    bool
+		Expected int64
+	}{
+		{Code: code, Valid: true, Expected: Step(now)},
+		{Code: " " + code + " ", Valid: true, Expected: Step(now)},
+		{Code: previous, Valid: true, Expected: Step(now) - 1},
+		{Code: next, Valid: true, Expected: Step(now) + 1},
+		{Code: stale, Valid: false},
+		{Code: "12345", Valid: false},
+		{Code: "", Valid: false},
+	}
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			step, ok := v.Validate(rfcSecret, test.Code)
+			assert.Equal(t, test.Valid, ok)
+			if test.Valid {
+				assert.Equal(t, test.Expected, step)
+			}
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestURI$ github.com/easymirror/easymirror-backend/internal/totp
+func TestURI(t *testing.T) {
+	uri := URI("EasyMirror", "user@example.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/EasyMirror:user@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=EasyMirror")
+}
+
+// go test -v -timeout 30s -run ^TestRecoveryCodes$ github.com/easymirror/easymirror-backend/internal/totp
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Len(t, code, 19)
+		assert.False(t, seen[code], "duplicate recovery code")
+		seen[code] = true
+	}
+
+	// Hashes should ignore formatting
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+	assert.NotEqual(t, HashRecoveryCode(codes[0]), HashRecoveryCode(codes[1]))
+}
@@ -7,15 +7,23 @@ import (
 	"log"
 	"strings"
 
+	"github.com/easymirror/easymirror-backend/internal/authz"
 	"github.com/easymirror/easymirror-backend/internal/common"
 	"github.com/easymirror/easymirror-backend/internal/db"
 	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
+	"github.com/easymirror/easymirror-backend/internal/workspace"
+	"github.com/google/uuid"
 )
 
 const (
 	query_limit = 25
 )
 
+var (
+	ErrMirrorNotFound = authz.ErrNotFound
+	ErrForbidden      = authz.ErrForbidden
+)
+
 // Returns a list of items a user has uploaded
 func (u user) MirrorLinks(ctx context.Context, db *db.Database, pageNum int) ([]mirrorlink.MirrorLink, error) {
 	links, err := mirrorlink.GetUserLinks(ctx, db, u.ID().String(), common.GetPageOffset(query_limit, pageNum))
@@ -25,6 +33,18 @@ func (u user) MirrorLinks(ctx context.Context, db *db.Database, pageNum int) ([]
 	return links, nil
 }
 
+// WorkspaceMirrorLinks returns a list of items in a workspace the user is a member of
+func (u user) WorkspaceMirrorLinks(ctx context.Context, db *db.Database, workspaceID uuid.UUID, pageNum int) ([]mirrorlink.MirrorLink, error) {
+	if _, err := workspace.MemberRole(ctx, db, workspaceID, u.ID()); err != nil {
+		return nil, err
+	}
+	links, err := mirrorlink.GetWorkspaceLinks(ctx, db, workspaceID, pageNum)
+	if err != nil {
+		return nil, fmt.Errorf("GetWorkspaceLinks error: %w", err)
+	}
+	return links, nil
+}
+
 func (u user) UpdateMirrorLinkName(
 	ctx context.Context,
 	db *db.Database,
@@ -33,8 +53,11 @@ func (u user) UpdateMirrorLinkName(
 	if strings.TrimSpace(name) == "" {
 		return errors.New("name cannot be empty")
 	}
+	if err := authz.CheckMirror(ctx, db, u.ID(), linkID, authz.Edit); err != nil {
+		return err
+	}
 
-	if err := mirrorlink.UpdateName(ctx, db, linkID, u.ID().String(), name); err != nil {
+	if err := mirrorlink.UpdateName(ctx, db, linkID, name); err != nil {
 		log.Println("Error updating name:", err)
 		return fmt.Errorf("UpdateName error: %w", err)
 	}
@@ -42,7 +65,10 @@ func (u user) UpdateMirrorLinkName(
 }
 
 func (u user) DeleteMirrorLink(ctx context.Context, db *db.Database, linkID string) error {
-	if err := mirrorlink.Delete(ctx, db, u.ID().String(), linkID); err != nil {
+	if err := authz.CheckMirror(ctx, db, u.ID(), linkID, authz.Delete); err != nil {
+		return err
+	}
+	if err := mirrorlink.Delete(ctx, db, linkID); err != nil {
 		log.Println("Error deleting:", err)
 		return fmt.Errorf("delete error: %w", err)
 	}
@@ -50,9 +76,33 @@ func (u user) DeleteMirrorLink(ctx context.Context, db *db.Database, linkID stri
 }
 
 func (u user) GetFiles(ctx context.Context, db *db.Database, mirrorLinkID string) ([]mirrorlink.File, error) {
-	files, err := mirrorlink.GetFilesFromMirror(ctx, db, mirrorLinkID, u.ID().String())
+	if err := authz.CheckMirror(ctx, db, u.ID(), mirrorLinkID, authz.View); err != nil {
+		return nil, err
+	}
+	files, err := mirrorlink.GetFilesFromMirror(ctx, db, mirrorLinkID)
 	if err != nil {
 		return nil, fmt.Errorf("GetFilesFromMirror error: %w", err)
 	}
 	return files, nil
 }
+
+// MoveMirrorLink moves a mirror link into a workspace, or back to the user if workspaceID is nil.
+// The user must be able to edit the mirror link and upload to the target workspace.
+func (u user) MoveMirrorLink(ctx context.Context, db *db.Database, linkID string, workspaceID *uuid.UUID) error {
+	if err := authz.CheckMirror(ctx, db, u.ID(), linkID, authz.Edit); err != nil {
+		return err
+	}
+	if workspaceID != nil {
+		role, err := workspace.MemberRole(ctx, db, *workspaceID, u.ID())
+		if err != nil {
+			return err
+		}
+		if !role.AtLeast(workspace.RoleMember) {
+			return ErrForbidden
+		}
+	}
+	if err := mirrorlink.SetWorkspace(ctx, db, linkID, workspaceID); err != nil {
+		return fmt.Errorf("SetWorkspace error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+)
+
+// FromIdentity returns the user linked to an external identity (ie: an OpenID Connect `sub`).
+//
+// If the identity is not linked yet, it is linked to the user with the same verified email address,
+// but only if the provider verified that email too. Otherwise a new user is created.
+func FromIdentity(ctx context.Context, db *db.Database, provider, subject, email string, emailVerified bool) (User, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	email = strings.TrimSpace(email)
+	now := time.Now().UTC()
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+
+	// Look for an existing link
+	var userID uuid.UUID
+	err = tx.QueryRow(`
+		UPDATE user_identities SET last_login_at=($1)
+		WHERE provider=($2) AND subject=($3)
+		RETURNING user_id;
+	`, now, provider, subject).Scan(&userID)
+	switch {
+	case err == nil:
+		if err = tx.Commit(); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("commit error: %w", err)
+		}
+		return &user{id: userID}, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		tx.Rollback()
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	// Link by verified email
+	if emailVerified && email != "" {
+		err = tx.QueryRow(`
+			SELECT id FROM users
+			WHERE LOWER(email)=LOWER($1) AND verified_at IS NOT NULL
+			ORDER BY member_since ASC
+			LIMIT 1;
+		`, email).Scan(&userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			return nil, fmt.Errorf("query error: %w", err)
+		}
+	}
+
+	// Otherwise create a new user
+	if userID == uuid.Nil {
+		userID = uuid.New()
+		var (
+			userEmail  sql.NullString
+			verifiedAt sql.NullTime
+		)
+		if emailVerified && email != "" {
+			userEmail = sql.NullString{String: email, Valid: true}
+			verifiedAt = sql.NullTime{Time: now, Valid: true}
+		}
+		_, err = tx.Exec(`
+			INSERT INTO users (id, member_since, email, verified_at)
+			VALUES (($1), ($2), ($3), ($4));
+		`, userID, now, userEmail, verifiedAt)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("exec error: %w", err)
+		}
+	}
+
+	// Save the link
+	_, err = tx.Exec(`
+		INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($6));
+	`, uuid.New(), userID, provider, subject, email, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return &user{id: userID}, nil
+}
@@ -12,14 +12,15 @@ import (
 
 // Struct containing user info
 type Info struct {
-	ID          string    `json:"id"`
-	FirstName   string    `json:"first_name"`
-	LastName    string    `json:"last_name"`
-	Email       string    `json:"email"`
-	Phone       string    `json:"phone"`
-	Username    string    `json:"username"`
-	MemberSince time.Time `json:"member_since"`
-	NextRenew   time.Time `json:"next_renewal"`
+	ID            string    `json:"id"`
+	FirstName     string    `json:"first_name"`
+	LastName      string    `json:"last_name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Phone         string    `json:"phone"`
+	Username      string    `json:"username"`
+	MemberSince   time.Time `json:"member_since"`
+	NextRenew     time.Time `json:"next_renewal"`
 }
 
 type InfoKey int
@@ -43,7 +44,7 @@ func (u user) Info(ctx context.Context, db *db.Database) (*Info, error) {
 		return nil, fmt.Errorf("BeginTx error: %w", err)
 	}
 	rows, err := tx.Query(`
-		SELECT "first_name", "last_name", "email", "phone", "username", "member_since", "next_renewal" from users
+		SELECT "verified_at" IS NOT NULL, "first_name", "last_name", "email", "phone", "username", "member_since", "next_renewal" from users
 		WHERE id=($1);
 	`, u.ID())
 	if err != nil {
@@ -54,7 +55,7 @@ func (u user) Info(ctx context.Context, db *db.Database) (*Info, error) {
 	defer rows.Close()
 	info := &Info{ID: u.ID().String()}
 	for rows.Next() {
-		if err = rows.Scan(&info.FirstName, &info.LastName, &info.Email, &info.Phone, &info.Username, &info.MemberSince, &info.NextRenew); err != nil {
+		if err = rows.Scan(&info.EmailVerified, &info.FirstName, &info.LastName, &info.Email, &info.Phone, &info.Username, &info.MemberSince, &info.NextRenew); err != nil {
 			log.Println("Error scanning row:", err)
 		}
 	}
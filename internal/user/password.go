@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything after 72 bytes
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrWeakPassword       = fmt.Errorf("password must be between %v and %v characters", minPasswordLength, maxPasswordLength)
+)
+
+// SetPassword sets or changes the password of a user.
+// If the user already has a password, the current password must be given.
+func (u user) SetPassword(ctx context.Context, db *db.Database, current, newPassword string) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	if len(newPassword) < minPasswordLength || len(newPassword) > maxPasswordLength {
+		return ErrWeakPassword
+	}
+
+	// Check the current password, if any
+	var existing sql.NullString
+	err := db.PostgresConn.QueryRowContext(ctx, `SELECT password FROM users WHERE id=($1);`, u.ID()).Scan(&existing)
+	if err != nil {
+		return fmt.Errorf("query error: %w", err)
+	}
+	if existing.Valid && existing.String != "" {
+		if bcrypt.CompareHashAndPassword([]byte(existing.String), []byte(current)) != nil {
+			return ErrInvalidCredentials
+		}
+	}
+
+	return u.updatePassword(ctx, db, newPassword)
+}
+
+// updatePassword hashes and stores a new password without checking the old one
+func (u user) updatePassword(ctx context.Context, db *db.Database, newPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("bcrypt error: %w", err)
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTx error: %w", err)
+	}
+	if _, err = tx.Exec(`UPDATE users SET password=($1) WHERE id=($2);`, string(hash), u.ID()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+// Login looks up a user by username or email and validates the password
+func Login(ctx context.Context, db *db.Database, login, password string) (User, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	login = strings.TrimSpace(login)
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	var (
+		id   uuid.UUID
+		hash string
+	)
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT id, password FROM users
+		WHERE (username=($1) OR LOWER(email)=LOWER($1))
+		AND password IS NOT NULL
+		ORDER BY verified_at IS NULL, member_since ASC
+		LIMIT 1;
+	`, login).Scan(&id, &hash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Compare against a dummy hash so response times do not reveal whether the user exists
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	case err != nil:
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &user{id: id}, nil
+}
+
+// dummyHash is a bcrypt hash used to keep login timing constant for unknown users
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("easymirror-dummy-password"), bcrypt.DefaultCost)
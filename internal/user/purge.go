@@ -0,0 +1,51 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+const purgeBatchSize = 1000 // Delete in batches so a large backlog does not hold locks for long
+
+// PurgeAnonymous deletes anonymous users that are older than the retention window and never used their account.
+//
+// A user is anonymous if it has no email, username, password or linked identity.
+// Users with mirror links, that own or are members of a workspace, or with a session or API key that was
+// created or used within the window, are kept.
+// It returns the number of users that were deleted.
+func PurgeAnonymous(ctx context.Context, db *db.Database, retention time.Duration) (int64, error) {
+	if db == nil {
+		return 0, errors.New("database is nil")
+	}
+	cutoff := time.Now().UTC().Add(-retention)
+
+	var total int64
+	for {
+		res, err := db.PostgresConn.ExecContext(ctx, `
+			DELETE FROM users WHERE id IN (
+				SELECT u.id FROM users u
+				WHERE u.member_since < ($1)
+				AND u.email IS NULL AND u.username IS NULL AND u.password IS NULL
+				AND NOT EXISTS (SELECT 1 FROM user_identities i WHERE i.user_id=u.id)
+				AND NOT EXISTS (SELECT 1 FROM mirroring_links m WHERE m.created_by_id=u.id)
+				AND NOT EXISTS (SELECT 1 FROM sessions s WHERE s.user_id=u.id AND s.last_refresh_at >= ($1))
+				AND NOT EXISTS (SELECT 1 FROM api_keys k WHERE k.user_id=u.id AND COALESCE(k.last_used_at, k.created_at) >= ($1))
+				AND NOT EXISTS (SELECT 1 FROM workspaces w WHERE w.created_by_id=u.id)
+				AND NOT EXISTS (SELECT 1 FROM workspace_members wm WHERE wm.user_id=u.id)
+				LIMIT ($2)
+			);
+		`, cutoff, purgeBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("exec error: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestPurgeAnonymous$ github.com/easymirror/easymirror-backend/internal/user
+func TestPurgeAnonymous(t *testing.T) {
+	database, err := db.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.PostgresConn.Close()
+	ctx := context.Background()
+	old := time.Now().UTC().Add(-48 * time.Hour)
+	exec := func(query string, args ...any) error {
+		_, err := database.PostgresConn.Exec(query, args...)
+		return err
+	}
+
+	// A workspace of a user with an account, anonymous users can be members of it
+	owner, err := Create(database)
+	if err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+	defer exec(`DELETE FROM users WHERE id=($1);`, owner.ID()) // Deletes its workspace too
+	if err = exec(`UPDATE users SET username=($2) WHERE id=($1);`, owner.ID(), "purge-"+owner.ID().String()); err != nil {
+		t.Fatal(err)
+	}
+	sharedID := uuid.New()
+	if err = exec(`INSERT INTO workspaces (id, name, created_by_id, created_at) VALUES (($1), 'Shared', ($2), ($3));`, sharedID, owner.ID(), old); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Setup    func(userID uuid.UUID) error
+		Expected bool // Whether the user is purged
+	}{
+		{Setup: func(userID uuid.UUID) error { return nil }, Expected: true},
+		{
+			Setup: func(userID uuid.UUID) error {
+				return exec(`INSERT INTO workspaces (id, name, created_by_id, created_at) VALUES (($1), 'Own', ($2), ($3));`, uuid.New(), userID, old)
+			},
+			Expected: false,
+		},
+		{
+			Setup: func(userID uuid.UUID) error {
+				return exec(`INSERT INTO workspace_members (workspace_id, user_id, role, added_at) VALUES (($1), ($2), 'member', ($3));`, sharedID, userID, old)
+			},
+			Expected: false,
+		},
+		{
+			// A key that was just created but never used
+			Setup: func(userID uuid.UUID) error {
+				return exec(`INSERT INTO api_keys (id, user_id, name, key_hash, scopes, created_at) VALUES (($1), ($2), 'cli', 'hash', 'upload', ($3));`, uuid.New(), userID, time.Now().UTC())
+			},
+			Expected: false,
+		},
+		{
+			Setup: func(userID uuid.UUID) error {
+				return exec(`INSERT INTO api_keys (id, user_id, name, key_hash, scopes, created_at, last_used_at) VALUES (($1), ($2), 'cli', 'hash', 'upload', ($3), ($3));`, uuid.New(), userID, old)
+			},
+			Expected: true,
+		},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			u, err := Create(database)
+			if err != nil {
+				t.Fatalf("Error creating user: %v", err)
+			}
+			defer exec(`DELETE FROM users WHERE id=($1);`, u.ID())
+			if err = exec(`UPDATE users SET member_since=($2) WHERE id=($1);`, u.ID(), old); err != nil {
+				t.Fatal(err)
+			}
+			if err = test.Setup(u.ID()); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = PurgeAnonymous(ctx, database, 24*time.Hour); err != nil {
+				t.Fatalf("Error purging: %v", err)
+			}
+			var exists bool
+			if err = database.PostgresConn.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id=($1));`, u.ID()).Scan(&exists); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.Expected, !exists)
+		})
+	}
+}
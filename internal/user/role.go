@@ -0,0 +1,38 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+var ErrSuspended = errors.New("user suspended")
+
+// Role returns the role of the user. It returns ErrSuspended if the account was suspended.
+func (u user) Role(ctx context.Context, db *db.Database) (auth.Role, error) {
+	if db == nil {
+		return "", errors.New("database is nil")
+	}
+	var (
+		role      sql.NullString
+		suspended sql.NullTime
+	)
+	err := db.PostgresConn.QueryRowContext(ctx, `SELECT role, suspended_at FROM users WHERE id=($1);`, u.ID()).Scan(&role, &suspended)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", ErrNotFound
+	case err != nil:
+		return "", fmt.Errorf("query error: %w", err)
+	case suspended.Valid:
+		return "", ErrSuspended
+	}
+	r, err := auth.ParseRole(role.String)
+	if err != nil {
+		return auth.RoleUser, nil // Never grant more than a regular user when the column holds garbage
+	}
+	return r, nil
+}
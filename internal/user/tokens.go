@@ -0,0 +1,118 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound     = errors.New("user not found")
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// IssueToken creates a signed, single-use token for a given purpose.
+// The token ID is recorded in the database so it can only be consumed once.
+func IssueToken(ctx context.Context, db *db.Database, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	if db == nil {
+		return "", errors.New("database is nil")
+	}
+	token, id, err := auth.NewPurposeToken(purpose, userID.String(), ttl)
+	if err != nil {
+		return "", fmt.Errorf("NewPurposeToken error: %w", err)
+	}
+
+	now := time.Now().UTC()
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("BeginTx error: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO user_tokens (id, user_id, purpose, created_at, expires_at)
+		VALUES (($1), ($2), ($3), ($4), ($5));
+	`, id, userID, purpose, now, now.Add(ttl))
+	if err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("commit error: %w", err)
+	}
+	return token, nil
+}
+
+// ConsumeToken validates a single-use token and marks it as used.
+// It returns the user the token was issued to.
+func ConsumeToken(ctx context.Context, db *db.Database, purpose, token string) (User, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	claims, err := auth.ParsePurposeToken(purpose, token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var userID uuid.UUID
+	err = db.PostgresConn.QueryRowContext(ctx, `
+		UPDATE user_tokens SET used_at=($1)
+		WHERE id=($2) AND purpose=($3) AND used_at IS NULL AND expires_at > ($1)
+		RETURNING user_id;
+	`, time.Now().UTC(), claims.ID, purpose).Scan(&userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrInvalidToken
+	case err != nil:
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	if userID.String() != claims.Subject {
+		return nil, ErrInvalidToken
+	}
+	return &user{id: userID}, nil
+}
+
+// revokeTokens marks all unused tokens of a given purpose as used
+func revokeTokens(ctx context.Context, tx *sql.Tx, userID uuid.UUID, purpose string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE user_tokens SET used_at=($1)
+		WHERE user_id=($2) AND purpose=($3) AND used_at IS NULL;
+	`, time.Now().UTC(), userID, purpose)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// FromEmail returns the user with a given email address.
+// Users that verified the address take precedence over ones that merely entered it.
+func FromEmail(ctx context.Context, db *db.Database, email string) (User, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, ErrNotFound
+	}
+
+	var id uuid.UUID
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT id FROM users
+		WHERE LOWER(email)=LOWER($1)
+		ORDER BY verified_at IS NULL, member_since ASC
+		LIMIT 1;
+	`, email).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	return &user{id: id}, nil
+}
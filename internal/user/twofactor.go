@@ -0,0 +1,272 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/totp"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer        = "EasyMirror"
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrTOTPEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrInvalidCode    = errors.New("invalid code")
+)
+
+// totpValidator validates TOTP codes. Tests can replace its clock.
+var totpValidator = totp.NewValidator()
+
+// TOTPEnrollment contains the data an authenticator app needs to be set up
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// EnrollTOTP starts the TOTP enrollment for a user.
+// The secret is not active until it is confirmed with ConfirmTOTP.
+func (u user) EnrollTOTP(ctx context.Context, db *db.Database) (*TOTPEnrollment, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	if enabled, err := u.TOTPEnabled(ctx, db); err != nil {
+		return nil, err
+	} else if enabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, fmt.Errorf("NewSecret error: %w", err)
+	}
+
+	// Use the most recognizable name of the user as the account label
+	var username, email sql.NullString
+	err = db.PostgresConn.QueryRowContext(ctx, `SELECT username, email FROM users WHERE id=($1);`, u.ID()).Scan(&username, &email)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	account := u.ID().String()
+	switch {
+	case email.String != "":
+		account = email.String
+	case username.String != "":
+		account = username.String
+	}
+
+	// Save the pending secret
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO user_totp (user_id, secret, created_at)
+		VALUES (($1), ($2), ($3))
+		ON CONFLICT (user_id)
+		DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at, confirmed_at = NULL, last_used_step = NULL;
+	`, u.ID(), secret, time.Now().UTC())
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return &TOTPEnrollment{Secret: secret, URI: totp.URI(totpIssuer, account, secret)}, nil
+}
+
+// ConfirmTOTP activates a pending TOTP enrollment with a valid code.
+// It returns a fresh set of recovery codes that are only shown once.
+func (u user) ConfirmTOTP(ctx context.Context, db *db.Database, code string) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+
+	var (
+		secret    string
+		confirmed sql.NullTime
+	)
+	err := db.PostgresConn.QueryRowContext(ctx, `SELECT secret, confirmed_at FROM user_totp WHERE user_id=($1);`, u.ID()).Scan(&secret, &confirmed)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrTOTPNotEnabled
+	case err != nil:
+		return nil, fmt.Errorf("query error: %w", err)
+	case confirmed.Valid:
+		return nil, ErrTOTPEnabled
+	}
+
+	step, ok := totpValidator.Validate(secret, code)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+	_, err = tx.Exec(`UPDATE user_totp SET confirmed_at=($1), last_used_step=($2) WHERE user_id=($3);`, time.Now().UTC(), step, u.ID())
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	codes, err := replaceRecoveryCodes(tx, u.ID())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns off two-factor authentication after verifying a code
+func (u user) DisableTOTP(ctx context.Context, db *db.Database, code string) error {
+	if err := u.VerifySecondFactor(ctx, db, code); err != nil {
+		return err
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTx error: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM user_totp WHERE user_id=($1);`, u.ID()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id=($1);`, u.ID()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after verifying a code
+func (u user) RegenerateRecoveryCodes(ctx context.Context, db *db.Database, code string) ([]string, error) {
+	if err := u.VerifySecondFactor(ctx, db, code); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+	codes, err := replaceRecoveryCodes(tx, u.ID())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return codes, nil
+}
+
+// TOTPEnabled returns true if the user has a confirmed TOTP secret
+func (u user) TOTPEnabled(ctx context.Context, db *db.Database) (bool, error) {
+	if db == nil {
+		return false, errors.New("database is nil")
+	}
+	var enabled bool
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM user_totp WHERE user_id=($1) AND confirmed_at IS NOT NULL);
+	`, u.ID()).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("query error: %w", err)
+	}
+	return enabled, nil
+}
+
+// VerifySecondFactor checks a TOTP code or an unused recovery code.
+// TOTP codes can only be used once, and recovery codes are burned after use.
+func (u user) VerifySecondFactor(ctx context.Context, db *db.Database, code string) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+
+	var (
+		secret   string
+		lastStep sql.NullInt64
+	)
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT secret, last_used_step FROM user_totp
+		WHERE user_id=($1) AND confirmed_at IS NOT NULL;
+	`, u.ID()).Scan(&secret, &lastStep)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrTOTPNotEnabled
+	case err != nil:
+		return fmt.Errorf("query error: %w", err)
+	}
+
+	// Try the code as a TOTP code first
+	if step, ok := totpValidator.Validate(secret, code); ok {
+		if lastStep.Valid && step <= lastStep.Int64 {
+			return ErrInvalidCode // Code was already used
+		}
+		res, err := db.PostgresConn.ExecContext(ctx, `
+			UPDATE user_totp SET last_used_step=($1)
+			WHERE user_id=($2) AND (last_used_step IS NULL OR last_used_step < ($1));
+		`, step, u.ID())
+		if err != nil {
+			return fmt.Errorf("exec error: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrInvalidCode // Lost a race against another request using the same code
+		}
+		return nil
+	}
+
+	// Fall back to recovery codes
+	res, err := db.PostgresConn.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at=($1)
+		WHERE user_id=($2) AND code_hash=($3) AND used_at IS NULL;
+	`, time.Now().UTC(), u.ID(), totp.HashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes all existing recovery codes of a user and generates new ones
+func replaceRecoveryCodes(tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	codes, err := totp.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, fmt.Errorf("NewRecoveryCodes error: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id=($1);`, userID); err != nil {
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	now := time.Now().UTC()
+	for _, code := range codes {
+		_, err = tx.Exec(`
+			INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+			VALUES (($1), ($2), ($3), ($4));
+		`, uuid.New(), userID, totp.HashRecoveryCode(code), now)
+		if err != nil {
+			return nil, fmt.Errorf("exec error: %w", err)
+		}
+	}
+	return codes, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/easymirror/easymirror-backend/internal/auth"
 	"github.com/easymirror/easymirror-backend/internal/db"
 	"github.com/easymirror/easymirror-backend/internal/mirrorlink"
 	"github.com/golang-jwt/jwt/v5"
@@ -22,7 +23,19 @@ type User interface {
 	UpdateMirrorLinkName(ctx context.Context, db *db.Database, linkID, name string) error
 	DeleteMirrorLink(ctx context.Context, db *db.Database, linkID string) error
 	GetFiles(ctx context.Context, db *db.Database, linkID string) ([]mirrorlink.File, error)
+	WorkspaceMirrorLinks(ctx context.Context, db *db.Database, workspaceID uuid.UUID, pageNum int) ([]mirrorlink.MirrorLink, error) // Returns the items of a workspace
+	MoveMirrorLink(ctx context.Context, db *db.Database, linkID string, workspaceID *uuid.UUID) error                               // Moves an item into or out of a workspace
 	Update(ctx context.Context, db *db.Database, k InfoKey, newVal string) error
+	SetPassword(ctx context.Context, db *db.Database, current, newPassword string) error         // Sets or changes the user's password
+	EnrollTOTP(ctx context.Context, db *db.Database) (*TOTPEnrollment, error)                    // Starts TOTP enrollment
+	ConfirmTOTP(ctx context.Context, db *db.Database, code string) ([]string, error)             // Activates TOTP and returns recovery codes
+	DisableTOTP(ctx context.Context, db *db.Database, code string) error                         // Turns off TOTP
+	RegenerateRecoveryCodes(ctx context.Context, db *db.Database, code string) ([]string, error) // Replaces all recovery codes
+	TOTPEnabled(ctx context.Context, db *db.Database) (bool, error)                              // Returns true if TOTP is active
+	VerifySecondFactor(ctx context.Context, db *db.Database, code string) error                  // Validates a TOTP or recovery code
+	SetEmail(ctx context.Context, db *db.Database, email string) error                           // Changes the email address and marks it as unverified
+	EmailVerified(ctx context.Context, db *db.Database) (bool, error)                            // Returns true if the email address was verified
+	Role(ctx context.Context, db *db.Database) (auth.Role, error)                                // Returns the role, or ErrSuspended
 }
 
 type user struct {
@@ -64,6 +77,11 @@ func New() User {
 	return newUser()
 }
 
+// FromID returns a User object for an existing user ID
+func FromID(id uuid.UUID) User {
+	return &user{id: id}
+}
+
 func newUser() User {
 	return user{id: uuid.New()}
 }
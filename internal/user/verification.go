@@ -0,0 +1,171 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/auth"
+	"github.com/easymirror/easymirror-backend/internal/db"
+)
+
+var (
+	ErrInvalidEmail = errors.New("invalid email")
+	ErrEmailTaken   = errors.New("email already in use")
+	ErrNoEmail      = errors.New("user has no email")
+)
+
+// NormalizeEmail validates an email address and returns it in lower case
+func NormalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// SetEmail changes the user's email address. The new address is unverified until
+// the user follows the link sent to it, and any outstanding verification links are revoked.
+func (u user) SetEmail(ctx context.Context, db *db.Database, email string) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTx error: %w", err)
+	}
+
+	// Another user already proved they own this address
+	var taken bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email)=($1) AND verified_at IS NOT NULL AND id<>($2));
+	`, email, u.ID()).Scan(&taken)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("query error: %w", err)
+	}
+	if taken {
+		tx.Rollback()
+		return ErrEmailTaken
+	}
+
+	// Only reset the verification if the address actually changed
+	_, err = tx.Exec(`
+		UPDATE users
+		SET verified_at = CASE WHEN LOWER(email)=($1) THEN verified_at ELSE NULL END, email=($1)
+		WHERE id=($2);
+	`, email, u.ID())
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if err = revokeTokens(ctx, tx, u.ID(), auth.PurposeVerifyEmail); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+// EmailVerified returns true if the user has a verified email address
+func (u user) EmailVerified(ctx context.Context, db *db.Database) (bool, error) {
+	if db == nil {
+		return false, errors.New("database is nil")
+	}
+	var verified bool
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT email IS NOT NULL AND verified_at IS NOT NULL FROM users WHERE id=($1);
+	`, u.ID()).Scan(&verified)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, ErrNotFound
+	case err != nil:
+		return false, fmt.Errorf("query error: %w", err)
+	}
+	return verified, nil
+}
+
+// Email returns the user's email address and whether it was verified
+func Email(ctx context.Context, db *db.Database, u User) (string, bool, error) {
+	if db == nil {
+		return "", false, errors.New("database is nil")
+	}
+	var email sql.NullString
+	var verifiedAt sql.NullTime
+	err := db.PostgresConn.QueryRowContext(ctx, `SELECT email, verified_at FROM users WHERE id=($1);`, u.ID()).Scan(&email, &verifiedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", false, ErrNotFound
+	case err != nil:
+		return "", false, fmt.Errorf("query error: %w", err)
+	case !email.Valid || email.String == "":
+		return "", false, ErrNoEmail
+	}
+	return email.String, verifiedAt.Valid, nil
+}
+
+// VerifyEmail consumes an email verification token and marks the user's email as verified
+func VerifyEmail(ctx context.Context, db *db.Database, token string) (User, error) {
+	u, err := ConsumeToken(ctx, db, auth.PurposeVerifyEmail, token)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.PostgresConn.ExecContext(ctx, `
+		UPDATE users SET verified_at=($1)
+		WHERE id=($2) AND email IS NOT NULL AND verified_at IS NULL;
+	`, time.Now().UTC(), u.ID())
+	if err != nil {
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	return u, nil
+}
+
+// ResetPassword consumes a password reset token and sets a new password.
+// Since the token was sent by email, the email address is verified as well.
+// All other outstanding reset links of the user are revoked.
+func ResetPassword(ctx context.Context, db *db.Database, token, newPassword string) (User, error) {
+	if len(newPassword) < minPasswordLength || len(newPassword) > maxPasswordLength {
+		return nil, ErrWeakPassword // Check before consuming so a weak password does not burn the token
+	}
+	u, err := ConsumeToken(ctx, db, auth.PurposePasswordReset, token)
+	if err != nil {
+		return nil, err
+	}
+	if err = u.(*user).updatePassword(ctx, db, newPassword); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE users SET verified_at=($1)
+		WHERE id=($2) AND email IS NOT NULL AND verified_at IS NULL;
+	`, time.Now().UTC(), u.ID())
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	if err = revokeTokens(ctx, tx, u.ID(), auth.PurposePasswordReset); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return u, nil
+}
@@ -0,0 +1,35 @@
+package user
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestNormalizeEmail$ github.com/easymirror/easymirror-backend/internal/user
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected string
+		Err      error
+	}{
+		{Input: "Jane.Doe@Example.COM", Expected: "jane.doe@example.com"},
+		{Input: "  jane@example.com ", Expected: "jane@example.com"},
+		{Input: "", Err: ErrInvalidEmail},
+		{Input: "jane", Err: ErrInvalidEmail},
+		{Input: "Jane <jane@example.com>", Err: ErrInvalidEmail},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result, err := NormalizeEmail(test.Input)
+			if test.Err != nil {
+				assert.ErrorIs(t, err, test.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package vault
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/easymirror/easymirror-backend/internal/hosts"
+	"github.com/google/uuid"
+)
+
+const rewrapBatchSize = 100
+
+var ErrNotFound = errors.New("no credentials stored for host")
+
+// Entry describes stored host credentials without revealing them
+type Entry struct {
+	Host      string    `json:"host"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PutCredentials stores a user's credentials for a host, replacing any existing ones
+func PutCredentials(ctx context.Context, db *db.Database, k *Keyring, userID uuid.UUID, host string, creds hosts.Credentials) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+	e, err := k.Seal(plaintext, credentialsAAD(userID, host))
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err = db.PostgresConn.ExecContext(ctx, `
+		INSERT INTO host_credentials (user_id, host, key_id, wrapped_key, ciphertext, created_at, updated_at)
+		VALUES (($1), ($2), ($3), ($4), ($5), ($6), ($6))
+		ON CONFLICT (user_id, host)
+		DO UPDATE
+		SET key_id = EXCLUDED.key_id, wrapped_key = EXCLUDED.wrapped_key,
+		ciphertext = EXCLUDED.ciphertext, updated_at = EXCLUDED.updated_at;
+	`, userID, host, e.KeyID, e.WrappedKey, e.Ciphertext, now)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// GetCredentials returns a user's decrypted credentials for a host, or ErrNotFound
+func GetCredentials(ctx context.Context, db *db.Database, k *Keyring, userID uuid.UUID, host string) (hosts.Credentials, error) {
+	var creds hosts.Credentials
+	if db == nil {
+		return creds, errors.New("database is nil")
+	}
+	e := &Envelope{}
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT key_id, wrapped_key, ciphertext FROM host_credentials WHERE user_id=($1) AND host=($2);
+	`, userID, host).Scan(&e.KeyID, &e.WrappedKey, &e.Ciphertext)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return creds, ErrNotFound
+	case err != nil:
+		return creds, fmt.Errorf("query error: %w", err)
+	}
+
+	plaintext, err := k.Open(e, credentialsAAD(userID, host))
+	if err != nil {
+		return creds, err
+	}
+	if err = json.Unmarshal(plaintext, &creds); err != nil {
+		return creds, fmt.Errorf("unmarshal error: %w", err)
+	}
+	return creds, nil
+}
+
+// ListCredentials returns the hosts a user has stored credentials for
+func ListCredentials(ctx context.Context, db *db.Database, userID uuid.UUID) ([]Entry, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT host, created_at, updated_at FROM host_credentials WHERE user_id=($1) ORDER BY host ASC;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err = rows.Scan(&e.Host, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteCredentials removes a user's credentials for a host
+func DeleteCredentials(ctx context.Context, db *db.Database, userID uuid.UUID, host string) error {
+	if db == nil {
+		return errors.New("database is nil")
+	}
+	res, err := db.PostgresConn.ExecContext(ctx, `DELETE FROM host_credentials WHERE user_id=($1) AND host=($2);`, userID, host)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RewrapAll rewraps every stored data key that is not wrapped with the active master key yet.
+// It returns the number of rewrapped keys. Once it returns 0, old master keys can be removed.
+func RewrapAll(ctx context.Context, db *db.Database, k *Keyring) (int64, error) {
+	if db == nil {
+		return 0, errors.New("database is nil")
+	}
+	if k == nil {
+		return 0, ErrDisabled
+	}
+
+	var total int64
+	for {
+		n, err := rewrapBatch(ctx, db, k)
+		total += n
+		if err != nil || n < rewrapBatchSize {
+			return total, err
+		}
+	}
+}
+
+// rewrapBatch rewraps up to rewrapBatchSize data keys in one transaction
+func rewrapBatch(ctx context.Context, db *db.Database, k *Keyring) (int64, error) {
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("BeginTx error: %w", err)
+	}
+	rows, err := tx.Query(`
+		SELECT user_id, host, key_id, wrapped_key FROM host_credentials
+		WHERE key_id != ($1)
+		LIMIT ($2)
+		FOR UPDATE SKIP LOCKED;
+	`, k.ActiveKey(), rewrapBatchSize)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("query error: %w", err)
+	}
+	type row struct {
+		userID uuid.UUID
+		host   string
+		e      Envelope
+	}
+	var batch []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.userID, &r.host, &r.e.KeyID, &r.e.WrappedKey); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return 0, fmt.Errorf("scan error: %w", err)
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+
+	for _, r := range batch {
+		e, _, err := k.Rewrap(&r.e)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("rewrap error for %v/%v: %w", r.userID, r.host, err)
+		}
+		_, err = tx.Exec(`
+			UPDATE host_credentials SET key_id=($1), wrapped_key=($2) WHERE user_id=($3) AND host=($4);
+		`, e.KeyID, e.WrappedKey, r.userID, r.host)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("exec error: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("commit error: %w", err)
+	}
+	return int64(len(batch)), nil
+}
+
+// credentialsAAD binds sealed credentials to the user and host they belong to,
+// so rows can't be swapped between users or hosts
+func credentialsAAD(userID uuid.UUID, host string) []byte {
+	return []byte("host_credentials:" + userID.String() + ":" + host)
+}
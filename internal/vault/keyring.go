@@ -0,0 +1,191 @@
+// Package vault stores secrets of users, like their own host credentials, encrypted at rest.
+//
+// It uses envelope encryption: every secret is sealed with its own random data key,
+// and the data key is wrapped with a master key. Master keys are rotated by adding a new
+// active key and rewrapping the data keys, the secrets themselves never have to be re-encrypted.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const dataKeySize = 32 // AES-256
+
+var (
+	ErrDisabled   = errors.New("vault is not configured")
+	ErrUnknownKey = errors.New("unknown master key")
+	ErrDecrypt    = errors.New("could not decrypt secret")
+)
+
+// Keyring holds the master keys. New data keys are always wrapped with the active key,
+// the other keys are only kept to unwrap data keys that were not rewrapped yet.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// Envelope is an encrypted secret together with its wrapped data key
+type Envelope struct {
+	KeyID      string // ID of the master key that wrapped the data key
+	WrappedKey []byte // Nonce and the encrypted data key
+	Ciphertext []byte // Nonce and the encrypted secret
+}
+
+// NewKeyring creates a keyring from 32 byte master keys, wrapping with the active one
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, active)
+	}
+	k := &Keyring{active: active, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, ":,") {
+			return nil, fmt.Errorf("invalid master key id %q", id)
+		}
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("master key %q must be %v bytes", id, dataKeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// FromEnv creates a keyring from VAULT_MASTER_KEYS, a comma separated list of `id:base64key` pairs.
+// The first key is the active one. It returns nil and no error if the vault is not configured.
+func FromEnv() (*Keyring, error) {
+	raw := strings.TrimSpace(os.Getenv("VAULT_MASTER_KEYS"))
+	if raw == "" {
+		return nil, nil
+	}
+	var active string
+	keys := map[string][]byte{}
+	for _, pair := range strings.Split(raw, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, errors.New("VAULT_MASTER_KEYS entries must look like id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q is not valid base64: %w", id, err)
+		}
+		if active == "" {
+			active = id
+		}
+		keys[id] = key
+	}
+	return NewKeyring(active, keys)
+}
+
+// ActiveKey returns the ID of the master key new secrets are wrapped with
+func (k *Keyring) ActiveKey() string { return k.active }
+
+// Seal encrypts a secret with a new data key.
+// aad is authenticated but not encrypted, it binds the secret to where it is stored.
+func (k *Keyring) Seal(plaintext, aad []byte) (*Envelope, error) {
+	if k == nil {
+		return nil, ErrDisabled
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("rand error: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(aead, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{KeyID: k.active, WrappedKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts a secret. aad must be the same as when it was sealed.
+func (k *Keyring) Open(e *Envelope, aad []byte) ([]byte, error) {
+	dataKey, err := k.unwrap(e)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, e.Ciphertext, aad)
+}
+
+// Rewrap wraps the data key of an envelope with the active master key.
+// It returns false if the envelope already uses the active key.
+func (k *Keyring) Rewrap(e *Envelope) (*Envelope, bool, error) {
+	if k == nil {
+		return nil, false, ErrDisabled
+	}
+	if e.KeyID == k.active {
+		return e, false, nil
+	}
+	dataKey, err := k.unwrap(e)
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return nil, false, err
+	}
+	return &Envelope{KeyID: k.active, WrappedKey: wrapped, Ciphertext: e.Ciphertext}, true, nil
+}
+
+// unwrap decrypts the data key of an envelope
+func (k *Keyring) unwrap(e *Envelope) ([]byte, error) {
+	if k == nil {
+		return nil, ErrDisabled
+	}
+	master, ok := k.keys[e.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, e.KeyID)
+	}
+	return open(master, e.WrappedKey, []byte(e.KeyID))
+}
+
+// newAEAD returns AES-GCM for a given key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes error: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data and prepends a random nonce
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("rand error: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts data that was encrypted with seal
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
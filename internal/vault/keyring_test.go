@@ -0,0 +1,133 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, dataKeySize)
+}
+
+// go test -v -timeout 30s -run ^TestSealOpen$ github.com/easymirror/easymirror-backend/internal/vault
+func TestSealOpen(t *testing.T) {
+	k, err := NewKeyring("v1", map[string][]byte{"v1": testKey(1)})
+	require.NoError(t, err)
+
+	e, err := k.Seal([]byte("secret"), []byte("aad"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", e.KeyID)
+	assert.NotContains(t, string(e.Ciphertext), "secret")
+
+	tests := []struct {
+		Envelope *Envelope
+		AAD      string
+		Expected string
+		Err      error
+	}{
+		{Envelope: e, AAD: "aad", Expected: "secret"},
+		{Envelope: e, AAD: "other", Err: ErrDecrypt},
+		{Envelope: &Envelope{KeyID: "v1", WrappedKey: e.WrappedKey, Ciphertext: flip(e.Ciphertext)}, AAD: "aad", Err: ErrDecrypt},
+		{Envelope: &Envelope{KeyID: "v1", WrappedKey: flip(e.WrappedKey), Ciphertext: e.Ciphertext}, AAD: "aad", Err: ErrDecrypt},
+		{Envelope: &Envelope{KeyID: "v1", WrappedKey: e.WrappedKey[:4], Ciphertext: e.Ciphertext}, AAD: "aad", Err: ErrDecrypt},
+		{Envelope: &Envelope{KeyID: "v0", WrappedKey: e.WrappedKey, Ciphertext: e.Ciphertext}, AAD: "aad", Err: ErrUnknownKey},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result, err := k.Open(test.Envelope, []byte(test.AAD))
+			if test.Err != nil {
+				assert.ErrorIs(t, err, test.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, string(result))
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestRewrap$ github.com/easymirror/easymirror-backend/internal/vault
+func TestRewrap(t *testing.T) {
+	old, err := NewKeyring("v1", map[string][]byte{"v1": testKey(1)})
+	require.NoError(t, err)
+	e, err := old.Seal([]byte("secret"), nil)
+	require.NoError(t, err)
+
+	// Rotate to v2, keeping v1 around to unwrap
+	rotated, err := NewKeyring("v2", map[string][]byte{"v1": testKey(1), "v2": testKey(2)})
+	require.NoError(t, err)
+	rewrapped, changed, err := rotated.Rewrap(e)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "v2", rewrapped.KeyID)
+	assert.Equal(t, e.Ciphertext, rewrapped.Ciphertext)
+
+	_, changed, err = rotated.Rewrap(rewrapped)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Once everything is rewrapped, v1 can be dropped
+	current, err := NewKeyring("v2", map[string][]byte{"v2": testKey(2)})
+	require.NoError(t, err)
+	got, err := current.Open(rewrapped, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(got))
+	_, err = current.Open(e, nil)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+// go test -v -timeout 30s -run ^TestFromEnv$ github.com/easymirror/easymirror-backend/internal/vault
+func TestFromEnv(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+	tests := []struct {
+		Input    string
+		Expected string // Active key
+		Err      bool
+	}{
+		{Input: "", Expected: ""}, // Not configured
+		{Input: "v1:" + k1, Expected: "v1"},
+		{Input: "v2:" + k2 + ", v1:" + k1, Expected: "v2"},
+		{Input: k1, Err: true}, // Missing id
+		{Input: "v1:not base64", Err: true},
+		{Input: "v1:" + base64.StdEncoding.EncodeToString([]byte("short")), Err: true},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			t.Setenv("VAULT_MASTER_KEYS", test.Input)
+			k, err := FromEnv()
+			if test.Err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.Expected == "" {
+				assert.Nil(t, k)
+				return
+			}
+			assert.Equal(t, test.Expected, k.ActiveKey())
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestDisabled$ github.com/easymirror/easymirror-backend/internal/vault
+func TestDisabled(t *testing.T) {
+	var k *Keyring
+	_, err := k.Seal([]byte("secret"), nil)
+	assert.ErrorIs(t, err, ErrDisabled)
+	_, err = k.Open(&Envelope{}, nil)
+	assert.ErrorIs(t, err, ErrDisabled)
+}
+
+// flip returns a copy of b with its last bit flipped
+func flip(b []byte) []byte {
+	c := append([]byte{}, b...)
+	c[len(c)-1] ^= 1
+	return c
+}
@@ -0,0 +1,56 @@
+package workspace
+
+import "errors"
+
+// Role is the role of a member within a workspace
+type Role string
+
+const (
+	RoleOwner  Role = "owner"  // Created the workspace. Can do everything, including deleting it
+	RoleAdmin  Role = "admin"  // Can manage members and delete any mirror in the workspace
+	RoleMember Role = "member" // Can upload, rename and move mirrors
+	RoleViewer Role = "viewer" // Can only view mirrors
+)
+
+var ErrUnknownRole = errors.New("unknown workspace role")
+
+var roleRanks = map[Role]int{RoleViewer: 0, RoleMember: 1, RoleAdmin: 2, RoleOwner: 3}
+
+// ParseRole validates a role that can be granted to a member. Ownership cannot be granted.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleRanks[r]; !ok || r == RoleOwner {
+		return "", ErrUnknownRole
+	}
+	return r, nil
+}
+
+// AtLeast returns true if the role is as privileged as, or more privileged than min
+func (r Role) AtLeast(min Role) bool {
+	rank, ok := roleRanks[r]
+	if !ok {
+		return false
+	}
+	return rank >= roleRanks[min]
+}
+
+// CanManage returns true if a member with this role may change or remove a member with the target role.
+// Admins manage members below them, the owner manages everyone.
+func (r Role) CanManage(target Role) bool {
+	if r == RoleOwner {
+		return target != RoleOwner
+	}
+	return r.AtLeast(RoleAdmin) && !target.AtLeast(r)
+}
+
+// CanGrant returns true if a member with this role may give the role to someone.
+// Only the owner can make other admins.
+func (r Role) CanGrant(role Role) bool {
+	if _, err := ParseRole(string(role)); err != nil {
+		return false
+	}
+	if r == RoleOwner {
+		return true
+	}
+	return r.AtLeast(RoleAdmin) && !role.AtLeast(r)
+}
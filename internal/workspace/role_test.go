@@ -0,0 +1,55 @@
+package workspace
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -timeout 30s -run ^TestCanManage$ github.com/easymirror/easymirror-backend/internal/workspace
+func TestCanManage(t *testing.T) {
+	tests := []struct {
+		Actor, Target Role
+		Expected      bool
+	}{
+		{Actor: RoleOwner, Target: RoleAdmin, Expected: true},
+		{Actor: RoleOwner, Target: RoleOwner, Expected: false},
+		{Actor: RoleAdmin, Target: RoleMember, Expected: true},
+		{Actor: RoleAdmin, Target: RoleViewer, Expected: true},
+		{Actor: RoleAdmin, Target: RoleAdmin, Expected: false},
+		{Actor: RoleAdmin, Target: RoleOwner, Expected: false},
+		{Actor: RoleMember, Target: RoleViewer, Expected: false},
+		{Actor: RoleViewer, Target: RoleViewer, Expected: false},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := test.Actor.CanManage(test.Target)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
+
+// go test -v -timeout 30s -run ^TestCanGrant$ github.com/easymirror/easymirror-backend/internal/workspace
+func TestCanGrant(t *testing.T) {
+	tests := []struct {
+		Actor, Role Role
+		Expected    bool
+	}{
+		{Actor: RoleOwner, Role: RoleAdmin, Expected: true},
+		{Actor: RoleOwner, Role: RoleOwner, Expected: false},
+		{Actor: RoleAdmin, Role: RoleAdmin, Expected: false},
+		{Actor: RoleAdmin, Role: RoleMember, Expected: true},
+		{Actor: RoleAdmin, Role: RoleViewer, Expected: true},
+		{Actor: RoleMember, Role: RoleViewer, Expected: false},
+		{Actor: RoleOwner, Role: Role("superuser"), Expected: false},
+	}
+
+	for testNum, test := range tests {
+		t.Run(fmt.Sprintf("Test #%v", testNum), func(t *testing.T) {
+			result := test.Actor.CanGrant(test.Role)
+			assert.Equal(t, test.Expected, result)
+		})
+	}
+}
@@ -0,0 +1,285 @@
+// Package workspace lets a team share mirror links.
+// A workspace has members with roles, and mirror links can belong to a workspace instead of just their creator.
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easymirror/easymirror-backend/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	maxNameLength  = 60
+	maxPerUser     = 20  // Max number of workspaces a single user can own
+	maxMemberCount = 100 // Max number of members in a workspace
+)
+
+var (
+	ErrNotFound    = errors.New("workspace not found")
+	ErrNotMember   = errors.New("not a member of the workspace")
+	ErrForbidden   = errors.New("not allowed in this workspace")
+	ErrInvalidName = fmt.Errorf("name must be between 1 and %v characters", maxNameLength)
+	ErrLimitHit    = errors.New("workspace limit reached")
+	ErrIsMember    = errors.New("user is already a member")
+)
+
+// Workspace is a shared space for mirror links
+type Workspace struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      Role      `json:"role"` // Role of the user that requested the workspace
+}
+
+// Member is a user that belongs to a workspace
+type Member struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Username string    `json:"username"`
+	Role     Role      `json:"role"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// Create creates a new workspace owned by a user
+func Create(ctx context.Context, db *db.Database, ownerID uuid.UUID, name string) (*Workspace, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, ErrInvalidName
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("BeginTx error: %w", err)
+	}
+
+	// Make sure the user is not hoarding workspaces
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM workspace_members WHERE user_id=($1) AND role=($2);`, ownerID, RoleOwner).Scan(&count)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("count error: %w", err)
+	}
+	if count >= maxPerUser {
+		tx.Rollback()
+		return nil, ErrLimitHit
+	}
+
+	ws := &Workspace{ID: uuid.New(), Name: name, CreatedAt: time.Now().UTC(), Role: RoleOwner}
+	_, err = tx.Exec(`
+		INSERT INTO workspaces (id, name, created_by_id, created_at)
+		VALUES (($1), ($2), ($3), ($4));
+	`, ws.ID, ws.Name, ownerID, ws.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+		VALUES (($1), ($2), ($3), ($4));
+	`, ws.ID, ownerID, RoleOwner, ws.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit error: %w", err)
+	}
+	return ws, nil
+}
+
+// List returns all workspaces a user is a member of
+func List(ctx context.Context, db *db.Database, userID uuid.UUID) ([]Workspace, error) {
+	if db == nil {
+		return nil, errors.New("database is nil")
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT w.id, w.name, w.created_at, m.role
+		FROM workspaces w INNER JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id=($1)
+		ORDER BY w.name ASC;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	workspaces := []Workspace{}
+	for rows.Next() {
+		var ws Workspace
+		if err = rows.Scan(&ws.ID, &ws.Name, &ws.CreatedAt, &ws.Role); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, rows.Err()
+}
+
+// MemberRole returns the role of a user in a workspace, or ErrNotMember
+func MemberRole(ctx context.Context, db *db.Database, workspaceID, userID uuid.UUID) (Role, error) {
+	if db == nil {
+		return "", errors.New("database is nil")
+	}
+	var role Role
+	err := db.PostgresConn.QueryRowContext(ctx, `
+		SELECT role FROM workspace_members WHERE workspace_id=($1) AND user_id=($2);
+	`, workspaceID, userID).Scan(&role)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "", ErrNotMember
+	case err != nil:
+		return "", fmt.Errorf("query error: %w", err)
+	}
+	return role, nil
+}
+
+// Members returns all members of a workspace. The user asking must be a member.
+func Members(ctx context.Context, db *db.Database, workspaceID, userID uuid.UUID) ([]Member, error) {
+	if _, err := MemberRole(ctx, db, workspaceID, userID); err != nil {
+		return nil, err
+	}
+	rows, err := db.PostgresConn.QueryContext(ctx, `
+		SELECT m.user_id, u.email, u.username, m.role, m.added_at
+		FROM workspace_members m INNER JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id=($1)
+		ORDER BY m.added_at ASC;
+	`, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var m Member
+		var email, username sql.NullString
+		if err = rows.Scan(&m.UserID, &email, &username, &m.Role, &m.AddedAt); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		m.Email, m.Username = email.String, username.String
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddMember adds a user to a workspace. The actor must be allowed to grant the role.
+func AddMember(ctx context.Context, db *db.Database, workspaceID, actorID, userID uuid.UUID, role Role) error {
+	actorRole, err := MemberRole(ctx, db, workspaceID, actorID)
+	if err != nil {
+		return err
+	}
+	if !actorRole.CanGrant(role) {
+		return ErrForbidden
+	}
+
+	tx, err := db.PostgresConn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTx error: %w", err)
+	}
+	var count int
+	if err = tx.QueryRow(`SELECT COUNT(*) FROM workspace_members WHERE workspace_id=($1);`, workspaceID).Scan(&count); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("count error: %w", err)
+	}
+	if count >= maxMemberCount {
+		tx.Rollback()
+		return ErrLimitHit
+	}
+	res, err := tx.Exec(`
+		INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+		VALUES (($1), ($2), ($3), ($4))
+		ON CONFLICT DO NOTHING;
+	`, workspaceID, userID, role, time.Now().UTC())
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("exec error: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return ErrIsMember
+	}
+	if err = tx.Commit(); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+// UpdateMemberRole changes the role of a member. The actor must be allowed to manage
+// the member's current role and to grant the new one.
+func UpdateMemberRole(ctx context.Context, db *db.Database, workspaceID, actorID, userID uuid.UUID, role Role) error {
+	actorRole, targetRole, err := roles(ctx, db, workspaceID, actorID, userID)
+	if err != nil {
+		return err
+	}
+	if !actorRole.CanManage(targetRole) || !actorRole.CanGrant(role) {
+		return ErrForbidden
+	}
+	_, err = db.PostgresConn.ExecContext(ctx, `
+		UPDATE workspace_members SET role=($1) WHERE workspace_id=($2) AND user_id=($3);
+	`, role, workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember removes a member from a workspace. Members can always remove themselves,
+// except the owner who has to delete the workspace instead.
+func RemoveMember(ctx context.Context, db *db.Database, workspaceID, actorID, userID uuid.UUID) error {
+	actorRole, targetRole, err := roles(ctx, db, workspaceID, actorID, userID)
+	if err != nil {
+		return err
+	}
+	leaving := actorID == userID
+	if targetRole == RoleOwner || (!leaving && !actorRole.CanManage(targetRole)) {
+		return ErrForbidden
+	}
+	_, err = db.PostgresConn.ExecContext(ctx, `
+		DELETE FROM workspace_members WHERE workspace_id=($1) AND user_id=($2);
+	`, workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a workspace. Only the owner can do this.
+// Mirror links in the workspace are kept and go back to their creators.
+func Delete(ctx context.Context, db *db.Database, workspaceID, actorID uuid.UUID) error {
+	role, err := MemberRole(ctx, db, workspaceID, actorID)
+	if err != nil {
+		return err
+	}
+	if role != RoleOwner {
+		return ErrForbidden
+	}
+	if _, err = db.PostgresConn.ExecContext(ctx, `DELETE FROM workspaces WHERE id=($1);`, workspaceID); err != nil {
+		return fmt.Errorf("exec error: %w", err)
+	}
+	return nil
+}
+
+// roles returns the roles of the actor and the target user in a workspace
+func roles(ctx context.Context, db *db.Database, workspaceID, actorID, userID uuid.UUID) (Role, Role, error) {
+	actorRole, err := MemberRole(ctx, db, workspaceID, actorID)
+	if err != nil {
+		return "", "", err
+	}
+	targetRole, err := MemberRole(ctx, db, workspaceID, userID)
+	if errors.Is(err, ErrNotMember) {
+		return "", "", ErrNotFound
+	} else if err != nil {
+		return "", "", err
+	}
+	return actorRole, targetRole, nil
+}